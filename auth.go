@@ -0,0 +1,155 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrAuthNotRefreshable is returned by AuthProvider.Refresh if the
+// credentials cannot be refreshed, e.g. for API keys.
+var ErrAuthNotRefreshable = errors.New("elastic: credentials cannot be refreshed")
+
+// AuthProvider provides the credentials for requests to Elasticsearch,
+// e.g. API keys or bearer tokens from the token service or an OpenID
+// Connect provider. Use SetAuthProvider to install an AuthProvider.
+// It takes precedence over the credentials of SetBasicAuth.
+//
+// Implementations must be safe for concurrent use.
+type AuthProvider interface {
+	// Authorization returns the value of the Authorization header for
+	// the next request, e.g. "Bearer <token>".
+	Authorization(ctx context.Context) (string, error)
+
+	// Refresh is called when Elasticsearch rejected the credentials with
+	// HTTP status 401 (Unauthorized). If Refresh returns nil, the request
+	// is replayed once with the credentials returned by Authorization.
+	Refresh(ctx context.Context) error
+}
+
+// -- APIKeyAuth --
+
+// APIKeyAuth is an AuthProvider for Elasticsearch API keys.
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/7.x/security-api-create-api-key.html
+// for details.
+type APIKeyAuth struct {
+	authorization string
+}
+
+// NewAPIKeyAuth creates a new APIKeyAuth with the id and the key
+// returned by the Create API key API.
+func NewAPIKeyAuth(id, key string) *APIKeyAuth {
+	return NewAPIKeyAuthEncoded(base64.StdEncoding.EncodeToString([]byte(id + ":" + key)))
+}
+
+// NewAPIKeyAuthEncoded creates a new APIKeyAuth with the base64-encoded
+// form of "id:key", as returned in the "encoded" field of the Create API
+// key API.
+func NewAPIKeyAuthEncoded(encoded string) *APIKeyAuth {
+	return &APIKeyAuth{authorization: "ApiKey " + encoded}
+}
+
+// Authorization returns the API key.
+func (a *APIKeyAuth) Authorization(ctx context.Context) (string, error) {
+	return a.authorization, nil
+}
+
+// Refresh returns ErrAuthNotRefreshable, as API keys cannot be refreshed.
+func (a *APIKeyAuth) Refresh(ctx context.Context) error {
+	return ErrAuthNotRefreshable
+}
+
+// -- BearerTokenAuth --
+
+// TokenFunc returns a new access token, and the time it expires.
+// A zero expiry means that the token is used until Elasticsearch
+// rejects it.
+type TokenFunc func(ctx context.Context) (token string, expiry time.Time, err error)
+
+// BearerTokenAuth is an AuthProvider for bearer tokens, e.g. from the
+// token service of Elasticsearch or from an OpenID Connect provider. It
+// caches the token until it expires or is rejected by Elasticsearch.
+type BearerTokenAuth struct {
+	fetch        TokenFunc
+	expiryLeeway time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewBearerTokenAuth creates a new BearerTokenAuth that gets its tokens
+// from fetch.
+func NewBearerTokenAuth(fetch TokenFunc) *BearerTokenAuth {
+	return &BearerTokenAuth{
+		fetch:        fetch,
+		expiryLeeway: 10 * time.Second,
+	}
+}
+
+// ExpiryLeeway specifies how long before its expiry a token is renewed
+// (10 seconds by default).
+func (a *BearerTokenAuth) ExpiryLeeway(leeway time.Duration) *BearerTokenAuth {
+	a.mu.Lock()
+	a.expiryLeeway = leeway
+	a.mu.Unlock()
+	return a
+}
+
+// Authorization returns the cached token, or gets a new one if there
+// is none or it is about to expire.
+func (a *BearerTokenAuth) Authorization(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token == "" || (!a.expiry.IsZero() && !time.Now().Before(a.expiry.Add(-a.expiryLeeway))) {
+		if err := a.renew(ctx); err != nil {
+			return "", err
+		}
+	}
+	return "Bearer " + a.token, nil
+}
+
+// Refresh gets a new token.
+func (a *BearerTokenAuth) Refresh(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.renew(ctx)
+}
+
+// renew gets a new token. The caller must hold the lock.
+func (a *BearerTokenAuth) renew(ctx context.Context) error {
+	token, expiry, err := a.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	a.token, a.expiry = token, expiry
+	return nil
+}
+
+// setAuthorization sets the Authorization header of the request, either
+// from the AuthProvider or, if there is none, from the basic auth
+// credentials.
+func setAuthorization(ctx context.Context, req *http.Request, provider AuthProvider, username, password string) error {
+	if provider != nil {
+		auth, err := provider.Authorization(ctx)
+		if err != nil {
+			return err
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		return nil
+	}
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientWithAPIKey(t *testing.T) {
+	var auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetBasicAuth("user", "secret"),
+		SetAPIKey("VuaCfGcBCdbkQm-e5aOx", "ui2lp2axTNmsyakw9tvNnw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "ApiKey VnVhQ2ZHY0JDZGJrUW0tZTVhT3g6dWkybHAyYXhUTm1zeWFrdzl0dk5udw==", auth; want != have {
+		t.Fatalf("expected Authorization %q; got: %q", want, have)
+	}
+}
+
+func TestClientWithAPIKeyDoesNotReplayOnUnauthorized(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false), SetAPIKey("id", "key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if !IsUnauthorized(err) {
+		t.Fatalf("expected unauthorized error; got: %v", err)
+	}
+	if want, have := int64(1), atomic.LoadInt64(&requests); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+}
+
+func TestClientWithBearerTokenRefreshesOnUnauthorized(t *testing.T) {
+	var requests int64
+	var validToken atomic.Value
+	validToken.Store("token-1")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if r.Header.Get("Authorization") != "Bearer "+validToken.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	var fetches int64
+	auth := NewBearerTokenAuth(func(ctx context.Context) (string, time.Time, error) {
+		n := atomic.AddInt64(&fetches, 1)
+		return fmt.Sprintf("token-%d", n), time.Time{}, nil
+	})
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false), SetAuthProvider(auth))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First request with the first token
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(1), atomic.LoadInt64(&requests); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+
+	// Token gets invalid: Refresh and replay
+	validToken.Store("token-2")
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(3), atomic.LoadInt64(&requests); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+	if want, have := int64(2), atomic.LoadInt64(&fetches); want != have {
+		t.Fatalf("expected %d token fetches; got: %d", want, have)
+	}
+
+	// Replay only once
+	validToken.Store("never")
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if !IsUnauthorized(err) {
+		t.Fatalf("expected unauthorized error; got: %v", err)
+	}
+	if want, have := int64(5), atomic.LoadInt64(&requests); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+}
+
+func TestBearerTokenAuthExpiry(t *testing.T) {
+	var fetches int64
+	auth := NewBearerTokenAuth(func(ctx context.Context) (string, time.Time, error) {
+		n := atomic.AddInt64(&fetches, 1)
+		return fmt.Sprintf("token-%d", n), time.Now().Add(time.Minute), nil
+	})
+	for i := 0; i < 3; i++ {
+		value, err := auth.Authorization(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := "Bearer token-1", value; want != have {
+			t.Fatalf("expected %q; got: %q", want, have)
+		}
+	}
+
+	// Renew tokens that are about to expire
+	auth.ExpiryLeeway(2 * time.Minute)
+	value, err := auth.Authorization(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "Bearer token-2", value; want != have {
+		t.Fatalf("expected %q; got: %q", want, have)
+	}
+}
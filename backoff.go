@@ -146,3 +146,19 @@ func (b *SimpleBackoff) Next(retry int) (time.Duration, bool) {
 	}
 	return time.Duration(ms) * time.Millisecond, true
 }
+
+// -- Max Retries Backoff --
+
+// maxRetriesBackoff wraps a Backoff and stops after maxRetries retries.
+type maxRetriesBackoff struct {
+	backoff    Backoff
+	maxRetries int
+}
+
+// Next implements BackoffFunc for maxRetriesBackoff.
+func (b maxRetriesBackoff) Next(retry int) (time.Duration, bool) {
+	if retry > b.maxRetries {
+		return 0, false
+	}
+	return b.backoff.Next(retry)
+}
@@ -170,12 +170,33 @@ func (s *BulkService) WaitForActiveShards(waitForActiveShards string) *BulkServi
 }
 
 // Add adds bulkable requests, i.e. BulkIndexRequest, BulkUpdateRequest,
-// and/or BulkDeleteRequest.
+// and/or BulkDeleteRequest. These requests encode their source with the
+// Encoder of the client (see SetEncoder).
 func (s *BulkService) Add(requests ...BulkableRequest) *BulkService {
+	s.useEncoder(requests...)
 	s.requests = append(s.requests, requests...)
 	return s
 }
 
+// bulkEncoderSetter is implemented by the bulkable requests of this
+// package, which encode their source with the Encoder of the client.
+type bulkEncoderSetter interface {
+	setEncoder(enc Encoder)
+}
+
+// useEncoder makes requests encode their source with the Encoder of the
+// client (see SetEncoder).
+func (s *BulkService) useEncoder(requests ...BulkableRequest) {
+	if s.client == nil {
+		return
+	}
+	for _, r := range requests {
+		if setter, ok := r.(bulkEncoderSetter); ok {
+			setter.setEncoder(s.client.encoder)
+		}
+	}
+}
+
 // EstimatedSizeInBytes returns the estimated size of all bulkable
 // requests added via Add.
 func (s *BulkService) EstimatedSizeInBytes() int64 {
@@ -213,12 +234,19 @@ func (s *BulkService) bodyAsString() (string, error) {
 	var buf strings.Builder
 	buf.Grow(int(s.EstimatedSizeInBytes()))
 
+	compatible := s.client.compatibilityEnabled()
 	for _, req := range s.requests {
 		source, err := req.Source()
 		if err != nil {
 			return "", err
 		}
-		for _, line := range source {
+		for i, line := range source {
+			if i == 0 && compatible {
+				// Mapping types have been removed in Elasticsearch 8
+				if line, err = bulkLineWithoutType(line); err != nil {
+					return "", err
+				}
+			}
 			buf.WriteString(line)
 			buf.WriteByte('\n')
 		}
@@ -253,7 +281,7 @@ func (s *BulkService) Do(ctx context.Context) (*BulkResponse, error) {
 		}
 		path += index + "/"
 	}
-	if len(s.typ) > 0 {
+	if len(s.typ) > 0 && !s.client.compatibilityEnabled() {
 		typ, err := uritemplates.Expand("{type}", map[string]string{
 			"type": s.typ,
 		})
@@ -7,7 +7,6 @@ package elastic
 //go:generate easyjson bulk_delete_request.go
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -30,7 +29,8 @@ type BulkDeleteRequest struct {
 	ifSeqNo       *int64
 	ifPrimaryTerm *int64
 
-	source []string
+	source  []string
+	encoder Encoder // encodes the source, if set (see BulkService.Add)
 
 	useEasyJSON bool
 }
@@ -66,6 +66,15 @@ func (r *BulkDeleteRequest) UseEasyJSON(enable bool) *BulkDeleteRequest {
 	return r
 }
 
+// setEncoder makes the request encode its source with enc, unless it
+// already has an encoder.
+func (r *BulkDeleteRequest) setEncoder(enc Encoder) {
+	if r.encoder == nil {
+		r.encoder = enc
+		r.source = nil
+	}
+}
+
 // Index specifies the Elasticsearch index to use for this delete request.
 // If unspecified, the index set on the BulkService will be used.
 func (r *BulkDeleteRequest) Index(index string) *BulkDeleteRequest {
@@ -172,8 +181,8 @@ func (r *BulkDeleteRequest) Source() ([]string, error) {
 		// easyjson
 		body, err = command.MarshalJSON()
 	} else {
-		// encoding/json
-		body, err = json.Marshal(command)
+		// encoding/json, or the Encoder of the client
+		body, err = encodeBulkLine(command, r.encoder)
 	}
 	if err != nil {
 		return nil, err
@@ -32,7 +32,8 @@ type BulkIndexRequest struct {
 	ifSeqNo         *int64
 	ifPrimaryTerm   *int64
 
-	source []string
+	source  []string
+	encoder Encoder // encodes the source, if set (see BulkService.Add)
 
 	useEasyJSON bool
 }
@@ -74,6 +75,15 @@ func (r *BulkIndexRequest) UseEasyJSON(enable bool) *BulkIndexRequest {
 	return r
 }
 
+// setEncoder makes the request encode its source with enc, unless it
+// already has an encoder.
+func (r *BulkIndexRequest) setEncoder(enc Encoder) {
+	if r.encoder == nil {
+		r.encoder = enc
+		r.source = nil
+	}
+}
+
 // Index specifies the Elasticsearch index to use for this index request.
 // If unspecified, the index set on the BulkService will be used.
 func (r *BulkIndexRequest) Index(index string) *BulkIndexRequest {
@@ -224,8 +234,8 @@ func (r *BulkIndexRequest) Source() ([]string, error) {
 		// easyjson
 		body, err = command.MarshalJSON()
 	} else {
-		// encoding/json
-		body, err = json.Marshal(command)
+		// encoding/json, or the Encoder of the client
+		body, err = encodeBulkLine(command, r.encoder)
 	}
 	if err != nil {
 		return nil, err
@@ -237,7 +247,7 @@ func (r *BulkIndexRequest) Source() ([]string, error) {
 	if r.doc != nil {
 		switch t := r.doc.(type) {
 		default:
-			body, err := json.Marshal(r.doc)
+			body, err := encodeBulkLine(r.doc, r.encoder)
 			if err != nil {
 				return nil, err
 			}
@@ -7,6 +7,7 @@ package elastic
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -481,6 +482,7 @@ func (w *bulkWorker) work(ctx context.Context) {
 		case req, open := <-w.p.requestsC:
 			if open {
 				// Received a new request
+				w.service.useEncoder(req)
 				if _, err = req.Source(); err == nil {
 					w.service.Add(req)
 					if w.commitRequired() {
@@ -503,7 +505,10 @@ func (w *bulkWorker) work(ctx context.Context) {
 			w.flushAckC <- struct{}{}
 		}
 		if err != nil {
-			w.p.c.errorf("elastic: bulk processor %q was unable to perform work: %v", w.p.name, err)
+			w.p.c.log(ctx, LogLevelError, fmt.Sprintf("elastic: bulk processor %q was unable to perform work: %v", w.p.name, err),
+				"processor", w.p.name,
+				"worker", w.i,
+				"error", err)
 			if !stop {
 				waitForActive := func() {
 					// Add back pressure to prevent Add calls from filling up the request queue
@@ -556,7 +561,10 @@ func (w *bulkWorker) commit(ctx context.Context) error {
 	}
 	// notifyFunc will be called if retry fails
 	notifyFunc := func(err error) {
-		w.p.c.errorf("elastic: bulk processor %q failed but may retry: %v", w.p.name, err)
+		w.p.c.log(ctx, LogLevelWarn, fmt.Sprintf("elastic: bulk processor %q failed but may retry: %v", w.p.name, err),
+			"processor", w.p.name,
+			"worker", w.i,
+			"error", err)
 	}
 
 	id := atomic.AddInt64(&w.p.executionId, 1)
@@ -577,10 +585,38 @@ func (w *bulkWorker) commit(ctx context.Context) error {
 	}
 
 	// Commit bulk requests
+	start := time.Now()
 	err := RetryNotify(commitFunc, w.p.backoff, notifyFunc)
 	w.updateStats(res)
 	if err != nil {
-		w.p.c.errorf("elastic: bulk processor %q failed: %v", w.p.name, err)
+		w.p.c.log(ctx, LogLevelError, fmt.Sprintf("elastic: bulk processor %q failed: %v", w.p.name, err),
+			"processor", w.p.name,
+			"worker", w.i,
+			"execution_id", id,
+			"requests", len(reqs),
+			"error", err)
+	} else if w.p.c.logEnabled(ctx, LogLevelDebug) {
+		w.p.c.log(ctx, LogLevelDebug, fmt.Sprintf("elastic: bulk processor %q committed %d requests", w.p.name, len(reqs)),
+			"processor", w.p.name,
+			"worker", w.i,
+			"execution_id", id,
+			"requests", len(reqs),
+			"duration", time.Since(start))
+	}
+	if observer := w.p.c.observer; observer != nil {
+		event := BulkCommitEvent{
+			Processor:   w.p.name,
+			Worker:      w.i,
+			ExecutionID: id,
+			Requests:    len(reqs),
+			Duration:    time.Since(start),
+			Err:         err,
+		}
+		if res != nil {
+			event.Succeeded = len(res.Succeeded())
+			event.Failed = len(res.Failed())
+		}
+		observer.BulkCommit(ctx, event)
 	}
 
 	// Invoke after callback
@@ -599,14 +635,18 @@ func (w *bulkWorker) waitForActiveConnection(ready chan<- struct{}) {
 
 	client := w.p.c
 	stopReconnC := w.p.stopReconnC
-	w.p.c.errorf("elastic: bulk processor %q is waiting for an active connection", w.p.name)
+	w.p.c.log(context.Background(), LogLevelError, fmt.Sprintf("elastic: bulk processor %q is waiting for an active connection", w.p.name),
+		"processor", w.p.name,
+		"worker", w.i)
 
 	// loop until a health check finds at least 1 active connection or the reconnection channel is closed
 	for {
 		select {
 		case _, ok := <-stopReconnC:
 			if !ok {
-				w.p.c.errorf("elastic: bulk processor %q active connection check interrupted", w.p.name)
+				w.p.c.log(context.Background(), LogLevelError, fmt.Sprintf("elastic: bulk processor %q active connection check interrupted", w.p.name),
+					"processor", w.p.name,
+					"worker", w.i)
 				return
 			}
 		case <-t.C:
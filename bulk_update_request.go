@@ -37,7 +37,8 @@ type BulkUpdateRequest struct {
 	ifSeqNo         *int64
 	ifPrimaryTerm   *int64
 
-	source []string
+	source  []string
+	encoder Encoder // encodes the source, if set (see BulkService.Add)
 
 	useEasyJSON bool
 }
@@ -86,6 +87,15 @@ func (r *BulkUpdateRequest) UseEasyJSON(enable bool) *BulkUpdateRequest {
 	return r
 }
 
+// setEncoder makes the request encode its source with enc, unless it
+// already has an encoder.
+func (r *BulkUpdateRequest) setEncoder(enc Encoder) {
+	if r.encoder == nil {
+		r.encoder = enc
+		r.source = nil
+	}
+}
+
 // Index specifies the Elasticsearch index to use for this update request.
 // If unspecified, the index set on the BulkService will be used.
 func (r *BulkUpdateRequest) Index(index string) *BulkUpdateRequest {
@@ -274,8 +284,8 @@ func (r *BulkUpdateRequest) Source() ([]string, error) {
 		// easyjson
 		body, err = command.MarshalJSON()
 	} else {
-		// encoding/json
-		body, err = json.Marshal(command)
+		// encoding/json, or the Encoder of the client
+		body, err = encodeBulkLine(command, r.encoder)
 	}
 	if err != nil {
 		return nil, err
@@ -320,8 +330,8 @@ func (r *BulkUpdateRequest) Source() ([]string, error) {
 		// easyjson
 		body, err = data.MarshalJSON()
 	} else {
-		// encoding/json
-		body, err = json.Marshal(data)
+		// encoding/json, or the Encoder of the client
+		body, err = encodeBulkLine(data, r.encoder)
 	}
 	if err != nil {
 		return nil, err
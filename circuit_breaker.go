@@ -0,0 +1,295 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// circuitBreakerBuckets is the number of buckets of the sliding window.
+	circuitBreakerBuckets = 10
+)
+
+// CircuitBreakerState is the state of the circuit breaker of a connection.
+type CircuitBreakerState int
+
+const (
+	// CircuitBreakerClosed lets all requests through.
+	CircuitBreakerClosed CircuitBreakerState = iota
+	// CircuitBreakerOpen blocks all requests until the open timeout has passed.
+	CircuitBreakerOpen
+	// CircuitBreakerHalfOpen lets a single trial request through. If it
+	// succeeds, the circuit breaker is closed again. Otherwise it is opened.
+	CircuitBreakerHalfOpen
+)
+
+// String returns a textual representation of the state.
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitBreakerClosed:
+		return "closed"
+	case CircuitBreakerOpen:
+		return "open"
+	case CircuitBreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerStateChangeFunc is called when the circuit breaker of a
+// connection changes its state.
+type CircuitBreakerStateChangeFunc func(conn Connection, from, to CircuitBreakerState)
+
+// CircuitBreaker specifies the settings of the circuit breakers that
+// guard each connection of a Client. Use SetCircuitBreaker to enable it.
+//
+// The circuit breaker of a connection opens when a number of consecutive
+// requests fail, or when the rate of failed requests within a sliding
+// window exceeds a threshold. A request fails if it returns a transport
+// error or HTTP status 429 or 5xx. While open, no requests are sent to the
+// connection. After the open timeout, a single trial request is let
+// through (half-open). If it succeeds, the circuit breaker is closed again.
+type CircuitBreaker struct {
+	consecutiveFailures int
+	failureRate         float64
+	minRequests         int
+	window              time.Duration
+	openTimeout         time.Duration
+	onStateChange       CircuitBreakerStateChangeFunc
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker with default settings:
+// It opens after 5 consecutive failures or when at least half of at least
+// 20 requests within 10 seconds fail, and stays open for 30 seconds.
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		consecutiveFailures: 5,
+		failureRate:         0.5,
+		minRequests:         20,
+		window:              10 * time.Second,
+		openTimeout:         30 * time.Second,
+	}
+}
+
+// ConsecutiveFailures sets the number of consecutive failures after which
+// the circuit breaker opens. Use 0 to disable.
+func (cb *CircuitBreaker) ConsecutiveFailures(n int) *CircuitBreaker {
+	cb.consecutiveFailures = n
+	return cb
+}
+
+// FailureRate sets the rate of failed requests in [0..1] within the
+// sliding window after which the circuit breaker opens. Use 0 to disable.
+func (cb *CircuitBreaker) FailureRate(rate float64) *CircuitBreaker {
+	cb.failureRate = rate
+	return cb
+}
+
+// MinRequests sets the minimum number of requests within the sliding
+// window before the failure rate is considered.
+func (cb *CircuitBreaker) MinRequests(n int) *CircuitBreaker {
+	cb.minRequests = n
+	return cb
+}
+
+// Window sets the duration of the sliding window for the failure rate.
+func (cb *CircuitBreaker) Window(window time.Duration) *CircuitBreaker {
+	cb.window = window
+	return cb
+}
+
+// OpenTimeout sets the time the circuit breaker stays open before it
+// lets a trial request through.
+func (cb *CircuitBreaker) OpenTimeout(timeout time.Duration) *CircuitBreaker {
+	cb.openTimeout = timeout
+	return cb
+}
+
+// OnStateChange sets a callback that is invoked whenever the circuit
+// breaker of a connection changes its state.
+func (cb *CircuitBreaker) OnStateChange(f CircuitBreakerStateChangeFunc) *CircuitBreaker {
+	cb.onStateChange = f
+	return cb
+}
+
+// isFailure returns true if the outcome of a request counts as a failure.
+func (cb *CircuitBreaker) isFailure(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	if res == nil {
+		return false
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
+
+// bucketWidth returns the duration covered by a bucket of the window.
+func (cb *CircuitBreaker) bucketWidth() time.Duration {
+	width := cb.window / circuitBreakerBuckets
+	if width <= 0 {
+		width = time.Millisecond
+	}
+	return width
+}
+
+// -- State per connection --
+
+// circuitBreakerBucket counts requests within a slice of the window.
+type circuitBreakerBucket struct {
+	epoch    int64 // index of the slice of time
+	total    int
+	failures int
+}
+
+// circuitBreakerState is the state of the circuit breaker of a connection.
+// It is protected by the lock of the connection.
+type circuitBreakerState struct {
+	state        CircuitBreakerState
+	consecutive  int       // number of consecutive failures
+	openedAt     time.Time // time the circuit breaker opened
+	trialStarted time.Time // time the trial request in half-open state started
+	buckets      [circuitBreakerBuckets]circuitBreakerBucket
+}
+
+// available returns true if a request may be sent at the given time.
+func (s *circuitBreakerState) available(cb *CircuitBreaker, now time.Time) bool {
+	switch s.state {
+	case CircuitBreakerOpen:
+		return !now.Before(s.openedAt.Add(cb.openTimeout))
+	case CircuitBreakerHalfOpen:
+		// Only a single trial; unless it never reported back.
+		return s.trialStarted.IsZero() || !now.Before(s.trialStarted.Add(cb.openTimeout))
+	default:
+		return true
+	}
+}
+
+// acquire records that a request is sent at the given time. It returns
+// the previous state if that changes the state.
+func (s *circuitBreakerState) acquire(cb *CircuitBreaker, now time.Time) (CircuitBreakerState, bool) {
+	from := s.state
+	switch s.state {
+	case CircuitBreakerOpen:
+		s.state = CircuitBreakerHalfOpen
+		s.trialStarted = now
+		return from, true
+	case CircuitBreakerHalfOpen:
+		s.trialStarted = now
+	}
+	return from, false
+}
+
+// record adds the outcome of a request. It returns the previous state
+// if that changes the state.
+func (s *circuitBreakerState) record(cb *CircuitBreaker, now time.Time, failed bool) (CircuitBreakerState, bool) {
+	from := s.state
+	switch s.state {
+	case CircuitBreakerHalfOpen:
+		if failed {
+			s.open(now)
+		} else {
+			s.reset()
+		}
+		return from, true
+	case CircuitBreakerOpen:
+		// Response of a request sent before the circuit breaker opened
+		return from, false
+	}
+
+	epoch := now.UnixNano() / int64(cb.bucketWidth())
+	b := &s.buckets[epoch%circuitBreakerBuckets]
+	if b.epoch != epoch {
+		*b = circuitBreakerBucket{epoch: epoch}
+	}
+	b.total++
+	if failed {
+		b.failures++
+		s.consecutive++
+	} else {
+		s.consecutive = 0
+	}
+
+	if failed && cb.consecutiveFailures > 0 && s.consecutive >= cb.consecutiveFailures {
+		s.open(now)
+		return from, true
+	}
+	if failed && cb.failureRate > 0 {
+		var total, failures int
+		for _, b := range s.buckets {
+			if b.epoch > epoch-circuitBreakerBuckets {
+				total += b.total
+				failures += b.failures
+			}
+		}
+		if total >= cb.minRequests && float64(failures)/float64(total) >= cb.failureRate {
+			s.open(now)
+			return from, true
+		}
+	}
+	return from, false
+}
+
+// open opens the circuit breaker.
+func (s *circuitBreakerState) open(now time.Time) {
+	s.state = CircuitBreakerOpen
+	s.openedAt = now
+	s.trialStarted = time.Time{}
+}
+
+// reset closes the circuit breaker and clears all counters.
+func (s *circuitBreakerState) reset() {
+	*s = circuitBreakerState{}
+}
+
+// CircuitBreakerState returns the state of the circuit breaker of this
+// connection. It is always closed if circuit breakers are disabled.
+func (c *conn) CircuitBreakerState() CircuitBreakerState {
+	c.RLock()
+	defer c.RUnlock()
+	return c.breaker.state
+}
+
+// breakerAvailable returns true if the circuit breaker of this connection
+// lets a request through at the given time.
+func (c *conn) breakerAvailable(cb *CircuitBreaker, now time.Time) bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.breaker.available(cb, now)
+}
+
+// breakerAcquire records that a request is sent through the circuit
+// breaker of this connection.
+func (c *conn) breakerAcquire(cb *CircuitBreaker, now time.Time) (CircuitBreakerState, CircuitBreakerState, bool) {
+	c.Lock()
+	defer c.Unlock()
+	from, changed := c.breaker.acquire(cb, now)
+	return from, c.breaker.state, changed
+}
+
+// breakerRecord adds the outcome of a request to the circuit breaker
+// of this connection.
+func (c *conn) breakerRecord(cb *CircuitBreaker, now time.Time, failed bool) (CircuitBreakerState, CircuitBreakerState, bool) {
+	c.Lock()
+	defer c.Unlock()
+	from, changed := c.breaker.record(cb, now, failed)
+	return from, c.breaker.state, changed
+}
+
+// circuitBreakerChanged logs a state change of the circuit breaker
+// of the given connection and invokes the callback.
+func (c *Client) circuitBreakerChanged(cb *CircuitBreaker, conn *conn, from, to CircuitBreakerState) {
+	c.log(context.Background(), LogLevelWarn, fmt.Sprintf("elastic: circuit breaker of %s changed from %s to %s", conn.URL(), from, to),
+		"node", conn.URL(),
+		"from", from.String(),
+		"to", to.String())
+	if cb.onStateChange != nil {
+		cb.onStateChange(conn, from, to)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerConsecutiveFailures(t *testing.T) {
+	cb := NewCircuitBreaker().ConsecutiveFailures(3).FailureRate(0).OpenTimeout(time.Second)
+	var s circuitBreakerState
+	now := time.Now()
+
+	s.record(cb, now, true)
+	s.record(cb, now, true)
+	s.record(cb, now, false) // resets consecutive failures
+	s.record(cb, now, true)
+	s.record(cb, now, true)
+	if want, have := CircuitBreakerClosed, s.state; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+	from, changed := s.record(cb, now, true)
+	if !changed {
+		t.Fatal("expected state to change")
+	}
+	if want, have := CircuitBreakerClosed, from; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+	if want, have := CircuitBreakerOpen, s.state; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+	if s.available(cb, now.Add(500*time.Millisecond)) {
+		t.Fatal("expected open circuit breaker to block requests")
+	}
+
+	// Half-open after timeout, with a single trial request
+	later := now.Add(time.Second)
+	if !s.available(cb, later) {
+		t.Fatal("expected circuit breaker to let a trial request through")
+	}
+	if _, changed := s.acquire(cb, later); !changed {
+		t.Fatal("expected state to change")
+	}
+	if want, have := CircuitBreakerHalfOpen, s.state; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+	if s.available(cb, later) {
+		t.Fatal("expected only a single trial request")
+	}
+
+	// Trial fails: open again
+	s.record(cb, later, true)
+	if want, have := CircuitBreakerOpen, s.state; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+
+	// Trial succeeds: closed
+	later = later.Add(time.Second)
+	s.acquire(cb, later)
+	s.record(cb, later, false)
+	if want, have := CircuitBreakerClosed, s.state; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+	if want, have := 0, s.consecutive; want != have {
+		t.Fatalf("expected %d; got: %d", want, have)
+	}
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	cb := NewCircuitBreaker().ConsecutiveFailures(0).FailureRate(0.5).MinRequests(10).Window(time.Second)
+	var s circuitBreakerState
+	now := time.Now()
+
+	// Failures below the minimum number of requests
+	for i := 0; i < 4; i++ {
+		s.record(cb, now, true)
+	}
+	if want, have := CircuitBreakerClosed, s.state; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+
+	// Old requests slide out of the window
+	now = now.Add(2 * time.Second)
+	for i := 0; i < 6; i++ {
+		s.record(cb, now, false)
+	}
+	s.record(cb, now, true)
+	if want, have := CircuitBreakerClosed, s.state; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+
+	for i := 0; i < 5; i++ {
+		s.record(cb, now, true)
+	}
+	if want, have := CircuitBreakerOpen, s.state; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+}
+
+func TestCircuitBreakerIsFailure(t *testing.T) {
+	cb := NewCircuitBreaker()
+	tests := []struct {
+		Status   int
+		Expected bool
+	}{
+		{200, false},
+		{404, false},
+		{429, true},
+		{500, true},
+		{503, true},
+	}
+	for _, test := range tests {
+		if want, have := test.Expected, cb.isFailure(&http.Response{StatusCode: test.Status}, nil); want != have {
+			t.Errorf("status %d: expected %v; got: %v", test.Status, want, have)
+		}
+	}
+	if !cb.isFailure(nil, context.DeadlineExceeded) {
+		t.Error("expected transport error to be a failure")
+	}
+}
+
+func TestClientWithCircuitBreaker(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var changes []CircuitBreakerState
+	cb := NewCircuitBreaker().
+		ConsecutiveFailures(2).
+		OpenTimeout(50 * time.Millisecond).
+		OnStateChange(func(conn Connection, from, to CircuitBreakerState) {
+			mu.Lock()
+			changes = append(changes, to)
+			mu.Unlock()
+		})
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetCircuitBreaker(cb))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if want, have := CircuitBreakerOpen, client.conns[0].CircuitBreakerState(); want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+
+	// Fail fast while open
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if !IsConnErr(err) {
+		t.Fatalf("expected connection error; got: %v", err)
+	}
+	if want, have := int64(2), atomic.LoadInt64(&requests); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+
+	// Trial request after open timeout
+	time.Sleep(60 * time.Millisecond)
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if want, have := int64(3), atomic.LoadInt64(&requests); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []CircuitBreakerState{CircuitBreakerOpen, CircuitBreakerHalfOpen, CircuitBreakerOpen}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected state changes %v; got: %v", expected, changes)
+	}
+	for i := range expected {
+		if expected[i] != changes[i] {
+			t.Fatalf("expected state changes %v; got: %v", expected, changes)
+		}
+	}
+}
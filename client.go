@@ -15,6 +15,7 @@ import (
 	"net/url"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -79,6 +80,15 @@ const (
 	// DefaultGzipEnabled specifies if gzip compression is enabled by default.
 	DefaultGzipEnabled = false
 
+	// DefaultResurrectTimeoutInitial is the time a connection stays dead
+	// after its first failure. After that time, a single request is sent
+	// to the node to probe whether it is available again.
+	DefaultResurrectTimeoutInitial = 60 * time.Second
+
+	// DefaultResurrectTimeoutMax is the maximum time a connection stays
+	// dead. The time doubles with every failure until it reaches this limit.
+	DefaultResurrectTimeoutMax = 30 * time.Minute
+
 	// off is used to disable timeouts.
 	off = -1 * time.Second
 )
@@ -119,9 +129,9 @@ type ClientOptionFunc func(*Client) error
 type Client struct {
 	c Doer // e.g. a net/*http.Client to use for requests
 
-	connsMu sync.RWMutex // connsMu guards the next block
-	conns   []*conn      // all connections
-	cindex  int          // index into conns
+	connsMu  sync.RWMutex // connsMu guards the next block
+	conns    []*conn      // all connections
+	connsGen uint64       // incremented when the URLs are reloaded
 
 	mu                        sync.RWMutex // guards the next block
 	urls                      []string     // set of URLs passed initially to the client
@@ -130,27 +140,48 @@ type Client struct {
 	infolog                   Logger       // information log for e.g. response times
 	tracelog                  Logger       // trace log for debugging
 	deprecationlog            func(*http.Request, *http.Response)
-	scheme                    string          // http or https
-	healthcheckEnabled        bool            // healthchecks enabled or disabled
-	healthcheckTimeoutStartup time.Duration   // time the healthcheck waits for a response from Elasticsearch on startup
-	healthcheckTimeout        time.Duration   // time the healthcheck waits for a response from Elasticsearch
-	healthcheckInterval       time.Duration   // interval between healthchecks
-	healthcheckStop           chan bool       // notify healthchecker to stop, and notify back
-	snifferEnabled            bool            // sniffer enabled or disabled
-	snifferTimeoutStartup     time.Duration   // time the sniffer waits for a response from nodes info API on startup
-	snifferTimeout            time.Duration   // time the sniffer waits for a response from nodes info API
-	snifferInterval           time.Duration   // interval between sniffing
-	snifferCallback           SnifferCallback // callback to modify the sniffing decision
-	snifferStop               chan bool       // notify sniffer to stop, and notify back
-	decoder                   Decoder         // used to decode data sent from Elasticsearch
-	basicAuthUsername         string          // username for HTTP Basic Auth
-	basicAuthPassword         string          // password for HTTP Basic Auth
-	sendGetBodyAs             string          // override for when sending a GET with a body
-	gzipEnabled               bool            // gzip compression enabled or disabled (default)
-	requiredPlugins           []string        // list of required plugins
-	retrier                   Retrier         // strategy for retries
-	retryStatusCodes          []int           // HTTP status codes where to retry automatically (with retrier)
-	headers                   http.Header     // a list of default headers to add to each request
+	logger                    StructuredLogger     // structured logger, used in addition to the loggers above
+	observer                  Observer             // receives events, e.g. to collect metrics
+	scheme                    string               // http or https
+	healthcheckEnabled        bool                 // healthchecks enabled or disabled
+	healthcheckTimeoutStartup time.Duration        // time the healthcheck waits for a response from Elasticsearch on startup
+	healthcheckTimeout        time.Duration        // time the healthcheck waits for a response from Elasticsearch
+	healthcheckInterval       time.Duration        // interval between healthchecks
+	healthcheckStop           chan bool            // notify healthchecker to stop, and notify back
+	healthcheckNow            chan struct{}        // notify healthchecker to run a health check now
+	snifferEnabled            bool                 // sniffer enabled or disabled
+	snifferTimeoutStartup     time.Duration        // time the sniffer waits for a response from nodes info API on startup
+	snifferTimeout            time.Duration        // time the sniffer waits for a response from nodes info API
+	snifferInterval           time.Duration        // interval between sniffing
+	snifferCallback           SnifferCallback      // callback to modify the sniffing decision
+	snifferStop               chan bool            // notify sniffer to stop, and notify back
+	snifferNow                chan struct{}        // notify sniffer to sniff now
+	decoder                   Decoder              // used to decode data sent from Elasticsearch
+	encoder                   Encoder              // used to encode request bodies sent to Elasticsearch
+	strictDecoding            StrictDecodingMode   // report unknown fields in responses
+	basicAuthUsername         string               // username for HTTP Basic Auth
+	basicAuthPassword         string               // password for HTTP Basic Auth
+	authProvider              AuthProvider         // provides credentials, e.g. API keys; takes precedence over basic auth
+	sendGetBodyAs             string               // override for when sending a GET with a body
+	gzipEnabled               bool                 // gzip compression enabled or disabled (default)
+	requiredPlugins           []string             // list of required plugins
+	retrier                   Retrier              // strategy for retries
+	retryStatusCodes          []int                // HTTP status codes where to retry automatically (with retrier)
+	retryBudget               *RetryBudget         // limits the retries of all requests, if set
+	headers                   http.Header          // a list of default headers to add to each request
+	selector                  Selector             // strategy to pick the connection for the next request
+	nodeFilter                NodeFilter           // decides which nodes may receive requests
+	zoneAttribute             string               // node attribute that holds the zone of a node, e.g. "zone"
+	localZone                 string               // zone of the caller; connections in this zone are preferred
+	resurrectTimeoutInitial   time.Duration        // time a connection stays dead after its first failure
+	resurrectTimeoutMax       time.Duration        // maximum time a connection stays dead
+	circuitBreaker            *CircuitBreaker      // settings for circuit breakers per connection, nil if disabled
+	requestInterceptors       []RequestInterceptor // run around every attempt of PerformRequest
+	compatibilityMode         CompatibilityMode    // whether to use the REST API compatibility mode of Elasticsearch 8
+	compatible                bool                 // true if requests are sent in compatibility mode
+	serverVersion             string               // version of Elasticsearch, if detected
+	deprecationHandler        DeprecationHandler   // called for each new deprecation warning
+	deprecations              deprecationTracker   // deprecation warnings seen so far
 }
 
 // NewClient creates a new client to work with Elasticsearch.
@@ -235,25 +266,31 @@ func NewSimpleClient(options ...ClientOptionFunc) (*Client, error) {
 	c := &Client{
 		c:                         http.DefaultClient,
 		conns:                     make([]*conn, 0),
-		cindex:                    -1,
 		scheme:                    DefaultScheme,
 		decoder:                   &DefaultDecoder{},
+		encoder:                   &DefaultEncoder{},
 		healthcheckEnabled:        false,
 		healthcheckTimeoutStartup: off,
 		healthcheckTimeout:        off,
 		healthcheckInterval:       off,
 		healthcheckStop:           make(chan bool),
+		healthcheckNow:            make(chan struct{}, 1),
 		snifferEnabled:            false,
 		snifferTimeoutStartup:     off,
 		snifferTimeout:            off,
 		snifferInterval:           off,
 		snifferCallback:           nopSnifferCallback,
 		snifferStop:               make(chan bool),
+		snifferNow:                make(chan struct{}, 1),
 		sendGetBodyAs:             DefaultSendGetBodyAs,
 		gzipEnabled:               DefaultGzipEnabled,
 		retrier:                   noRetries, // no retries by default
 		retryStatusCodes:          nil,       // no automatic retries for specific HTTP status codes
 		deprecationlog:            noDeprecationLog,
+		selector:                  NewRoundRobinSelector(),
+		nodeFilter:                DefaultNodeFilter,
+		resurrectTimeoutInitial:   DefaultResurrectTimeoutInitial,
+		resurrectTimeoutMax:       DefaultResurrectTimeoutMax,
 	}
 
 	// Run the options on it
@@ -262,6 +299,7 @@ func NewSimpleClient(options ...ClientOptionFunc) (*Client, error) {
 			return nil, err
 		}
 	}
+	c.setupStrictDecoding()
 
 	// Use a default URL and normalize them
 	if len(c.urls) == 0 {
@@ -290,6 +328,13 @@ func NewSimpleClient(options ...ClientOptionFunc) (*Client, error) {
 		return nil, err
 	}
 
+	// Detect the version of Elasticsearch for the compatibility mode
+	if c.compatibilityMode == CompatibilityAuto {
+		if _, err := c.DetectServerVersion(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check the required plugins
 	for _, plugin := range c.requiredPlugins {
 		found, err := c.HasPlugin(plugin)
@@ -321,25 +366,31 @@ func DialContext(ctx context.Context, options ...ClientOptionFunc) (*Client, err
 	c := &Client{
 		c:                         http.DefaultClient,
 		conns:                     make([]*conn, 0),
-		cindex:                    -1,
 		scheme:                    DefaultScheme,
 		decoder:                   &DefaultDecoder{},
+		encoder:                   &DefaultEncoder{},
 		healthcheckEnabled:        DefaultHealthcheckEnabled,
 		healthcheckTimeoutStartup: DefaultHealthcheckTimeoutStartup,
 		healthcheckTimeout:        DefaultHealthcheckTimeout,
 		healthcheckInterval:       DefaultHealthcheckInterval,
 		healthcheckStop:           make(chan bool),
+		healthcheckNow:            make(chan struct{}, 1),
 		snifferEnabled:            DefaultSnifferEnabled,
 		snifferTimeoutStartup:     DefaultSnifferTimeoutStartup,
 		snifferTimeout:            DefaultSnifferTimeout,
 		snifferInterval:           DefaultSnifferInterval,
 		snifferCallback:           nopSnifferCallback,
 		snifferStop:               make(chan bool),
+		snifferNow:                make(chan struct{}, 1),
 		sendGetBodyAs:             DefaultSendGetBodyAs,
 		gzipEnabled:               DefaultGzipEnabled,
 		retrier:                   noRetries, // no retries by default
 		retryStatusCodes:          nil,       // no automatic retries for specific HTTP status codes
 		deprecationlog:            noDeprecationLog,
+		selector:                  NewRoundRobinSelector(),
+		nodeFilter:                DefaultNodeFilter,
+		resurrectTimeoutInitial:   DefaultResurrectTimeoutInitial,
+		resurrectTimeoutMax:       DefaultResurrectTimeoutMax,
 	}
 
 	// Run the options on it
@@ -348,6 +399,7 @@ func DialContext(ctx context.Context, options ...ClientOptionFunc) (*Client, err
 			return nil, err
 		}
 	}
+	c.setupStrictDecoding()
 
 	// Use a default URL and normalize them
 	if len(c.urls) == 0 {
@@ -395,6 +447,13 @@ func DialContext(ctx context.Context, options ...ClientOptionFunc) (*Client, err
 		return nil, err
 	}
 
+	// Detect the version of Elasticsearch for the compatibility mode
+	if c.compatibilityMode == CompatibilityAuto {
+		if _, err := c.DetectServerVersion(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	// Check the required plugins
 	for _, plugin := range c.requiredPlugins {
 		found, err := c.HasPlugin(plugin)
@@ -435,9 +494,14 @@ func DialWithConfig(ctx context.Context, cfg *config.Config) (*Client, error) {
 func configToOptions(cfg *config.Config) ([]ClientOptionFunc, error) {
 	var options []ClientOptionFunc
 	if cfg != nil {
-		if cfg.URL != "" {
+		if len(cfg.URLs) > 0 {
+			options = append(options, SetURL(cfg.URLs...))
+		} else if cfg.URL != "" {
 			options = append(options, SetURL(cfg.URL))
 		}
+		if cfg.CloudID != "" {
+			options = append(options, SetCloudID(cfg.CloudID))
+		}
 		if cfg.Errorlog != "" {
 			f, err := os.OpenFile(cfg.Errorlog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 			if err != nil {
@@ -465,12 +529,60 @@ func configToOptions(cfg *config.Config) ([]ClientOptionFunc, error) {
 		if cfg.Username != "" || cfg.Password != "" {
 			options = append(options, SetBasicAuth(cfg.Username, cfg.Password))
 		}
+		if cfg.APIKeyID != "" || cfg.APIKey != "" {
+			options = append(options, SetAPIKey(cfg.APIKeyID, cfg.APIKey))
+		}
 		if cfg.Sniff != nil {
 			options = append(options, SetSniff(*cfg.Sniff))
 		}
 		if cfg.Healthcheck != nil {
 			options = append(options, SetHealthcheck(*cfg.Healthcheck))
 		}
+		if cfg.Gzip != nil {
+			options = append(options, SetGzip(*cfg.Gzip))
+		}
+		if len(cfg.Headers) > 0 {
+			options = append(options, SetHeaders(cfg.Headers))
+		}
+		if cfg.MaxRetries != nil {
+			options = append(options, SetMaxRetries(*cfg.MaxRetries))
+		}
+		if cfg.RetryInitialTimeout > 0 && cfg.RetryMaxTimeout > 0 {
+			var backoff Backoff = NewExponentialBackoff(cfg.RetryInitialTimeout, cfg.RetryMaxTimeout)
+			if cfg.MaxRetries != nil {
+				// Replaces the retrier of SetMaxRetries, so honor max_retries here
+				backoff = maxRetriesBackoff{backoff: backoff, maxRetries: *cfg.MaxRetries}
+			}
+			options = append(options, SetRetrier(NewBackoffRetrier(backoff)))
+		}
+		if len(cfg.RetryStatusCodes) > 0 {
+			options = append(options, SetRetryStatusCodes(cfg.RetryStatusCodes...))
+		}
+		if cfg.HealthcheckTimeout > 0 {
+			options = append(options, SetHealthcheckTimeout(cfg.HealthcheckTimeout))
+		}
+		if cfg.HealthcheckTimeoutStartup > 0 {
+			options = append(options, SetHealthcheckTimeoutStartup(cfg.HealthcheckTimeoutStartup))
+		}
+		if cfg.HealthcheckInterval > 0 {
+			options = append(options, SetHealthcheckInterval(cfg.HealthcheckInterval))
+		}
+		if cfg.SnifferTimeout > 0 {
+			options = append(options, SetSnifferTimeout(cfg.SnifferTimeout))
+		}
+		if cfg.SnifferTimeoutStartup > 0 {
+			options = append(options, SetSnifferTimeoutStartup(cfg.SnifferTimeoutStartup))
+		}
+		if cfg.SnifferInterval > 0 {
+			options = append(options, SetSnifferInterval(cfg.SnifferInterval))
+		}
+		httpClient, err := cfg.HTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		if httpClient != nil {
+			options = append(options, SetHttpClient(httpClient))
+		}
 	}
 	return options, nil
 }
@@ -498,6 +610,25 @@ func SetBasicAuth(username, password string) ClientOptionFunc {
 	}
 }
 
+// SetAPIKey specifies the API key to use when making HTTP requests to
+// Elasticsearch, with the id and the key returned by the Create API key API.
+// It takes precedence over the credentials of SetBasicAuth.
+func SetAPIKey(id, key string) ClientOptionFunc {
+	return SetAuthProvider(NewAPIKeyAuth(id, key))
+}
+
+// SetAuthProvider specifies an AuthProvider that returns the credentials
+// for each HTTP request to Elasticsearch, e.g. bearer tokens. If
+// Elasticsearch rejects the credentials with HTTP status 401, the
+// provider is asked to refresh them, and the request is replayed once.
+// It takes precedence over the credentials of SetBasicAuth.
+func SetAuthProvider(provider AuthProvider) ClientOptionFunc {
+	return func(c *Client) error {
+		c.authProvider = provider
+		return nil
+	}
+}
+
 // SetURL defines the URL endpoints of the Elasticsearch nodes. Notice that
 // when sniffing is enabled, these URLs are used to initially sniff the
 // cluster on startup.
@@ -519,6 +650,24 @@ func SetURL(urls ...string) ClientOptionFunc {
 	}
 }
 
+// SetCloudID configures the client to connect to a deployment on
+// Elastic Cloud, given its Cloud ID as shown in the Elastic Cloud console.
+// It sets the URL to the HTTPS endpoint of Elasticsearch, and disables
+// sniffing, as the nodes behind the proxy of Elastic Cloud cannot be
+// reached directly. Use SetSniff after SetCloudID to override that.
+func SetCloudID(cloudID string) ClientOptionFunc {
+	return func(c *Client) error {
+		endpoint, err := decodeCloudID(cloudID)
+		if err != nil {
+			return err
+		}
+		c.urls = []string{endpoint}
+		c.scheme = "https"
+		c.snifferEnabled = false
+		return nil
+	}
+}
+
 // SetScheme sets the HTTP scheme to look for when sniffing (http or https).
 // This is http by default.
 func SetScheme(scheme string) ClientOptionFunc {
@@ -652,6 +801,51 @@ func SetMaxRetries(maxRetries int) ClientOptionFunc {
 	}
 }
 
+// SetResurrectTimeout specifies how long a connection stays dead before
+// a single request is let through to probe the node. The timeout starts
+// with initial and doubles with each consecutive failure of the
+// connection, up to max. The defaults are 60 seconds and 30 minutes
+// (see DefaultResurrectTimeoutInitial and DefaultResurrectTimeoutMax).
+//
+// Notice that health checks, if enabled, will still mark available
+// connections as alive regardless of these timeouts.
+func SetResurrectTimeout(initial, max time.Duration) ClientOptionFunc {
+	return func(c *Client) error {
+		if initial <= 0 || max <= 0 {
+			return errors.New("resurrect timeouts must be greater than 0")
+		}
+		if max < initial {
+			return errors.New("maximum resurrect timeout must be greater than or equal to the initial timeout")
+		}
+		c.resurrectTimeoutInitial = initial
+		c.resurrectTimeoutMax = max
+		return nil
+	}
+}
+
+// SetCircuitBreaker enables a circuit breaker for each connection with
+// the given settings (see NewCircuitBreaker). Circuit breakers are
+// disabled by default. Pass nil to disable them.
+//
+// A connection with an open circuit breaker will not receive requests.
+// Changes of the state are logged to the error log.
+func SetCircuitBreaker(cb *CircuitBreaker) ClientOptionFunc {
+	return func(c *Client) error {
+		c.circuitBreaker = cb
+		return nil
+	}
+}
+
+// SetRequestInterceptors specifies a chain of interceptors that run
+// around every attempt of PerformRequest, including retries. The first
+// interceptor is the outermost one (see RequestInterceptor).
+func SetRequestInterceptors(interceptors ...RequestInterceptor) ClientOptionFunc {
+	return func(c *Client) error {
+		c.requestInterceptors = interceptors
+		return nil
+	}
+}
+
 // SetGzip enables or disables gzip compression (disabled by default).
 func SetGzip(enabled bool) ClientOptionFunc {
 	return func(c *Client) error {
@@ -673,6 +867,19 @@ func SetDecoder(decoder Decoder) ClientOptionFunc {
 	}
 }
 
+// SetEncoder sets the Encoder to use when encoding request bodies sent
+// to Elasticsearch. DefaultEncoder is used by default.
+func SetEncoder(encoder Encoder) ClientOptionFunc {
+	return func(c *Client) error {
+		if encoder != nil {
+			c.encoder = encoder
+		} else {
+			c.encoder = &DefaultEncoder{}
+		}
+		return nil
+	}
+}
+
 // SetRequiredPlugins can be used to indicate that some plugins are required
 // before a Client will be created.
 func SetRequiredPlugins(plugins ...string) ClientOptionFunc {
@@ -712,6 +919,27 @@ func SetTraceLog(logger Logger) ClientOptionFunc {
 	}
 }
 
+// SetLogger sets a structured, leveled logger (see StructuredLogger).
+// It receives all messages of the client, with key/value pairs like the
+// method, path and status of a request. It is used in addition to the
+// loggers of SetErrorLog, SetInfoLog, and SetTraceLog. It is nil by default.
+func SetLogger(logger StructuredLogger) ClientOptionFunc {
+	return func(c *Client) error {
+		c.logger = logger
+		return nil
+	}
+}
+
+// SetObserver sets an Observer that receives events from the internals
+// of the client, e.g. requests, retries, or nodes marked as dead. Use it
+// to collect metrics (see PrometheusCollector). It is nil by default.
+func SetObserver(observer Observer) ClientOptionFunc {
+	return func(c *Client) error {
+		c.observer = observer
+		return nil
+	}
+}
+
 // SetSendGetBodyAs specifies the HTTP method to use when sending a GET request
 // with a body. It is GET by default.
 func SetSendGetBodyAs(httpMethod string) ClientOptionFunc {
@@ -744,6 +972,19 @@ func SetRetryStatusCodes(statusCodes ...int) ClientOptionFunc {
 	}
 }
 
+// SetRetryBudget limits the retries of all requests of the client by
+// the given budget, whichever Retrier decides to retry, including those
+// passed to PerformRequestOptions.Retrier or to the Retrier of a service.
+// Successful requests deposit into the budget. Retries of a BudgetRetrier
+// that uses the same budget are charged only once. Use nil to disable the
+// budget, which is the default.
+func SetRetryBudget(budget *RetryBudget) ClientOptionFunc {
+	return func(c *Client) error {
+		c.retryBudget = budget
+		return nil
+	}
+}
+
 // SetHeaders adds a list of default HTTP headers that will be added to
 // each requests executed by PerformRequest.
 func SetHeaders(headers http.Header) ClientOptionFunc {
@@ -753,6 +994,53 @@ func SetHeaders(headers http.Header) ClientOptionFunc {
 	}
 }
 
+// SetConnectionSelector specifies the strategy to pick the connection
+// for the next request to Elasticsearch. The RoundRobinSelector is used
+// by default.
+func SetConnectionSelector(selector Selector) ClientOptionFunc {
+	return func(c *Client) error {
+		if selector == nil {
+			selector = NewRoundRobinSelector()
+		}
+		c.selector = selector
+		return nil
+	}
+}
+
+// SetNodeFilter specifies which nodes may receive requests. By default,
+// DefaultNodeFilter is used, which sends requests to all nodes except
+// dedicated master nodes.
+// Use AnyNodeFilter to send requests to all nodes.
+//
+// The filter can be overridden per request with
+// PerformRequestOptions.NodeFilter.
+func SetNodeFilter(filter NodeFilter) ClientOptionFunc {
+	return func(c *Client) error {
+		if filter == nil {
+			filter = DefaultNodeFilter
+		}
+		c.nodeFilter = filter
+		return nil
+	}
+}
+
+// SetZoneAwareness enables zone-aware routing. The attribute specifies
+// the node attribute that holds the (availability) zone of a node,
+// e.g. "zone" for nodes started with "node.attr.zone". The localZone is
+// the zone of the caller.
+//
+// With zone-aware routing enabled, requests are sent to the nodes in the
+// local zone only. Nodes in other zones are only used when all nodes
+// of the local zone are dead (or not eligible for the request).
+// Notice that node attributes are only available if sniffing is enabled.
+func SetZoneAwareness(attribute, localZone string) ClientOptionFunc {
+	return func(c *Client) error {
+		c.zoneAttribute = strings.TrimPrefix(attribute, "node.attr.")
+		c.localZone = localZone
+		return nil
+	}
+}
+
 // String returns a string representation of the client status.
 func (c *Client) String() string {
 	c.connsMu.Lock()
@@ -801,7 +1089,7 @@ func (c *Client) Start() {
 	c.running = true
 	c.mu.Unlock()
 
-	c.infof("elastic: client started")
+	c.log(context.Background(), LogLevelInfo, "elastic: client started")
 }
 
 // Stop stops the background processes that the client is running,
@@ -831,46 +1119,28 @@ func (c *Client) Stop() {
 	c.running = false
 	c.mu.Unlock()
 
-	c.infof("elastic: client stopped")
-}
-
-// errorf logs to the error log.
-func (c *Client) errorf(format string, args ...interface{}) {
-	if c.errorlog != nil {
-		c.errorlog.Printf(format, args...)
-	}
-}
-
-// infof logs informational messages.
-func (c *Client) infof(format string, args ...interface{}) {
-	if c.infolog != nil {
-		c.infolog.Printf(format, args...)
-	}
-}
-
-// tracef logs to the trace log.
-func (c *Client) tracef(format string, args ...interface{}) {
-	if c.tracelog != nil {
-		c.tracelog.Printf(format, args...)
-	}
+	c.log(context.Background(), LogLevelInfo, "elastic: client stopped")
 }
 
 // dumpRequest dumps the given HTTP request to the trace log.
-func (c *Client) dumpRequest(r *http.Request) {
-	if c.tracelog != nil {
+func (c *Client) dumpRequest(ctx context.Context, r *http.Request) {
+	if c.logEnabled(ctx, LogLevelTrace) {
 		out, err := httputil.DumpRequestOut(r, true)
 		if err == nil {
-			c.tracef("%s\n", string(out))
+			c.log(ctx, LogLevelTrace, string(out)+"\n",
+				"method", r.Method,
+				"url", r.URL.Redacted())
 		}
 	}
 }
 
 // dumpResponse dumps the given HTTP response to the trace log.
-func (c *Client) dumpResponse(resp *http.Response) {
-	if c.tracelog != nil {
+func (c *Client) dumpResponse(ctx context.Context, resp *http.Response) {
+	if c.logEnabled(ctx, LogLevelTrace) {
 		out, err := httputil.DumpResponse(resp, true)
 		if err == nil {
-			c.tracef("%s\n", string(out))
+			c.log(ctx, LogLevelTrace, string(out)+"\n",
+				"status", resp.StatusCode)
 		}
 	}
 }
@@ -891,6 +1161,8 @@ func (c *Client) sniffer() {
 			// we are asked to stop, so we signal back that we're stopping now
 			c.snifferStop <- true
 			return
+		case <-c.snifferNow:
+			c.sniff(context.Background(), timeout)
 		case <-ticker.C:
 			c.sniff(context.Background(), timeout)
 		}
@@ -902,13 +1174,26 @@ func (c *Client) sniffer() {
 // by the preceding sniffing process (if sniffing is enabled).
 //
 // If sniffing is disabled, this is a no-op.
-func (c *Client) sniff(parentCtx context.Context, timeout time.Duration) error {
+func (c *Client) sniff(parentCtx context.Context, timeout time.Duration) (err error) {
 	c.mu.RLock()
 	if !c.snifferEnabled {
 		c.mu.RUnlock()
 		return nil
 	}
 
+	if c.observer != nil {
+		start := time.Now()
+		defer func() {
+			event := SniffEvent{Duration: time.Since(start), Err: err}
+			if err == nil {
+				c.connsMu.RLock()
+				event.Nodes = len(c.conns)
+				c.connsMu.RUnlock()
+			}
+			c.observer.Sniff(parentCtx, event)
+		}()
+	}
+
 	// Use all available URLs provided to sniff the cluster.
 	var urls []string
 	urlsMap := make(map[string]bool)
@@ -922,6 +1207,7 @@ func (c *Client) sniff(parentCtx context.Context, timeout time.Duration) error {
 
 	// Add all URLs found by sniffing
 	c.connsMu.RLock()
+	gen := c.connsGen
 	for _, conn := range c.conns {
 		if !conn.IsDead() {
 			url := conn.URL()
@@ -951,7 +1237,7 @@ func (c *Client) sniff(parentCtx context.Context, timeout time.Duration) error {
 		select {
 		case conns := <-ch:
 			if len(conns) > 0 {
-				c.updateConns(conns)
+				c.updateConns(conns, gen)
 				return nil
 			}
 		case <-ctx.Done():
@@ -982,10 +1268,13 @@ func (c *Client) sniffNode(ctx context.Context, url string) []*conn {
 	}
 
 	c.mu.RLock()
-	if c.basicAuthUsername != "" || c.basicAuthPassword != "" {
-		req.SetBasicAuth(c.basicAuthUsername, c.basicAuthPassword)
-	}
+	basicAuthUsername := c.basicAuthUsername
+	basicAuthPassword := c.basicAuthPassword
+	authProvider := c.authProvider
 	c.mu.RUnlock()
+	if err := setAuthorization(ctx, (*http.Request)(req), authProvider, basicAuthUsername, basicAuthPassword); err != nil {
+		return nodes
+	}
 
 	if req.Header.Get("User-Agent") == "" {
 		req.Header.Add("User-Agent", "elastic/"+Version+" ("+runtime.GOOS+"-"+runtime.GOARCH+")")
@@ -1005,7 +1294,9 @@ func (c *Client) sniffNode(ctx context.Context, url string) []*conn {
 					if node.HTTP != nil && len(node.HTTP.PublishAddress) > 0 {
 						url := c.extractHostname(c.scheme, node.HTTP.PublishAddress)
 						if url != "" {
-							nodes = append(nodes, newConn(nodeID, url))
+							conn := newConn(nodeID, url)
+							conn.setNodeInfo(node.Roles, node.Attributes)
+							nodes = append(nodes, conn)
 						}
 					}
 				}
@@ -1036,9 +1327,15 @@ func (c *Client) extractHostname(scheme, address string) string {
 }
 
 // updateConns updates the clients' connections with new information
-// gather by a sniff operation.
-func (c *Client) updateConns(conns []*conn) {
+// gather by a sniff operation. The connections are discarded if the
+// URLs have been reloaded since gen, as they may belong to the old
+// cluster.
+func (c *Client) updateConns(conns []*conn, gen uint64) {
 	c.connsMu.Lock()
+	if c.connsGen != gen {
+		c.connsMu.Unlock()
+		return
+	}
 
 	// Build up new connections:
 	// If we find an existing connection, use that (including no. of failures etc.).
@@ -1050,7 +1347,9 @@ func (c *Client) updateConns(conns []*conn) {
 			// Notice that e.g. in a Kubernetes cluster the NodeID might be
 			// stable while the URL has changed.
 			if oldConn.NodeID() == conn.NodeID() && oldConn.URL() == conn.URL() {
-				// Take over the old connection
+				// Take over the old connection, but pick up changes in roles
+				// and attributes of the node.
+				oldConn.setNodeInfo(conn.Roles(), conn.Attributes())
 				newConns = append(newConns, oldConn)
 				found = true
 				break
@@ -1058,13 +1357,13 @@ func (c *Client) updateConns(conns []*conn) {
 		}
 		if !found {
 			// New connection didn't exist, so add it to our list of new conns.
-			c.infof("elastic: %s joined the cluster", conn.URL())
+			c.log(context.Background(), LogLevelInfo, fmt.Sprintf("elastic: %s joined the cluster", conn.URL()),
+				"node", conn.URL())
 			newConns = append(newConns, conn)
 		}
 	}
 
 	c.conns = newConns
-	c.cindex = -1
 	c.connsMu.Unlock()
 }
 
@@ -1084,6 +1383,8 @@ func (c *Client) healthchecker() {
 			// we are asked to stop, so we signal back that we're stopping now
 			c.healthcheckStop <- true
 			return
+		case <-c.healthcheckNow:
+			c.healthcheck(context.Background(), timeout, false)
 		case <-ticker.C:
 			c.healthcheck(context.Background(), timeout, false)
 		}
@@ -1101,9 +1402,9 @@ func (c *Client) healthcheck(parentCtx context.Context, timeout time.Duration, f
 		return
 	}
 	headers := c.headers
-	basicAuth := c.basicAuthUsername != "" || c.basicAuthPassword != ""
 	basicAuthUsername := c.basicAuthUsername
 	basicAuthPassword := c.basicAuthPassword
+	authProvider := c.authProvider
 	c.mu.RUnlock()
 
 	c.connsMu.RLock()
@@ -1117,6 +1418,7 @@ func (c *Client) healthcheck(parentCtx context.Context, timeout time.Duration, f
 
 		// Goroutine executes the HTTP request, returns an error and sets status
 		var status int
+		checkStart := time.Now()
 		errc := make(chan error, 1)
 		go func(url string) {
 			req, err := NewRequest("HEAD", url)
@@ -1124,8 +1426,9 @@ func (c *Client) healthcheck(parentCtx context.Context, timeout time.Duration, f
 				errc <- err
 				return
 			}
-			if basicAuth {
-				req.SetBasicAuth(basicAuthUsername, basicAuthPassword)
+			if err := setAuthorization(ctx, (*http.Request)(req), authProvider, basicAuthUsername, basicAuthPassword); err != nil {
+				errc <- err
+				return
 			}
 			if len(headers) > 0 {
 				for key, values := range headers {
@@ -1150,20 +1453,29 @@ func (c *Client) healthcheck(parentCtx context.Context, timeout time.Duration, f
 		// Wait for the Goroutine (or its timeout)
 		select {
 		case <-ctx.Done(): // timeout
-			c.errorf("elastic: %s is dead", conn.URL())
-			conn.MarkAsDead()
+			c.log(ctx, LogLevelError, fmt.Sprintf("elastic: %s is dead", conn.URL()),
+				"node", conn.URL(),
+				"error", ctx.Err())
+			c.markConnDead(ctx, conn)
+			c.observeHealthcheck(ctx, conn, checkStart, 0, ctx.Err())
 		case err := <-errc:
 			if err != nil {
-				c.errorf("elastic: %s is dead", conn.URL())
-				conn.MarkAsDead()
+				c.log(ctx, LogLevelError, fmt.Sprintf("elastic: %s is dead", conn.URL()),
+					"node", conn.URL(),
+					"error", err)
+				c.markConnDead(ctx, conn)
+				c.observeHealthcheck(ctx, conn, checkStart, status, err)
 				break
 			}
 			if status >= 200 && status < 300 {
-				conn.MarkAsAlive()
+				c.markConnAlive(ctx, conn)
 			} else {
-				conn.MarkAsDead()
-				c.errorf("elastic: %s is dead [status=%d]", conn.URL(), status)
+				c.markConnDead(ctx, conn)
+				c.log(ctx, LogLevelError, fmt.Sprintf("elastic: %s is dead [status=%d]", conn.URL(), status),
+					"node", conn.URL(),
+					"status", status)
 			}
+			c.observeHealthcheck(ctx, conn, checkStart, status, nil)
 		}
 	}
 }
@@ -1174,9 +1486,9 @@ func (c *Client) startupHealthcheck(parentCtx context.Context, timeout time.Dura
 	c.mu.Lock()
 	urls := c.urls
 	headers := c.headers
-	basicAuth := c.basicAuthUsername != "" || c.basicAuthPassword != ""
 	basicAuthUsername := c.basicAuthUsername
 	basicAuthPassword := c.basicAuthPassword
+	authProvider := c.authProvider
 	c.mu.Unlock()
 
 	// If we don't get a connection after "timeout", we bail.
@@ -1189,8 +1501,8 @@ func (c *Client) startupHealthcheck(parentCtx context.Context, timeout time.Dura
 			if err != nil {
 				return err
 			}
-			if basicAuth {
-				req.SetBasicAuth(basicAuthUsername, basicAuthPassword)
+			if err := setAuthorization(parentCtx, req, authProvider, basicAuthUsername, basicAuthPassword); err != nil {
+				return err
 			}
 			if len(headers) > 0 {
 				for key, values := range headers {
@@ -1209,6 +1521,10 @@ func (c *Client) startupHealthcheck(parentCtx context.Context, timeout time.Dura
 				return nil
 			} else if res.StatusCode == http.StatusUnauthorized {
 				lastErr = &Error{Status: res.StatusCode}
+				if authProvider != nil {
+					// Try again with new credentials, if possible
+					authProvider.Refresh(parentCtx)
+				}
 			}
 		}
 		select {
@@ -1231,40 +1547,128 @@ func (c *Client) startupHealthcheck(parentCtx context.Context, timeout time.Dura
 }
 
 // next returns the next available connection, or ErrNoClient.
+// It uses the node filter of the client.
 func (c *Client) next() (*conn, error) {
-	// We do round-robin here.
-	// TODO(oe) This should be a pluggable strategy, like the Selector in the official clients.
+	return c.nextMatching(nil)
+}
+
+// nextMatching returns the next available connection that is accepted
+// by the given filter, or ErrNoClient. If filter is nil, the node filter
+// of the client is used (see SetNodeFilter). The connection is picked
+// from all matching connections that are not marked as dead by the
+// Selector of the client (see SetConnectionSelector).
+//
+// A dead connection whose resurrection timeout has passed is returned
+// as a probe instead, so that a single request can test whether the
+// node is available again (see SetResurrectTimeout).
+func (c *Client) nextMatching(filter NodeFilter) (*conn, error) {
+	c.mu.RLock()
+	selector := c.selector
+	if filter == nil {
+		filter = c.nodeFilter
+	}
+	zoneAttribute := c.zoneAttribute
+	localZone := c.localZone
+	resurrectInitial := c.resurrectTimeoutInitial
+	resurrectMax := c.resurrectTimeoutMax
+	cb := c.circuitBreaker
+	c.mu.RUnlock()
+
+	// Notify about changes of circuit breakers after releasing the lock
+	var notify func()
+	defer func() {
+		if notify != nil {
+			notify()
+		}
+	}()
+
 	c.connsMu.Lock()
 	defer c.connsMu.Unlock()
 
-	i := 0
-	numConns := len(c.conns)
-	for {
-		i++
-		if i > numConns {
-			break // we visited all conns: they all seem to be dead
+	// acquire passes the request through the circuit breaker of the connection
+	acquire := func(conn *conn) *conn {
+		if cb != nil {
+			if from, to, changed := conn.breakerAcquire(cb, time.Now()); changed {
+				notify = func() { c.circuitBreakerChanged(cb, conn, from, to) }
+			}
+		}
+		return conn
+	}
+
+	now := time.Now().UTC()
+	var numAlive, numBlocked int
+	var dead []*conn
+	live := make([]Connection, 0, len(c.conns))
+	for _, conn := range c.conns {
+		isDead := conn.IsDead()
+		if !isDead {
+			numAlive++
 		}
-		c.cindex++
-		if c.cindex >= numConns {
-			c.cindex = 0
+		if !filter(conn) {
+			continue
 		}
-		conn := c.conns[c.cindex]
-		if !conn.IsDead() {
-			return conn, nil
+		if cb != nil && !conn.breakerAvailable(cb, now) {
+			numBlocked++
+			continue
+		}
+		if isDead {
+			dead = append(dead, conn)
+		} else {
+			live = append(live, conn)
 		}
 	}
 
-	// We have a deadlock here: All nodes are marked as dead.
-	// If sniffing is disabled, connections will never be marked alive again.
-	// So we are marking them as alive--if sniffing is disabled.
-	// They'll then be picked up in the next call to PerformRequest.
-	if !c.snifferEnabled {
-		c.errorf("elastic: all %d nodes marked as dead; resurrecting them to prevent deadlock", len(c.conns))
-		for _, conn := range c.conns {
-			conn.MarkAsAlive()
+	// Prefer the local zone, but fall back to all zones
+	preferZone := zoneAttribute != "" && localZone != ""
+	var liveLocal []Connection
+	if preferZone {
+		liveLocal = connsInZone(live, zoneAttribute, localZone)
+	}
+
+	// Let a single request through to a dead connection that is due.
+	// Dead connections in the local zone are probed first, and those in
+	// other zones only if there is no live connection in the local zone.
+	if preferZone {
+		sort.SliceStable(dead, func(i, j int) bool {
+			return dead[i].Attributes()[zoneAttribute] == localZone && dead[j].Attributes()[zoneAttribute] != localZone
+		})
+	}
+	for _, conn := range dead {
+		if len(liveLocal) > 0 && conn.Attributes()[zoneAttribute] != localZone {
+			continue
+		}
+		if conn.TryResurrect(now, resurrectInitial, resurrectMax) {
+			c.log(context.Background(), LogLevelInfo, fmt.Sprintf("elastic: probing %s after it has been marked as dead", conn.URL()),
+				"node", conn.URL())
+			return acquire(conn), nil
 		}
 	}
 
+	if len(live) > 0 {
+		if len(liveLocal) > 0 {
+			live = liveLocal
+		}
+		selected, err := selector.Select(live)
+		if err != nil {
+			return nil, err
+		}
+		if conn, ok := selected.(*conn); ok && conn != nil {
+			return acquire(conn), nil
+		}
+		return nil, errors.Wrap(ErrNoClient, "selector returned an unknown connection")
+	}
+	if numAlive > 0 {
+		// There are nodes available, but none of them is eligible
+		if numBlocked > 0 {
+			return nil, errors.Wrap(ErrNoClient, "circuit breakers of all eligible connections are open")
+		}
+		return nil, errors.Wrap(ErrNoClient, "no available connection matches the node filter")
+	}
+
+	// All nodes are marked as dead, and none of them is due for a probe.
+	// Even if sniffing is disabled, they are probed once their resurrection
+	// time has passed (see SetResurrectTimeout), so fail fast until then
+	// rather than sending every request to a dead node.
 	// We tried hard, but there is no node available
 	return nil, errors.Wrap(ErrNoClient, "no available connection")
 }
@@ -1298,6 +1702,8 @@ type PerformRequestOptions struct {
 	Headers          http.Header
 	MaxResponseSize  int64
 	Stream           bool
+	NodeFilter       NodeFilter  // overrides the node filter of the client (see SetNodeFilter)
+	Hedge            HedgePolicy // enables hedged requests for idempotent requests (see HedgePolicy)
 }
 
 // PerformRequest does a HTTP request to Elasticsearch.
@@ -1308,15 +1714,30 @@ type PerformRequestOptions struct {
 // valid outcome (Exists, IndicesExists, IndicesTypeExists).
 //
 // If Stream is set, the returned BodyReader field must be closed, even
-// if PerformRequest returns an error.
+// if PerformRequest returns an error. If MaxResponseSize is set as well,
+// reading more than MaxResponseSize bytes from BodyReader returns
+// ErrResponseSize.
+//
+// If Hedge is set and the request is idempotent, a duplicate request
+// is sent to a different node if no response arrives in time (see
+// HedgePolicy).
 func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
+	if opt.Hedge != nil && !opt.Stream && isIdempotentRequest(opt.Method, opt.Path) {
+		return c.performHedgedRequest(ctx, opt)
+	}
+	return c.performRequest(ctx, opt, nil)
+}
+
+// performRequest does a HTTP request to Elasticsearch. If connUsed is
+// not nil, it is called with each connection that a request is sent to.
+func (c *Client) performRequest(ctx context.Context, opt PerformRequestOptions, connUsed func(*conn)) (*Response, error) {
 	start := time.Now().UTC()
 
 	c.mu.RLock()
 	timeout := c.healthcheckTimeout
-	basicAuth := c.basicAuthUsername != "" || c.basicAuthPassword != ""
 	basicAuthUsername := c.basicAuthUsername
 	basicAuthPassword := c.basicAuthPassword
+	authProvider := c.authProvider
 	sendGetBodyAs := c.sendGetBodyAs
 	gzipEnabled := c.gzipEnabled
 	healthcheckEnabled := c.healthcheckEnabled
@@ -1328,7 +1749,14 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 	if opt.RetryStatusCodes != nil {
 		retryStatusCodes = opt.RetryStatusCodes
 	}
+	budget := c.retryBudget
+	if budget != nil && retrierUsesBudget(retrier, budget) {
+		budget = nil // the retrier already withdraws from and deposits into it
+	}
 	defaultHeaders := c.headers
+	cb := c.circuitBreaker
+	interceptors := c.requestInterceptors
+	compatible := c.compatible
 	c.mu.RUnlock()
 
 	// retry returns true if statusCode indicates the request is to be retried
@@ -1341,11 +1769,19 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 		return false
 	}
 
-	var err error
-	var conn *conn
-	var req *Request
-	var resp *Response
+	// withdraw returns true if the retry budget allows another retry
+	withdraw := func(ctx context.Context, opt PerformRequestOptions) bool {
+		if budget == nil || budget.TryWithdraw() {
+			return true
+		}
+		c.log(ctx, LogLevelWarn, fmt.Sprintf("elastic: retry budget exhausted; not retrying %s %s", strings.ToUpper(opt.Method), opt.Path),
+			"method", strings.ToUpper(opt.Method),
+			"path", opt.Path)
+		return false
+	}
+
 	var retried bool
+	var authRefreshed bool // true if the credentials have been refreshed after HTTP status 401
 	var n int
 
 	// Change method if sendGetBodyAs is specified.
@@ -1353,14 +1789,16 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 		opt.Method = sendGetBodyAs
 	}
 
-	for {
+	// attempt performs a single attempt of the request. It returns a
+	// *retryError if the request is to be retried.
+	attempt := func(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
 		pathWithParams := opt.Path
 		if len(opt.Params) > 0 {
 			pathWithParams += "?" + opt.Params.Encode()
 		}
 
 		// Get a connection
-		conn, err = c.next()
+		conn, err := c.nextMatching(opt.NodeFilter)
 		if errors.Cause(err) == ErrNoClient {
 			n++
 			if !retried {
@@ -1368,32 +1806,46 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 				c.healthcheck(ctx, timeout, false)
 				if healthcheckEnabled {
 					retried = true
-					continue
+					return nil, &retryError{err: err}
 				}
 			}
-			wait, ok, rerr := retrier.Retry(ctx, n, nil, nil, err)
+			w, ok, rerr := retrier.Retry(ctx, n, nil, nil, err)
 			if rerr != nil {
 				return nil, rerr
 			}
-			if !ok {
+			if !ok || !withdraw(ctx, opt) {
 				return nil, err
 			}
 			retried = true
-			time.Sleep(wait)
-			continue // try again
+			return nil, &retryError{wait: w, err: err} // try again
 		}
 		if err != nil {
-			c.errorf("elastic: cannot get connection from pool")
+			c.log(ctx, LogLevelError, "elastic: cannot get connection from pool",
+				"method", strings.ToUpper(opt.Method),
+				"path", opt.Path,
+				"error", err)
 			return nil, err
 		}
+		if connUsed != nil {
+			connUsed(conn)
+		}
 
-		req, err = NewRequest(opt.Method, conn.URL()+pathWithParams)
+		req, err := NewRequest(opt.Method, conn.URL()+pathWithParams)
 		if err != nil {
-			c.errorf("elastic: cannot create request for %s %s: %v", strings.ToUpper(opt.Method), conn.URL()+pathWithParams, err)
+			c.log(ctx, LogLevelError, fmt.Sprintf("elastic: cannot create request for %s %s: %v", strings.ToUpper(opt.Method), conn.URL()+pathWithParams, err),
+				"method", strings.ToUpper(opt.Method),
+				"path", opt.Path,
+				"node", conn.URL(),
+				"error", err)
 			return nil, err
 		}
-		if basicAuth {
-			req.SetBasicAuth(basicAuthUsername, basicAuthPassword)
+		if err := setAuthorization(ctx, (*http.Request)(req), authProvider, basicAuthUsername, basicAuthPassword); err != nil {
+			c.log(ctx, LogLevelError, fmt.Sprintf("elastic: cannot get credentials for request: %v", err),
+				"method", strings.ToUpper(opt.Method),
+				"path", opt.Path,
+				"node", conn.URL(),
+				"error", err)
+			return nil, err
 		}
 		if opt.ContentType != "" {
 			req.Header.Set("Content-Type", opt.ContentType)
@@ -1416,97 +1868,240 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 
 		// Set body
 		if opt.Body != nil {
-			err = req.SetBody(opt.Body, gzipEnabled)
+			err = req.setBody(opt.Body, gzipEnabled, c.encoder)
 			if err != nil {
-				c.errorf("elastic: couldn't set body %+v for request: %v", opt.Body, err)
+				c.log(ctx, LogLevelError, fmt.Sprintf("elastic: couldn't set body %+v for request: %v", opt.Body, err),
+					"method", strings.ToUpper(opt.Method),
+					"path", opt.Path,
+					"node", conn.URL(),
+					"error", err)
 				return nil, err
 			}
 		}
+		if compatible {
+			setCompatibilityHeaders(req.Header)
+		}
 
 		// Tracing
-		c.dumpRequest((*http.Request)(req))
+		c.dumpRequest(ctx, (*http.Request)(req))
 
 		// Get response
+		if c.observer != nil {
+			c.observer.RequestStart(ctx, RequestStartEvent{
+				Method:  strings.ToUpper(opt.Method),
+				Path:    opt.Path,
+				Node:    conn.URL(),
+				Attempt: n + 1,
+			})
+		}
+		conn.startRequest()
+		roundTripStart := time.Now()
 		res, err := c.c.Do((*http.Request)(req).WithContext(ctx))
+		took := time.Since(roundTripStart)
+		conn.finishRequest(took, err == nil)
+		if c.observer != nil {
+			event := RequestFinishEvent{
+				Method:   strings.ToUpper(opt.Method),
+				Path:     opt.Path,
+				Node:     conn.URL(),
+				Attempt:  n + 1,
+				Duration: took,
+				Err:      err,
+			}
+			if res != nil {
+				event.StatusCode = res.StatusCode
+			}
+			c.observer.RequestFinish(ctx, event)
+		}
+		if cb != nil && !IsContextErr(err) {
+			if from, to, changed := conn.breakerRecord(cb, time.Now(), cb.isFailure(res, err)); changed {
+				c.circuitBreakerChanged(cb, conn, from, to)
+			}
+		}
 		if IsContextErr(err) {
 			// Proceed, but don't mark the node as dead
 			return nil, err
 		}
+		// markAsDead logs and marks the connection as dead
+		markAsDead := func(err error) {
+			c.log(ctx, LogLevelError, fmt.Sprintf("elastic: %s is dead", conn.URL()),
+				"method", strings.ToUpper(opt.Method),
+				"path", opt.Path,
+				"node", conn.URL(),
+				"attempt", n,
+				"error", err)
+			c.markConnDead(ctx, conn)
+		}
+		// logRetry logs that the request is retried, and notifies the observer
+		logRetry := func(wait time.Duration, keyvals ...interface{}) {
+			if c.observer != nil {
+				event := RetryEvent{
+					Method:  strings.ToUpper(opt.Method),
+					Path:    opt.Path,
+					Node:    conn.URL(),
+					Attempt: n + 1,
+					Wait:    wait,
+				}
+				if res != nil {
+					event.StatusCode = res.StatusCode
+				} else {
+					event.Err = err
+				}
+				c.observer.Retry(ctx, event)
+			}
+			if c.logEnabled(ctx, LogLevelDebug) {
+				c.log(ctx, LogLevelDebug, fmt.Sprintf("elastic: retrying %s %s in %v [attempt:%d]", strings.ToUpper(opt.Method), opt.Path, wait, n+1),
+					append([]interface{}{
+						"method", strings.ToUpper(opt.Method),
+						"path", opt.Path,
+						"node", conn.URL(),
+						"attempt", n + 1,
+						"wait", wait,
+					}, keyvals...)...)
+			}
+		}
 		if err != nil {
 			n++
-			wait, ok, rerr := retrier.Retry(ctx, n, (*http.Request)(req), res, err)
+			w, ok, rerr := retrier.Retry(ctx, n, (*http.Request)(req), res, err)
 			if rerr != nil {
-				c.errorf("elastic: %s is dead", conn.URL())
-				conn.MarkAsDead()
+				markAsDead(rerr)
 				return nil, rerr
 			}
-			if !ok {
-				c.errorf("elastic: %s is dead", conn.URL())
-				conn.MarkAsDead()
+			if !ok || !withdraw(ctx, opt) {
+				markAsDead(err)
 				return nil, err
 			}
+			logRetry(w, "error", err)
 			retried = true
-			time.Sleep(wait)
-			continue // try again
+			return nil, &retryError{wait: w, err: err} // try again
+		}
+		if res.StatusCode == http.StatusUnauthorized && authProvider != nil && !authRefreshed {
+			// Replay the request once with fresh credentials
+			authRefreshed = true
+			rerr := authProvider.Refresh(ctx)
+			if rerr == nil {
+				err = createResponseError(res)
+				res.Body.Close()
+				c.log(ctx, LogLevelInfo, fmt.Sprintf("elastic: credentials refreshed; replaying %s %s", strings.ToUpper(opt.Method), opt.Path),
+					"method", strings.ToUpper(opt.Method),
+					"path", opt.Path,
+					"node", conn.URL())
+				return nil, &retryError{err: err} // try again
+			}
+			if rerr != ErrAuthNotRefreshable {
+				c.log(ctx, LogLevelError, fmt.Sprintf("elastic: cannot refresh credentials: %v", rerr),
+					"method", strings.ToUpper(opt.Method),
+					"path", opt.Path,
+					"node", conn.URL(),
+					"error", rerr)
+			}
 		}
 		if retry(res.StatusCode) {
 			n++
-			wait, ok, rerr := retrier.Retry(ctx, n, (*http.Request)(req), res, err)
+			w, ok, rerr := retrier.Retry(ctx, n, (*http.Request)(req), res, err)
 			if rerr != nil {
-				c.errorf("elastic: %s is dead", conn.URL())
-				conn.MarkAsDead()
+				res.Body.Close()
+				markAsDead(rerr)
 				return nil, rerr
 			}
-			if ok {
+			if ok && withdraw(ctx, opt) {
 				// retry
+				err = createResponseError(res)
+				res.Body.Close()
+				logRetry(w, "status", res.StatusCode)
 				retried = true
-				time.Sleep(wait)
-				continue // try again
+				return nil, &retryError{wait: w, err: err} // try again
 			}
 		}
 
+		// Notify the retrier that we got a response that won't be retried
+		if observer, ok := retrier.(RetryObserver); ok {
+			observer.Success(ctx, (*http.Request)(req), res)
+		}
+		if budget != nil && res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+			budget.Deposit()
+		}
+
 		if !opt.Stream {
 			defer res.Body.Close()
 		}
 
 		// Tracing
-		c.dumpResponse(res)
+		c.dumpResponse(ctx, res)
 
 		// Log deprecation warnings as errors
 		if len(res.Header["Warning"]) > 0 {
 			c.deprecationlog((*http.Request)(req), res)
-			for _, warning := range res.Header["Warning"] {
-				c.errorf("Deprecation warning: %s", warning)
-			}
+			c.handleDeprecations(ctx, (*http.Request)(req), parseWarnings(res.Header))
 		}
 
 		// Check for errors
 		if err := checkResponse((*http.Request)(req), res, opt.IgnoreErrors...); err != nil {
 			// No retry if request succeeded
 			// We still try to return a response.
-			resp, _ = c.newResponse(res, opt.MaxResponseSize, opt.Stream)
+			resp, _ := c.newResponse(res, opt.MaxResponseSize, opt.Stream)
 			return resp, err
 		}
 
 		// We successfully made a request with this connection
-		conn.MarkAsHealthy()
+		c.markConnHealthy(ctx, conn)
 
-		resp, err = c.newResponse(res, opt.MaxResponseSize, opt.Stream)
+		resp, err := c.newResponse(res, opt.MaxResponseSize, opt.Stream)
 		if err != nil {
 			return nil, err
 		}
 
-		break
+		if c.logEnabled(ctx, LogLevelInfo) {
+			duration := time.Now().UTC().Sub(start)
+			c.log(ctx, LogLevelInfo, fmt.Sprintf("%s %s [status:%d, request:%.3fs]",
+				strings.ToUpper(opt.Method),
+				req.URL.Redacted(),
+				resp.StatusCode,
+				float64(int64(duration/time.Millisecond))/1000),
+				"method", strings.ToUpper(opt.Method),
+				"path", opt.Path,
+				"url", req.URL.Redacted(),
+				"node", conn.URL(),
+				"status", resp.StatusCode,
+				"duration", duration,
+				"attempt", n+1)
+		}
+
+		return resp, nil
 	}
 
-	duration := time.Now().UTC().Sub(start)
-	c.infof("%s %s [status:%d, request:%.3fs]",
-		strings.ToUpper(opt.Method),
-		req.URL.Redacted(),
-		resp.StatusCode,
-		float64(int64(duration/time.Millisecond))/1000)
+	// Run every attempt through the chain of interceptors
+	handler := chainRequestInterceptors(interceptors, attempt)
+	for {
+		attemptOpt := opt
+		if len(interceptors) > 0 {
+			// Interceptors must not change the options of other attempts
+			attemptOpt = opt.clone()
+		}
+		resp, err := handler(ctx, attemptOpt)
+		var retry *retryError
+		if !errors.As(err, &retry) {
+			return resp, err
+		}
+		if err := sleepContext(ctx, retry.wait); err != nil {
+			return nil, err
+		}
+	}
+}
 
-	return resp, nil
+// sleepContext waits for the given duration, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 // -- Document APIs --
@@ -1634,6 +2229,11 @@ func (c *Client) Scroll(indices ...string) *ScrollService {
 	return NewScrollService(c).Index(indices...)
 }
 
+// SlicedScan reads all documents matching a search in parallel slices.
+func (c *Client) SlicedScan(indices ...string) *SlicedScanService {
+	return NewSlicedScanService(c).Index(indices...)
+}
+
 // ClearScroll can be used to clear search contexts manually.
 func (c *Client) ClearScroll(scrollIds ...string) *ClearScrollService {
 	return NewClearScrollService(c).ScrollId(scrollIds...)
@@ -276,6 +276,92 @@ func TestClientFromConfig(t *testing.T) {
 	}
 }
 
+func TestClientOptionsFromConfig(t *testing.T) {
+	cfg, err := config.ParseJSON([]byte(`{
+  "urls": ["http://es1:9200", "http://es2:9200"],
+  "sniff": false,
+  "healthcheck": false,
+  "gzip": true,
+  "headers": {"X-Opaque-Id": "my-app"},
+  "retry_initial_timeout": "100ms",
+  "retry_max_timeout": "5s",
+  "retry_status_codes": [429],
+  "healthcheck_interval": "30s",
+  "sniffer_timeout": "2s",
+  "timeout": "1m"
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := configToOptions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSimpleClient(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"http://es1:9200", "http://es2:9200"}, client.urls; !reflect.DeepEqual(want, have) {
+		t.Fatalf("expected urls = %v; got: %v", want, have)
+	}
+	if !client.gzipEnabled {
+		t.Fatal("expected gzip to be enabled")
+	}
+	if want, have := "my-app", client.headers.Get("X-Opaque-Id"); want != have {
+		t.Fatalf("expected header X-Opaque-Id = %q; got: %q", want, have)
+	}
+	if _, ok := client.retrier.(*BackoffRetrier); !ok {
+		t.Fatalf("expected retrier of type *BackoffRetrier; got: %T", client.retrier)
+	}
+	if want, have := []int{429}, client.retryStatusCodes; !reflect.DeepEqual(want, have) {
+		t.Fatalf("expected retry status codes = %v; got: %v", want, have)
+	}
+	if want, have := 30*time.Second, client.healthcheckInterval; want != have {
+		t.Fatalf("expected healthcheck interval = %v; got: %v", want, have)
+	}
+	if want, have := 2*time.Second, client.snifferTimeout; want != have {
+		t.Fatalf("expected sniffer timeout = %v; got: %v", want, have)
+	}
+	httpClient, ok := client.c.(*http.Client)
+	if !ok || httpClient == http.DefaultClient {
+		t.Fatalf("expected a custom http.Client; got: %v", client.c)
+	}
+	if want, have := time.Minute, httpClient.Timeout; want != have {
+		t.Fatalf("expected timeout = %v; got: %v", want, have)
+	}
+}
+
+func TestClientOptionsFromConfigWithMaxRetriesAndBackoff(t *testing.T) {
+	cfg, err := config.ParseJSON([]byte(`{
+  "urls": ["http://es1:9200"],
+  "sniff": false,
+  "healthcheck": false,
+  "max_retries": 2,
+  "retry_initial_timeout": "100ms",
+  "retry_max_timeout": "5s"
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := configToOptions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSimpleClient(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for retry := 1; retry <= 3; retry++ {
+		_, ok, err := client.retrier.Retry(context.Background(), retry, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := retry <= 2, ok; want != have {
+			t.Fatalf("retry %d: expected ok = %v; got: %v", retry, want, have)
+		}
+	}
+}
+
 func TestClientDialFromConfig(t *testing.T) {
 	cfg, err := config.Parse("http://127.0.0.1:9200")
 	if err != nil {
@@ -406,9 +492,10 @@ func TestClientSniffDisabled(t *testing.T) {
 	}
 }
 
-func TestClientWillMarkConnectionsAsAliveWhenAllAreDead(t *testing.T) {
+func TestClientWillProbeConnectionsWhenAllAreDead(t *testing.T) {
 	client, err := NewClient(SetURL("http://127.0.0.1:9201"),
-		SetSniff(false), SetHealthcheck(false), SetMaxRetries(0))
+		SetSniff(false), SetHealthcheck(false), SetMaxRetries(0),
+		SetResurrectTimeout(50*time.Millisecond, 200*time.Millisecond))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -429,14 +516,29 @@ func TestClientWillMarkConnectionsAsAliveWhenAllAreDead(t *testing.T) {
 		}
 	}
 
-	// Now send another request and the connection should be marked as alive again.
+	// Another request fails fast without touching the dead connection
+	if _, err := client.Flush().Do(context.TODO()); !IsConnErr(err) {
+		t.Fatalf("expected connection error; got: %v", err)
+	}
+
+	// After the resurrect timeout, the request is sent to the dead
+	// connection to probe it. As the node is still unavailable, the
+	// failures counter increases.
+	time.Sleep(60 * time.Millisecond)
 	client.Flush().Do(context.TODO())
 
 	if i, found := findConn("http://127.0.0.1:9201", client.conns...); !found {
 		t.Fatalf("expected connection to %q to be found", "http://127.0.0.1:9201")
 	} else {
-		if conn := client.conns[i]; conn.IsDead() {
-			t.Fatalf("expected connection to be alive, got: %v", conn)
+		conn := client.conns[i]
+		if !conn.IsDead() {
+			t.Fatalf("expected connection to be dead, got: %v", conn)
+		}
+		conn.RLock()
+		failures := conn.failures
+		conn.RUnlock()
+		if want, have := 2, failures; want != have {
+			t.Fatalf("expected %d failures; got: %d", want, have)
 		}
 	}
 }
@@ -1060,6 +1162,7 @@ func TestClientSelectConnAllDead(t *testing.T) {
 	client, err := NewClient(
 		SetSniff(false),
 		SetHealthcheck(false),
+		SetResurrectTimeout(50*time.Millisecond, 200*time.Millisecond),
 		SetURL("http://127.0.0.1:9200", "http://127.0.0.1:9201"))
 	if err != nil {
 		t.Fatal(err)
@@ -1069,30 +1172,141 @@ func TestClientSelectConnAllDead(t *testing.T) {
 	client.conns[0].MarkAsDead()
 	client.conns[1].MarkAsDead()
 
-	// If all connections are dead, next should make them alive again, but
-	// still return an error when it first finds out.
+	// If all connections are dead, next returns an error until one of
+	// them is due for a probe.
 	c, err := client.next()
 	if !IsConnErr(err) {
-		t.Fatal(err)
+		t.Fatalf("expected connection error; got: %v", err)
 	}
 	if c != nil {
 		t.Fatalf("expected no connection; got: %v", c)
 	}
-	// Return a connection
+
+	// After the resurrect timeout, every connection gets a single probe
+	time.Sleep(60 * time.Millisecond)
+	probed := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		c, err = client.next()
+		if err != nil {
+			t.Fatalf("expected no error; got: %v", err)
+		}
+		if !c.IsDead() {
+			t.Fatalf("expected probed connection to still be dead")
+		}
+		probed[c.URL()] = true
+	}
+	if want, have := 2, len(probed); want != have {
+		t.Fatalf("expected %d probed connections; got: %d", want, have)
+	}
+
+	// A second call inside the timeout gets no connection
 	c, err = client.next()
+	if !IsConnErr(err) {
+		t.Fatalf("expected connection error; got: %v", err)
+	}
+	if c != nil {
+		t.Fatalf("expected no connection; got: %v", c)
+	}
+
+	// A successful request marks the connection as healthy
+	client.conns[0].MarkAsHealthy()
+	for i := 0; i < 2; i++ {
+		next, err := client.next()
+		if err != nil {
+			t.Fatalf("expected no error; got: %v", err)
+		}
+		if want, have := client.conns[0].URL(), next.URL(); want != have {
+			t.Fatalf("expected %s; got: %s", want, have)
+		}
+	}
+}
+
+func TestClientSelectConnResurrectsDeadConnAfterTimeout(t *testing.T) {
+	client, err := NewClient(
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetResurrectTimeout(50*time.Millisecond, 200*time.Millisecond),
+		SetURL("http://127.0.0.1:9200", "http://127.0.0.1:9201"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.conns[0].MarkAsHealthy()
+	client.conns[1].MarkAsDead()
+
+	// Dead connection is skipped until its resurrection time has passed
+	c, err := client.next()
 	if err != nil {
-		t.Fatalf("expected no error; got: %v", err)
+		t.Fatal(err)
 	}
-	if c == nil {
-		t.Fatalf("expected connection; got: %v", c)
+	if want, have := client.conns[0].URL(), c.URL(); want != have {
+		t.Fatalf("expected %s; got: %s", want, have)
 	}
-	// Return a connection
+
+	// After that, a single request is let through
+	time.Sleep(60 * time.Millisecond)
 	c, err = client.next()
 	if err != nil {
-		t.Fatalf("expected no error; got: %v", err)
+		t.Fatal(err)
+	}
+	if want, have := client.conns[1].URL(), c.URL(); want != have {
+		t.Fatalf("expected probe of %s; got: %s", want, have)
+	}
+	for i := 0; i < 3; i++ {
+		c, err = client.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := client.conns[0].URL(), c.URL(); want != have {
+			t.Fatalf("#%d: expected %s; got: %s", i, want, have)
+		}
+	}
+}
+
+func TestConnResurrectTimeout(t *testing.T) {
+	tests := []struct {
+		Failures int
+		Expected time.Duration
+	}{
+		{0, 1 * time.Second},
+		{1, 1 * time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, test := range tests {
+		if want, have := test.Expected, resurrectTimeout(time.Second, 10*time.Second, test.Failures); want != have {
+			t.Errorf("failures=%d: expected %v; got: %v", test.Failures, want, have)
+		}
+	}
+}
+
+func TestConnTryResurrect(t *testing.T) {
+	c := newConn("node", "http://127.0.0.1:9200")
+	if c.TryResurrect(time.Now(), time.Second, time.Minute) {
+		t.Fatal("expected healthy connection not to be resurrected")
+	}
+
+	c.MarkAsDead()
+	c.MarkAsDead()
+	now := time.Now().UTC()
+	if c.TryResurrect(now, time.Second, time.Minute) {
+		t.Fatal("expected dead connection not to be resurrected before timeout")
+	}
+	if c.TryResurrect(now.Add(1500*time.Millisecond), time.Second, time.Minute) {
+		t.Fatal("expected timeout to grow with the number of failures")
+	}
+	due := now.Add(2500 * time.Millisecond)
+	if !c.TryResurrect(due, time.Second, time.Minute) {
+		t.Fatal("expected dead connection to be resurrected after timeout")
+	}
+	if c.TryResurrect(due, time.Second, time.Minute) {
+		t.Fatal("expected only a single probe")
 	}
-	if c == nil {
-		t.Fatalf("expected connection; got: %v", c)
+	if !c.IsDead() {
+		t.Fatal("expected probed connection to still be dead")
 	}
 }
 
@@ -0,0 +1,52 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// decodeCloudID returns the HTTPS endpoint of Elasticsearch from the
+// given Cloud ID of Elastic Cloud.
+//
+// A Cloud ID has the form "<name>:<base64>", where the decoded base64
+// part is "<host>[:<port>]$<elasticsearch-uuid>[:<port>]$<kibana-uuid>".
+// The resulting endpoint is "https://<elasticsearch-uuid>.<host>[:<port>]".
+func decodeCloudID(cloudID string) (string, error) {
+	encoded := cloudID
+	if i := strings.LastIndex(cloudID, ":"); i >= 0 {
+		encoded = cloudID[i+1:]
+	}
+	if encoded == "" {
+		return "", fmt.Errorf("elastic: invalid cloud ID %q", cloudID)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	}
+	if err != nil {
+		return "", fmt.Errorf("elastic: invalid cloud ID %q: %v", cloudID, err)
+	}
+	parts := strings.Split(strings.TrimSuffix(string(data), "\n"), "$")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("elastic: invalid cloud ID %q: expected host and Elasticsearch ID", cloudID)
+	}
+	host, port := parts[0], ""
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host, port = host[:i], host[i+1:]
+	}
+	uuid := parts[1]
+	if i := strings.LastIndex(uuid, ":"); i >= 0 {
+		// Port of Elasticsearch takes precedence
+		uuid, port = uuid[:i], uuid[i+1:]
+	}
+	endpoint := "https://" + uuid + "." + host
+	if port != "" && port != "443" {
+		endpoint += ":" + port
+	}
+	return endpoint, nil
+}
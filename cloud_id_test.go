@@ -0,0 +1,68 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import "testing"
+
+func TestDecodeCloudID(t *testing.T) {
+	tests := []struct {
+		CloudID  string
+		Endpoint string
+		Error    bool
+	}{
+		{"my-deployment:dXMtZWFzdC0xLmF3cy5mb3VuZC5pbyRhYmMxMjMkZGVmNDU2", "https://abc123.us-east-1.aws.found.io", false},
+		{"dXMtZWFzdC0xLmF3cy5mb3VuZC5pbyRhYmMxMjMkZGVmNDU2", "https://abc123.us-east-1.aws.found.io", false},
+		{"my-deployment:dXMtZWFzdC0xLmF3cy5mb3VuZC5pbzo5MjQzJGFiYzEyMyRkZWY0NTY=", "https://abc123.us-east-1.aws.found.io:9243", false},
+		{"my-deployment:dXMtZWFzdC0xLmF3cy5mb3VuZC5pbzo5MjQzJGFiYzEyMzo5MjAwJGRlZjQ1Ng==", "https://abc123.us-east-1.aws.found.io:9200", false},
+		{"my-deployment:dXMtZWFzdC0xLmF3cy5mb3VuZC5pbw==", "", true},
+		{"my-deployment:not-base64!", "", true},
+		{"my-deployment:", "", true},
+		{"", "", true},
+	}
+	for _, test := range tests {
+		endpoint, err := decodeCloudID(test.CloudID)
+		if test.Error {
+			if err == nil {
+				t.Errorf("%q: expected error", test.CloudID)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", test.CloudID, err)
+		}
+		if want, have := test.Endpoint, endpoint; want != have {
+			t.Errorf("%q: expected endpoint %q; got: %q", test.CloudID, want, have)
+		}
+	}
+}
+
+func TestClientWithCloudID(t *testing.T) {
+	client, err := NewSimpleClient(SetCloudID("my-deployment:dXMtZWFzdC0xLmF3cy5mb3VuZC5pbzo5MjQzJGFiYzEyMyRkZWY0NTY="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"https://abc123.us-east-1.aws.found.io:9243"}, client.urls; len(have) != 1 || have[0] != want[0] {
+		t.Fatalf("expected urls = %v; got: %v", want, have)
+	}
+	if client.snifferEnabled {
+		t.Fatal("expected sniffing to be disabled")
+	}
+	if want, have := "https", client.scheme; want != have {
+		t.Fatalf("expected scheme = %q; got: %q", want, have)
+	}
+
+	// SetSniff after SetCloudID re-enables sniffing
+	client, err = NewSimpleClient(SetCloudID("my-deployment:dXMtZWFzdC0xLmF3cy5mb3VuZC5pbzo5MjQzJGFiYzEyMyRkZWY0NTY="), SetSniff(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.snifferEnabled {
+		t.Fatal("expected sniffing to be enabled")
+	}
+
+	if _, err := NewSimpleClient(SetCloudID("invalid")); err == nil {
+		t.Fatal("expected error for invalid cloud ID")
+	}
+}
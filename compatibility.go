@@ -0,0 +1,163 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CompatibilityMode specifies whether the client sends requests in
+// the REST API compatibility mode of Elasticsearch 8, which accepts the
+// requests and responses of Elasticsearch 7.
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/8.0/rest-api-compatibility.html
+// for details.
+type CompatibilityMode int
+
+const (
+	// CompatibilityOff sends requests as usual. This is the default.
+	CompatibilityOff CompatibilityMode = iota
+	// CompatibilityAuto detects the version of Elasticsearch on startup,
+	// and enables compatibility mode if it is 8.0 or later.
+	CompatibilityAuto
+	// CompatibilityOn always sends requests in compatibility mode.
+	CompatibilityOn
+)
+
+const (
+	// compatibleJSON is the media type of JSON in compatibility mode.
+	compatibleJSON = "application/vnd.elasticsearch+json; compatible-with=7"
+	// compatibleNDJSON is the media type of newline-delimited JSON,
+	// e.g. of bulk requests, in compatibility mode.
+	compatibleNDJSON = "application/vnd.elasticsearch+x-ndjson; compatible-with=7"
+)
+
+// SetCompatibilityMode specifies whether to send requests in the REST API
+// compatibility mode of Elasticsearch 8 (CompatibilityOff by default).
+//
+// In compatibility mode, the Accept and Content-Type headers ask
+// Elasticsearch 8 to accept the requests of the v7 API, and removed
+// parameters are adjusted, e.g. mapping types are omitted from the
+// requests of IndexService and BulkService.
+func SetCompatibilityMode(mode CompatibilityMode) ClientOptionFunc {
+	return func(c *Client) error {
+		switch mode {
+		case CompatibilityOff, CompatibilityAuto, CompatibilityOn:
+		default:
+			return fmt.Errorf("elastic: invalid compatibility mode %d", mode)
+		}
+		c.compatibilityMode = mode
+		c.compatible = mode == CompatibilityOn
+		return nil
+	}
+}
+
+// ServerVersion returns the version of Elasticsearch, e.g. "8.1.0", as
+// detected on startup (see SetCompatibilityMode) or by the last call to
+// DetectServerVersion. It returns an empty string if the version has not
+// been detected.
+func (c *Client) ServerVersion() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.serverVersion
+}
+
+// DetectServerVersion asks Elasticsearch for its version. If the
+// compatibility mode is CompatibilityAuto, compatibility mode is enabled
+// or disabled depending on the version.
+func (c *Client) DetectServerVersion(ctx context.Context) (string, error) {
+	conn, err := c.next()
+	if err != nil {
+		return "", err
+	}
+	res, _, err := c.Ping(conn.URL()).Do(ctx)
+	if err != nil {
+		return "", err
+	}
+	if res.Version.Number == "" {
+		return "", errors.New("elastic: Elasticsearch did not return its version")
+	}
+	version := res.Version.Number
+
+	c.mu.Lock()
+	c.serverVersion = version
+	mode := c.compatibilityMode
+	if mode == CompatibilityAuto {
+		c.compatible = majorVersion(version) >= 8
+	}
+	compatible := c.compatible
+	c.mu.Unlock()
+
+	c.log(ctx, LogLevelInfo, fmt.Sprintf("elastic: detected Elasticsearch %s (compatibility mode: %v)", version, compatible),
+		"node", conn.URL())
+	return version, nil
+}
+
+// compatibilityEnabled returns true if requests are sent in
+// compatibility mode.
+func (c *Client) compatibilityEnabled() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.compatible
+}
+
+// majorVersion returns the major version of a version like "8.1.0",
+// or 0 if it cannot be parsed.
+func majorVersion(version string) int {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
+// setCompatibilityHeaders replaces the JSON media types in the Accept
+// and Content-Type headers by those of compatibility mode. Other media
+// types, e.g. text/plain, are left alone.
+func setCompatibilityHeaders(header http.Header) {
+	for _, name := range []string{"Accept", "Content-Type"} {
+		values := header.Values(name)
+		for i, value := range values {
+			mediaType := strings.TrimSpace(strings.SplitN(value, ";", 2)[0])
+			switch strings.ToLower(mediaType) {
+			case "application/json":
+				values[i] = compatibleJSON
+			case "application/x-ndjson":
+				values[i] = compatibleNDJSON
+			}
+		}
+	}
+}
+
+// bulkLineWithoutType removes the "_type" field from the action and
+// meta data line of a bulk request, as mapping types have been removed
+// in Elasticsearch 8.
+func bulkLineWithoutType(line string) (string, error) {
+	if !strings.Contains(line, `"_type"`) {
+		return line, nil
+	}
+	var command map[string]map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &command); err != nil {
+		return "", err
+	}
+	for _, op := range command {
+		delete(op, "_type")
+	}
+	body, err := json.Marshal(command)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
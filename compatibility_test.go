@@ -0,0 +1,227 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// compatibilityTestServer pretends to be Elasticsearch of the given
+// version, and records the last request other than GET /.
+type compatibilityTestServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	pings       int
+	method      string
+	path        string
+	accept      string
+	contentType string
+	body        string
+}
+
+func newCompatibilityTestServer(version string) *compatibilityTestServer {
+	s := &compatibilityTestServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" && r.URL.Path == "/" {
+			s.pings++
+			fmt.Fprintf(w, `{"name":"node","cluster_name":"elasticsearch","version":{"number":%q}}`, version)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.method = r.Method
+		s.path = r.URL.Path
+		s.accept = r.Header.Get("Accept")
+		s.contentType = r.Header.Get("Content-Type")
+		s.body = string(body)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_bulk"):
+			fmt.Fprint(w, `{"took":1,"errors":false,"items":[]}`)
+		default:
+			fmt.Fprint(w, `{"_index":"twitter","_id":"1","result":"created"}`)
+		}
+	}))
+	return s
+}
+
+func TestCompatibilityModeAutoWithElasticsearch8(t *testing.T) {
+	ts := newCompatibilityTestServer("8.1.0")
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL), SetCompatibilityMode(CompatibilityAuto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "8.1.0", client.ServerVersion(); want != have {
+		t.Fatalf("expected server version %q; got: %q", want, have)
+	}
+	if !client.compatibilityEnabled() {
+		t.Fatal("expected compatibility mode to be enabled")
+	}
+
+	// Index with a mapping type
+	_, err = client.Index().Index("twitter").Type("tweet").Id("1").BodyJson(map[string]interface{}{"user": "olivere"}).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "/twitter/_doc/1", ts.path; want != have {
+		t.Fatalf("expected path %q; got: %q", want, have)
+	}
+	if want, have := compatibleJSON, ts.accept; want != have {
+		t.Fatalf("expected Accept = %q; got: %q", want, have)
+	}
+	if want, have := compatibleJSON, ts.contentType; want != have {
+		t.Fatalf("expected Content-Type = %q; got: %q", want, have)
+	}
+
+	// Bulk with mapping types
+	_, err = client.Bulk().Index("twitter").Type("tweet").
+		Add(NewBulkIndexRequest().Type("tweet").Id("1").Doc(map[string]interface{}{"user": "olivere"})).
+		Add(NewBulkDeleteRequest().Index("twitter").Type("tweet").Id("2")).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "/twitter/_bulk", ts.path; want != have {
+		t.Fatalf("expected path %q; got: %q", want, have)
+	}
+	if want, have := compatibleNDJSON, ts.contentType; want != have {
+		t.Fatalf("expected Content-Type = %q; got: %q", want, have)
+	}
+	want := `{"index":{"_id":"1"}}
+{"user":"olivere"}
+{"delete":{"_id":"2","_index":"twitter"}}
+`
+	if have := ts.body; want != have {
+		t.Fatalf("expected body\n%s\ngot:\n%s", want, have)
+	}
+}
+
+func TestCompatibilityModeAutoWithElasticsearch7(t *testing.T) {
+	ts := newCompatibilityTestServer("7.17.0")
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL), SetCompatibilityMode(CompatibilityAuto))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "7.17.0", client.ServerVersion(); want != have {
+		t.Fatalf("expected server version %q; got: %q", want, have)
+	}
+	if client.compatibilityEnabled() {
+		t.Fatal("expected compatibility mode to be disabled")
+	}
+
+	_, err = client.Index().Index("twitter").Type("tweet").Id("1").BodyJson(map[string]interface{}{"user": "olivere"}).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "/twitter/tweet/1", ts.path; want != have {
+		t.Fatalf("expected path %q; got: %q", want, have)
+	}
+	if want, have := "application/json", ts.accept; want != have {
+		t.Fatalf("expected Accept = %q; got: %q", want, have)
+	}
+}
+
+func TestCompatibilityModeOn(t *testing.T) {
+	ts := newCompatibilityTestServer("8.1.0")
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL), SetCompatibilityMode(CompatibilityOn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 0, ts.pings; want != have {
+		t.Fatalf("expected %d requests to detect the version; got: %d", want, have)
+	}
+	if have := client.ServerVersion(); have != "" {
+		t.Fatalf("expected no server version; got: %q", have)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/_cluster/health"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := compatibleJSON, ts.accept; want != have {
+		t.Fatalf("expected Accept = %q; got: %q", want, have)
+	}
+
+	// Detecting the version doesn't change the mode
+	version, err := client.DetectServerVersion(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "8.1.0", version; want != have {
+		t.Fatalf("expected server version %q; got: %q", want, have)
+	}
+	if !client.compatibilityEnabled() {
+		t.Fatal("expected compatibility mode to be enabled")
+	}
+}
+
+func TestSetCompatibilityHeaders(t *testing.T) {
+	tests := []struct {
+		Accept      string
+		ContentType string
+		WantAccept  string
+		WantContent string
+	}{
+		{"application/json", "application/json", compatibleJSON, compatibleJSON},
+		{"application/json", "application/x-ndjson", compatibleJSON, compatibleNDJSON},
+		{"text/plain", "application/json; charset=utf-8", "text/plain", compatibleJSON},
+		{"", "", "", ""},
+	}
+	for i, test := range tests {
+		header := make(http.Header)
+		if test.Accept != "" {
+			header.Set("Accept", test.Accept)
+		}
+		if test.ContentType != "" {
+			header.Set("Content-Type", test.ContentType)
+		}
+		setCompatibilityHeaders(header)
+		if want, have := test.WantAccept, header.Get("Accept"); want != have {
+			t.Errorf("#%d: expected Accept = %q; got: %q", i, want, have)
+		}
+		if want, have := test.WantContent, header.Get("Content-Type"); want != have {
+			t.Errorf("#%d: expected Content-Type = %q; got: %q", i, want, have)
+		}
+	}
+}
+
+func TestMajorVersion(t *testing.T) {
+	tests := []struct {
+		Version string
+		Major   int
+	}{
+		{"8.1.0", 8},
+		{"7.17.3", 7},
+		{"10", 10},
+		{"", 0},
+		{"x.y", 0},
+	}
+	for _, test := range tests {
+		if want, have := test.Major, majorVersion(test.Version); want != have {
+			t.Errorf("%q: expected %d; got: %d", test.Version, want, have)
+		}
+	}
+}
+
+func TestSetCompatibilityModeInvalid(t *testing.T) {
+	_, err := NewSimpleClient(SetURL("http://127.0.0.1:9200"), SetCompatibilityMode(CompatibilityMode(42)))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
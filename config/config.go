@@ -6,17 +6,23 @@ package config
 
 import (
 	"fmt"
+	"net/http"
 	"net/url"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Config represents an Elasticsearch configuration.
 type Config struct {
 	URL         string
+	URLs        []string // all URLs, including URL
+	CloudID     string
 	Index       string
 	Username    string
 	Password    string
+	APIKeyID    string
+	APIKey      string
 	Shards      int
 	Replicas    int
 	Sniff       *bool
@@ -24,6 +30,38 @@ type Config struct {
 	Infolog     string
 	Errorlog    string
 	Tracelog    string
+	Gzip        *bool
+	Headers     http.Header
+
+	// Retries
+	MaxRetries          *int
+	RetryInitialTimeout time.Duration
+	RetryMaxTimeout     time.Duration
+	RetryStatusCodes    []int
+
+	// Timeouts and intervals
+	Timeout                   time.Duration // timeout of the http.Client
+	HealthcheckTimeout        time.Duration
+	HealthcheckTimeoutStartup time.Duration
+	HealthcheckInterval       time.Duration
+	SnifferTimeout            time.Duration
+	SnifferTimeoutStartup     time.Duration
+	SnifferInterval           time.Duration
+
+	// TLS
+	TLSCAFile             string // PEM-encoded CA bundle
+	TLSCertFile           string // PEM-encoded client certificate
+	TLSKeyFile            string // PEM-encoded key of the client certificate
+	TLSInsecureSkipVerify bool
+}
+
+// newConfig returns a Config with the default settings.
+func newConfig() *Config {
+	return &Config{
+		Shards:   1,
+		Replicas: 0,
+		Sniff:    nil,
+	}
 }
 
 // Parse returns the Elasticsearch configuration by extracting it
@@ -34,12 +72,21 @@ type Config struct {
 //
 // The code above will return a URL of http://127.0.0.1:9200, an index name
 // of store-blobs, and the related settings from the query string.
+//
+// An API key can be passed with the apikey parameter, either as "id:key"
+// or in its base64-encoded form, e.g.
+//   https://127.0.0.1:9200/?apikey=VuaCfGcBCdbkQm-e5aOx:ui2lp2axTNmsyakw9tvNnw
+//
+// A deployment on Elastic Cloud can be specified with the cloud_id
+// parameter. It takes precedence over the URL.
+//
+// All other keys described in the package documentation can be passed
+// as query parameters as well, e.g. url for additional URLs or
+// max_retries. Unknown parameters and invalid values other than an invalid
+// API key are ignored, and the configuration is not validated. Use Load,
+// LoadYAML, ParseJSON, ParseYAML or FromEnv for a strict configuration.
 func Parse(elasticURL string) (*Config, error) {
-	cfg := &Config{
-		Shards:   1,
-		Replicas: 0,
-		Sniff:    nil,
-	}
+	cfg := newConfig()
 
 	uri, err := url.Parse(elasticURL)
 	if err != nil {
@@ -51,37 +98,61 @@ func Parse(elasticURL string) (*Config, error) {
 		cfg.Password, _ = uri.User.Password()
 	}
 	uri.User = nil
+	query := uri.Query()
 
-	if i, err := strconv.Atoi(uri.Query().Get("shards")); err == nil {
-		cfg.Shards = i
+	uri.Path = ""
+	uri.RawQuery = ""
+	cfg.URL = uri.String()
+	if cfg.URL != "" {
+		cfg.URLs = []string{cfg.URL}
 	}
-	if i, err := strconv.Atoi(uri.Query().Get("replicas")); err == nil {
-		cfg.Replicas = i
+	cfg.Index = index
+
+	names := make([]string, 0, len(query))
+	for key := range query {
+		if _, found := keys[key]; found {
+			names = append(names, key)
+		}
 	}
-	if s := uri.Query().Get("sniff"); s != "" {
-		if b, err := strconv.ParseBool(s); err == nil {
-			cfg.Sniff = &b
+	sort.Strings(names)
+	for _, key := range names {
+		for _, value := range query[key] {
+			// The URL form always ignored invalid values, except for
+			// API keys, which must not be dropped silently
+			if err := cfg.set(key, key, value); err != nil && key == "apikey" {
+				return nil, err
+			}
 		}
 	}
-	if s := uri.Query().Get("healthcheck"); s != "" {
-		if b, err := strconv.ParseBool(s); err == nil {
-			cfg.Healthcheck = &b
+	return cfg, nil
+}
+
+// Validate checks the settings that depend on each other, e.g. that
+// a client certificate comes with its key.
+func (cfg *Config) Validate() error {
+	if cfg.Shards < 1 {
+		return &KeyError{Key: "shards", Err: fmt.Errorf("must be at least 1")}
+	}
+	if cfg.Replicas < 0 {
+		return &KeyError{Key: "replicas", Err: fmt.Errorf("must not be negative")}
+	}
+	if cfg.MaxRetries != nil && *cfg.MaxRetries < 0 {
+		return &KeyError{Key: "max_retries", Err: fmt.Errorf("must not be negative")}
+	}
+	if (cfg.RetryInitialTimeout > 0) != (cfg.RetryMaxTimeout > 0) {
+		if cfg.RetryInitialTimeout > 0 {
+			return &KeyError{Key: "retry_max_timeout", Err: fmt.Errorf("is required with retry_initial_timeout")}
 		}
+		return &KeyError{Key: "retry_initial_timeout", Err: fmt.Errorf("is required with retry_max_timeout")}
 	}
-	if s := uri.Query().Get("infolog"); s != "" {
-		cfg.Infolog = s
+	if cfg.RetryInitialTimeout > cfg.RetryMaxTimeout {
+		return &KeyError{Key: "retry_initial_timeout", Err: fmt.Errorf("must not be greater than retry_max_timeout")}
 	}
-	if s := uri.Query().Get("errorlog"); s != "" {
-		cfg.Errorlog = s
+	if cfg.TLSCertFile != "" && cfg.TLSKeyFile == "" {
+		return &KeyError{Key: "tls_key_file", Err: fmt.Errorf("is required with tls_cert_file")}
 	}
-	if s := uri.Query().Get("tracelog"); s != "" {
-		cfg.Tracelog = s
+	if cfg.TLSKeyFile != "" && cfg.TLSCertFile == "" {
+		return &KeyError{Key: "tls_cert_file", Err: fmt.Errorf("is required with tls_key_file")}
 	}
-
-	uri.Path = ""
-	uri.RawQuery = ""
-	cfg.URL = uri.String()
-	cfg.Index = index
-
-	return cfg, nil
+	return nil
 }
@@ -4,6 +4,42 @@
 
 /*
 Package config allows parsing a configuration for Elasticsearch
-from a URL.
+from a URL (see Parse), from a JSON or YAML file (see Load and
+LoadYAML), or from ELASTICSEARCH_* environment variables (see FromEnv
+and ApplyEnv).
+
+All sources use the same keys:
+
+	url, urls                    URLs of Elasticsearch (multiple values)
+	cloud_id                     Cloud ID of a deployment on Elastic Cloud
+	index                        name of the index
+	username, password           credentials for HTTP Basic Auth
+	apikey                       API key as "id:key" or base64-encoded
+	api_key_id, api_key          API key as ID and key
+	shards, replicas             number of shards and replicas
+	sniff, healthcheck           enable or disable sniffing and health checks
+	errorlog, infolog, tracelog  files to log to
+	gzip                         enable or disable gzip compression
+	headers                      HTTP headers (multiple values, "Name: value")
+	max_retries                  number of retries
+	retry_initial_timeout        initial wait of the exponential backoff
+	retry_max_timeout            maximum wait of the exponential backoff
+	retry_status_codes           HTTP status codes to retry (multiple values)
+	timeout                      timeout of the HTTP client
+	healthcheck_timeout          timeout of health checks
+	healthcheck_timeout_startup  timeout of the health check on startup
+	healthcheck_interval         interval of health checks
+	sniffer_timeout              timeout of sniffing
+	sniffer_timeout_startup      timeout of sniffing on startup
+	sniffer_interval             interval of sniffing
+	tls_ca_file                  PEM-encoded CA bundle
+	tls_cert_file, tls_key_file  PEM-encoded client certificate and key
+	tls_insecure_skip_verify     disable verification of server certificates
+
+Durations are specified like "5s" or "1m30s". Invalid settings in files
+and environment variables are reported as a *KeyError with the name of
+the offending key. Parse ignores invalid settings for compatibility.
+
+Pass the configuration to elastic.NewClientFromConfig to create a client.
 */
 package config
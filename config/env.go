@@ -0,0 +1,89 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables read by FromEnv
+// and ApplyEnv.
+const EnvPrefix = "ELASTICSEARCH_"
+
+// FromEnv returns the configuration from the ELASTICSEARCH_* environment
+// variables. See ApplyEnv for details.
+func FromEnv() (*Config, error) {
+	cfg := newConfig()
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ApplyEnv overrides the settings of cfg with the ELASTICSEARCH_*
+// environment variables, e.g. to override a configuration file. The name
+// of the variable is the key in upper case, e.g. ELASTICSEARCH_MAX_RETRIES
+// for max_retries. Keys with multiple values, like ELASTICSEARCH_URLS,
+// are separated by commas. Headers are specified as "Name: value".
+//
+// Variables for list keys replace the values of cfg rather than adding
+// to them.
+func (cfg *Config) ApplyEnv() error {
+	return cfg.applyEnv(os.LookupEnv)
+}
+
+// applyEnv implements ApplyEnv with the given lookup function.
+func (cfg *Config) applyEnv(lookup func(string) (string, bool)) error {
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	cleared := make(map[string]bool)
+	for _, key := range names {
+		name := EnvPrefix + strings.ToUpper(key)
+		value, found := lookup(name)
+		if !found || value == "" {
+			continue
+		}
+		values := []string{value}
+		if keys[key].list {
+			if field := listField(key); !cleared[field] {
+				cfg.reset(field)
+				cleared[field] = true
+			}
+			values = strings.Split(value, ",")
+		}
+		for _, v := range values {
+			if err := cfg.set(name, key, v); err != nil {
+				return err
+			}
+		}
+	}
+	return cfg.Validate()
+}
+
+// listField returns the name of the field of a list key. Both url
+// and urls set the same field.
+func listField(key string) string {
+	if key == "urls" {
+		return "url"
+	}
+	return key
+}
+
+// reset clears the values of the field of a list key.
+func (cfg *Config) reset(field string) {
+	switch field {
+	case "url":
+		cfg.URL, cfg.URLs = "", nil
+	case "headers":
+		cfg.Headers = nil
+	case "retry_status_codes":
+		cfg.RetryStatusCodes = nil
+	}
+}
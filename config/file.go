@@ -0,0 +1,196 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// UnmarshalFunc decodes data into v, e.g. yaml.Unmarshal of
+// gopkg.in/yaml.v3 or gopkg.in/yaml.v2.
+type UnmarshalFunc func(data []byte, v interface{}) error
+
+// Load reads the configuration from a JSON file. Use LoadYAML for
+// YAML files.
+//
+// The file contains an object with the keys described in the package
+// documentation, e.g. in YAML
+//
+//	urls:
+//	  - https://es1:9200
+//	  - https://es2:9200
+//	username: elastic
+//	password: secret
+//	max_retries: 3
+//	healthcheck_interval: 30s
+//	headers:
+//	  X-Opaque-Id: my-app
+//	tls:
+//	  ca_file: /etc/ssl/es-ca.pem
+//
+// Nested objects are joined with an underscore, so "ca_file" in
+// "tls" is the same as "tls_ca_file".
+func Load(filename string) (*Config, error) {
+	return LoadYAML(filename, nil)
+}
+
+// LoadYAML reads the configuration from a JSON or YAML file, depending
+// on the extension of the file (.json, .yaml or .yml). YAML files are
+// decoded with unmarshal, so that this package does not depend on a YAML
+// library, e.g.
+//
+//	cfg, err := config.LoadYAML("elastic.yml", yaml.Unmarshal)
+//
+// See Load for details.
+func LoadYAML(filename string, unmarshal UnmarshalFunc) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		if unmarshal == nil {
+			return nil, fmt.Errorf("config: use LoadYAML to read YAML files")
+		}
+		return ParseYAML(data, unmarshal)
+	default:
+		return nil, fmt.Errorf("config: unsupported file extension %q", ext)
+	}
+}
+
+// ParseJSON returns the configuration from a JSON object. See Load
+// for details.
+func ParseJSON(data []byte) (*Config, error) {
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("config: invalid JSON: %v", err)
+	}
+	return fromMap(m)
+}
+
+// ParseYAML returns the configuration from a YAML document decoded with
+// unmarshal, e.g. yaml.Unmarshal. See Load for details.
+func ParseYAML(data []byte, unmarshal UnmarshalFunc) (*Config, error) {
+	var m map[string]interface{}
+	if err := unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("config: invalid YAML: %v", err)
+	}
+	return fromMap(m)
+}
+
+// fromMap returns the configuration from a decoded JSON or YAML object.
+func fromMap(m map[string]interface{}) (*Config, error) {
+	cfg := newConfig()
+	if err := cfg.setMap("", "", m); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// setMap applies all entries of m. path is the name of m for errors,
+// e.g. "tls", and prefix is the prefix of its keys, e.g. "tls_".
+func (cfg *Config) setMap(path, prefix string, m map[string]interface{}) error {
+	for _, k := range sortedKeys(m) { // report errors deterministically
+		name := k
+		if path != "" {
+			name = path + "." + k
+		}
+		if err := cfg.setValue(name, prefix+k, m[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setValue applies a decoded JSON or YAML value to the setting key.
+func (cfg *Config) setValue(name, key string, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		if key == "headers" {
+			for _, header := range sortedKeys(v) {
+				s, err := scalar(v[header])
+				if err != nil {
+					return &KeyError{Key: name + "." + header, Err: err}
+				}
+				if err := cfg.set(name, key, header+": "+s); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return cfg.setMap(name, key+"_", v)
+	case map[interface{}]interface{}:
+		// Nested objects of gopkg.in/yaml.v2
+		m := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			m[fmt.Sprint(k)] = elem
+		}
+		return cfg.setValue(name, key, m)
+	case []interface{}:
+		if k, found := keys[key]; found && !k.list {
+			return &KeyError{Key: name, Err: fmt.Errorf("expected a single value")}
+		}
+		for i, elem := range v {
+			s, err := scalar(elem)
+			if err != nil {
+				return &KeyError{Key: fmt.Sprintf("%s[%d]", name, i), Err: err}
+			}
+			if err := cfg.set(name, key, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		s, err := scalar(v)
+		if err != nil {
+			return &KeyError{Key: name, Err: err}
+		}
+		return cfg.set(name, key, s)
+	}
+}
+
+// scalar returns the textual representation of a decoded JSON or
+// YAML scalar.
+func scalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return "", fmt.Errorf("expected a scalar value, got %T", value)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
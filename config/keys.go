@@ -0,0 +1,210 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KeyError is returned for an invalid setting. Key is the name of the
+// setting as it was specified, e.g. "max_retries" in a file or query
+// string, or "ELASTICSEARCH_MAX_RETRIES" in the environment.
+type KeyError struct {
+	Key string
+	Err error
+}
+
+// Error returns a textual representation of the error.
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("config: invalid %s: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// setter applies a single value of a key to the Config.
+type setter func(cfg *Config, value string) error
+
+// keys are all the settings of a Config, by their name in files,
+// query strings and (in upper case, prefixed with "ELASTICSEARCH_")
+// environment variables.
+var keys = map[string]struct {
+	set  setter
+	list bool // true if the key accepts multiple values
+}{
+	"url":         {setURL, true},
+	"urls":        {setURL, true},
+	"cloud_id":    {func(cfg *Config, s string) error { cfg.CloudID = s; return nil }, false},
+	"index":       {func(cfg *Config, s string) error { cfg.Index = s; return nil }, false},
+	"username":    {func(cfg *Config, s string) error { cfg.Username = s; return nil }, false},
+	"password":    {func(cfg *Config, s string) error { cfg.Password = s; return nil }, false},
+	"apikey":      {setAPIKey, false},
+	"api_key_id":  {func(cfg *Config, s string) error { cfg.APIKeyID = s; return nil }, false},
+	"api_key":     {func(cfg *Config, s string) error { cfg.APIKey = s; return nil }, false},
+	"shards":      {intSetter(func(cfg *Config, i int) { cfg.Shards = i }), false},
+	"replicas":    {intSetter(func(cfg *Config, i int) { cfg.Replicas = i }), false},
+	"sniff":       {boolSetter(func(cfg *Config, b bool) { cfg.Sniff = &b }), false},
+	"healthcheck": {boolSetter(func(cfg *Config, b bool) { cfg.Healthcheck = &b }), false},
+	"infolog":     {func(cfg *Config, s string) error { cfg.Infolog = s; return nil }, false},
+	"errorlog":    {func(cfg *Config, s string) error { cfg.Errorlog = s; return nil }, false},
+	"tracelog":    {func(cfg *Config, s string) error { cfg.Tracelog = s; return nil }, false},
+	"gzip":        {boolSetter(func(cfg *Config, b bool) { cfg.Gzip = &b }), false},
+	"headers":     {setHeader, true},
+
+	"max_retries":           {intSetter(func(cfg *Config, i int) { cfg.MaxRetries = &i }), false},
+	"retry_initial_timeout": {durationSetter(func(cfg *Config, d time.Duration) { cfg.RetryInitialTimeout = d }), false},
+	"retry_max_timeout":     {durationSetter(func(cfg *Config, d time.Duration) { cfg.RetryMaxTimeout = d }), false},
+	"retry_status_codes":    {setRetryStatusCode, true},
+
+	"timeout":                     {durationSetter(func(cfg *Config, d time.Duration) { cfg.Timeout = d }), false},
+	"healthcheck_timeout":         {durationSetter(func(cfg *Config, d time.Duration) { cfg.HealthcheckTimeout = d }), false},
+	"healthcheck_timeout_startup": {durationSetter(func(cfg *Config, d time.Duration) { cfg.HealthcheckTimeoutStartup = d }), false},
+	"healthcheck_interval":        {durationSetter(func(cfg *Config, d time.Duration) { cfg.HealthcheckInterval = d }), false},
+	"sniffer_timeout":             {durationSetter(func(cfg *Config, d time.Duration) { cfg.SnifferTimeout = d }), false},
+	"sniffer_timeout_startup":     {durationSetter(func(cfg *Config, d time.Duration) { cfg.SnifferTimeoutStartup = d }), false},
+	"sniffer_interval":            {durationSetter(func(cfg *Config, d time.Duration) { cfg.SnifferInterval = d }), false},
+
+	"tls_ca_file":              {func(cfg *Config, s string) error { cfg.TLSCAFile = s; return nil }, false},
+	"tls_cert_file":            {func(cfg *Config, s string) error { cfg.TLSCertFile = s; return nil }, false},
+	"tls_key_file":             {func(cfg *Config, s string) error { cfg.TLSKeyFile = s; return nil }, false},
+	"tls_insecure_skip_verify": {boolSetter(func(cfg *Config, b bool) { cfg.TLSInsecureSkipVerify = b }), false},
+}
+
+// set applies value to the setting key. name is the name of the
+// setting as specified by the user, and is used in errors.
+func (cfg *Config) set(name, key, value string) error {
+	k, found := keys[key]
+	if !found {
+		return &KeyError{Key: name, Err: fmt.Errorf("unknown key")}
+	}
+	if err := k.set(cfg, value); err != nil {
+		return &KeyError{Key: name, Err: err}
+	}
+	return nil
+}
+
+func intSetter(f func(cfg *Config, i int)) setter {
+	return func(cfg *Config, s string) error {
+		i, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("expected an integer, got %q", s)
+		}
+		f(cfg, i)
+		return nil
+	}
+}
+
+func boolSetter(f func(cfg *Config, b bool)) setter {
+	return func(cfg *Config, s string) error {
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("expected a boolean, got %q", s)
+		}
+		f(cfg, b)
+		return nil
+	}
+}
+
+func durationSetter(f func(cfg *Config, d time.Duration)) setter {
+	return func(cfg *Config, s string) error {
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("expected a duration like \"5s\", got %q", s)
+		}
+		if d < 0 {
+			return fmt.Errorf("must not be negative")
+		}
+		f(cfg, d)
+		return nil
+	}
+}
+
+// setURL adds a URL. The first URL is also used as Config.URL.
+func setURL(cfg *Config, s string) error {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("expected an http or https URL, got %q", s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host in %q", s)
+	}
+	for _, existing := range cfg.URLs {
+		if existing == s {
+			return nil
+		}
+	}
+	if cfg.URL == "" {
+		cfg.URL = s
+	}
+	cfg.URLs = append(cfg.URLs, s)
+	return nil
+}
+
+// setAPIKey sets the API key, either as "id:key" or in its
+// base64-encoded form.
+func setAPIKey(cfg *Config, s string) error {
+	id, key, err := parseAPIKey(s)
+	if err != nil {
+		return err
+	}
+	cfg.APIKeyID = id
+	cfg.APIKey = key
+	return nil
+}
+
+// parseAPIKey parses an API key, either as "id:key" or in its
+// base64-encoded form.
+func parseAPIKey(s string) (id, key string, err error) {
+	if !strings.Contains(s, ":") {
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			decoded, err = base64.URLEncoding.DecodeString(s)
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("expected id:key or its base64 encoding")
+		}
+		s = string(decoded)
+	}
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("expected id:key or its base64 encoding")
+	}
+	return parts[0], parts[1], nil
+}
+
+// setHeader adds a header in the form "Name: value".
+func setHeader(cfg *Config, s string) error {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return fmt.Errorf("expected \"Name: value\", got %q", s)
+	}
+	if cfg.Headers == nil {
+		cfg.Headers = make(http.Header)
+	}
+	cfg.Headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	return nil
+}
+
+// setRetryStatusCode adds an HTTP status code to retry on.
+func setRetryStatusCode(cfg *Config, s string) error {
+	code, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || code < 100 || code > 599 {
+		return fmt.Errorf("expected an HTTP status code, got %q", s)
+	}
+	cfg.RetryStatusCodes = append(cfg.RetryStatusCodes, code)
+	return nil
+}
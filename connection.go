@@ -10,6 +10,34 @@ import (
 	"time"
 )
 
+// connLatencyDecay is the weight of a new sample in the exponentially
+// weighted moving average of the latency of a connection.
+const connLatencyDecay = 0.3
+
+// Connection is the read-only view of a connection to a node in a
+// cluster. It is passed to a Selector to pick the connection to use
+// for the next request.
+type Connection interface {
+	// NodeID returns the ID of the node of this connection.
+	NodeID() string
+	// URL returns the URL of this connection.
+	URL() string
+	// IsDead returns true if this connection is marked as dead.
+	IsDead() bool
+	// InFlight returns the number of requests currently in flight.
+	InFlight() int
+	// Latency returns the exponentially weighted moving average of the
+	// round-trip time of requests, or 0 if there are no samples yet.
+	Latency() time.Duration
+	// Roles returns the roles of the node, e.g. [master, data, ingest],
+	// as found by the sniffer. It is empty if the roles are unknown or
+	// if the node is a coordinating-only node.
+	Roles() []string
+	// Attributes returns the custom attributes of the node, e.g. the zone,
+	// as found by the sniffer.
+	Attributes() map[string]string
+}
+
 // conn represents a single connection to a node in a cluster.
 type conn struct {
 	sync.RWMutex
@@ -18,6 +46,12 @@ type conn struct {
 	failures  int
 	dead      bool
 	deadSince *time.Time
+	lastTry   time.Time // last time the connection failed or was probed
+	inFlight  int       // number of requests in flight
+	latency   float64   // moving average of round-trip times in nanoseconds
+	roles     []string
+	attrs     map[string]string
+	breaker   circuitBreakerState
 }
 
 // newConn creates a new connection to the given URL.
@@ -50,6 +84,29 @@ func (c *conn) URL() string {
 	return c.url
 }
 
+// Roles returns the roles of the node of this connection.
+func (c *conn) Roles() []string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.roles
+}
+
+// Attributes returns the custom attributes of the node of this connection.
+func (c *conn) Attributes() map[string]string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.attrs
+}
+
+// setNodeInfo updates the roles and attributes of the node of this
+// connection.
+func (c *conn) setNodeInfo(roles []string, attrs map[string]string) {
+	c.Lock()
+	c.roles = roles
+	c.attrs = attrs
+	c.Unlock()
+}
+
 // IsDead returns true if this connection is marked as dead, i.e. a previous
 // request to the URL has been unsuccessful.
 func (c *conn) IsDead() bool {
@@ -58,33 +115,134 @@ func (c *conn) IsDead() bool {
 	return c.dead
 }
 
+// InFlight returns the number of requests currently running on this
+// connection.
+func (c *conn) InFlight() int {
+	c.RLock()
+	defer c.RUnlock()
+	return c.inFlight
+}
+
+// Latency returns the exponentially weighted moving average of the
+// round-trip time of requests on this connection. It returns 0 if no
+// request has been completed yet.
+func (c *conn) Latency() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+	return time.Duration(c.latency)
+}
+
+// startRequest records that a request is started on this connection.
+func (c *conn) startRequest() {
+	c.Lock()
+	c.inFlight++
+	c.Unlock()
+}
+
+// finishRequest records that a request on this connection has finished.
+// If ok is true, the round-trip time is added to the latency average.
+func (c *conn) finishRequest(took time.Duration, ok bool) {
+	c.Lock()
+	if c.inFlight > 0 {
+		c.inFlight--
+	}
+	if ok {
+		if c.latency == 0 {
+			c.latency = float64(took)
+		} else {
+			c.latency = connLatencyDecay*float64(took) + (1-connLatencyDecay)*c.latency
+		}
+	}
+	c.Unlock()
+}
+
 // MarkAsDead marks this connection as dead, increments the failures
 // counter and stores the current time in dead since.
 func (c *conn) MarkAsDead() {
+	c.markAsDead()
+}
+
+// markAsDead is like MarkAsDead, but returns true if the connection
+// was alive before.
+func (c *conn) markAsDead() bool {
 	c.Lock()
+	wasAlive := !c.dead
 	c.dead = true
+	utcNow := time.Now().UTC()
 	if c.deadSince == nil {
-		utcNow := time.Now().UTC()
 		c.deadSince = &utcNow
 	}
+	c.lastTry = utcNow
 	c.failures += 1
 	c.Unlock()
+	return wasAlive
+}
+
+// ResurrectAt returns the time when a dead connection is eligible to
+// receive a probe request. The time grows exponentially with the number
+// of failures, starting with initial and capped at max.
+func (c *conn) ResurrectAt(initial, max time.Duration) time.Time {
+	c.RLock()
+	defer c.RUnlock()
+	return c.lastTry.Add(resurrectTimeout(initial, max, c.failures))
+}
+
+// TryResurrect returns true if this connection is dead and its resurrection
+// time has passed. It then records the probe, so that only a single request
+// is let through until the next resurrection time.
+func (c *conn) TryResurrect(now time.Time, initial, max time.Duration) bool {
+	c.Lock()
+	defer c.Unlock()
+	if !c.dead || now.Before(c.lastTry.Add(resurrectTimeout(initial, max, c.failures))) {
+		return false
+	}
+	c.lastTry = now
+	return true
+}
+
+// resurrectTimeout returns the time a connection with the given number
+// of failures stays dead: initial * 2^(failures-1), capped at max.
+func resurrectTimeout(initial, max time.Duration, failures int) time.Duration {
+	timeout := initial
+	for i := 1; i < failures && timeout < max; i++ {
+		timeout *= 2
+	}
+	if timeout > max {
+		timeout = max
+	}
+	return timeout
 }
 
 // MarkAsAlive marks this connection as eligible to be returned from the
 // pool of connections by the selector.
 func (c *conn) MarkAsAlive() {
+	c.markAsAlive()
+}
+
+// markAsAlive is like MarkAsAlive, but returns true if the connection
+// was dead before.
+func (c *conn) markAsAlive() bool {
 	c.Lock()
+	wasDead := c.dead
 	c.dead = false
 	c.Unlock()
+	return wasDead
 }
 
 // MarkAsHealthy marks this connection as healthy, i.e. a request has been
 // successfully performed with it.
 func (c *conn) MarkAsHealthy() {
+	c.markAsHealthy()
+}
+
+// markAsHealthy is like MarkAsHealthy, but returns true if the connection
+// was dead before.
+func (c *conn) markAsHealthy() bool {
 	c.Lock()
+	wasDead := c.dead
 	c.dead = false
 	c.deadSince = nil
 	c.failures = 0
 	c.Unlock()
+	return wasDead
 }
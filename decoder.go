@@ -7,6 +7,7 @@ package elastic
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 )
 
 // Decoder is used to decode responses from Elasticsearch.
@@ -17,6 +18,25 @@ type Decoder interface {
 	Decode(data []byte, v interface{}) error
 }
 
+// StreamDecoder is a Decoder that can also decode responses while they
+// are read from the network, e.g. to iterate over search hits one at a time
+// without reading the whole response into memory (see SearchService.DoStream).
+//
+// If the Decoder of a Client does not implement StreamDecoder, responses
+// are tokenized with encoding/json and each value is passed to Decode.
+type StreamDecoder interface {
+	Decoder
+	NewStream(r io.Reader) TokenDecoder
+}
+
+// TokenDecoder reads JSON tokens and values from a stream.
+// *json.Decoder from the Go standard library implements TokenDecoder.
+type TokenDecoder interface {
+	Token() (json.Token, error)
+	More() bool
+	Decode(v interface{}) error
+}
+
 // DefaultDecoder uses json.Unmarshal from the Go standard library
 // to decode JSON data.
 type DefaultDecoder struct{}
@@ -26,6 +46,11 @@ func (u *DefaultDecoder) Decode(data []byte, v interface{}) error {
 	return json.Unmarshal(data, v)
 }
 
+// NewStream returns a json.Decoder from the Go standard library.
+func (u *DefaultDecoder) NewStream(r io.Reader) TokenDecoder {
+	return json.NewDecoder(r)
+}
+
 // NumberDecoder uses json.NewDecoder, with UseNumber() enabled, from
 // the Go standard library to decode JSON data.
 type NumberDecoder struct{}
@@ -36,3 +61,40 @@ func (u *NumberDecoder) Decode(data []byte, v interface{}) error {
 	dec.UseNumber()
 	return dec.Decode(v)
 }
+
+// NewStream returns a json.Decoder from the Go standard library,
+// with UseNumber() enabled.
+func (u *NumberDecoder) NewStream(r io.Reader) TokenDecoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
+
+// newTokenDecoder returns a TokenDecoder for r. If dec does not implement
+// StreamDecoder, values are decoded with dec after being tokenized with
+// encoding/json.
+func newTokenDecoder(dec Decoder, r io.Reader) TokenDecoder {
+	if sd, ok := dec.(StreamDecoder); ok {
+		return sd.NewStream(r)
+	}
+	return &rawTokenDecoder{Decoder: json.NewDecoder(r), dec: dec}
+}
+
+// rawTokenDecoder reads each value as a json.RawMessage and passes it
+// to a Decoder.
+type rawTokenDecoder struct {
+	*json.Decoder
+	dec Decoder
+}
+
+// Decode reads the next value and decodes it with the Decoder.
+func (d *rawTokenDecoder) Decode(v interface{}) error {
+	if raw, ok := v.(*json.RawMessage); ok {
+		return d.Decoder.Decode(raw)
+	}
+	var raw json.RawMessage
+	if err := d.Decoder.Decode(&raw); err != nil {
+		return err
+	}
+	return d.dec.Decode(raw, v)
+}
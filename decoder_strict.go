@@ -0,0 +1,277 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StrictDecodingMode specifies what happens when a response from
+// Elasticsearch contains fields that the result type does not know
+// about (see SetStrictDecoding).
+type StrictDecodingMode int
+
+const (
+	// StrictDecodingOff ignores unknown fields. This is the default.
+	StrictDecodingOff StrictDecodingMode = iota
+	// StrictDecodingLog logs unknown fields as warnings.
+	StrictDecodingLog
+	// StrictDecodingFail logs unknown fields and fails the request
+	// with an *UnknownFieldsError.
+	StrictDecodingFail
+)
+
+// SetStrictDecoding reports fields in responses from Elasticsearch that
+// are not decoded into the result type, e.g. because a new version of
+// Elasticsearch added them (StrictDecodingOff by default).
+//
+// Strict decoding is meant for testing the client against new versions
+// of Elasticsearch. It wraps the Decoder of the client (see SetDecoder)
+// in a StrictDecoder, which is expensive.
+func SetStrictDecoding(mode StrictDecodingMode) ClientOptionFunc {
+	return func(c *Client) error {
+		switch mode {
+		case StrictDecodingOff, StrictDecodingLog, StrictDecodingFail:
+		default:
+			return fmt.Errorf("elastic: invalid strict decoding mode %d", mode)
+		}
+		c.strictDecoding = mode
+		return nil
+	}
+}
+
+// setupStrictDecoding wraps the decoder of the client if strict
+// decoding is enabled.
+func (c *Client) setupStrictDecoding() {
+	if c.strictDecoding == StrictDecodingOff {
+		return
+	}
+	c.decoder = &StrictDecoder{
+		Decoder: c.decoder,
+		Fail:    c.strictDecoding == StrictDecodingFail,
+		Report: func(err *UnknownFieldsError) {
+			c.log(context.Background(), LogLevelWarn, err.Error(),
+				"type", err.Type,
+				"fields", strings.Join(err.Paths, ","))
+		},
+	}
+}
+
+// UnknownFieldsError is returned by StrictDecoder if a response contains
+// fields that are not decoded into the result type.
+type UnknownFieldsError struct {
+	// Type is the Go type the response was decoded into,
+	// e.g. "*elastic.NodesStatsResponse".
+	Type string
+	// Paths are the JSON paths of the unknown fields, e.g.
+	// "nodes.*.jvm.mem.new_field". Keys of maps are replaced by "*",
+	// and elements of arrays by "[]".
+	Paths []string
+}
+
+// Error returns a string representation of the error.
+func (e *UnknownFieldsError) Error() string {
+	return fmt.Sprintf("elastic: unknown fields in response decoded into %s: %s", e.Type, strings.Join(e.Paths, ", "))
+}
+
+// StrictDecoder is a Decoder that reports fields in the data that are not
+// decoded into the given value, i.e. that are silently dropped by
+// encoding/json. Fields are reported with their JSON path.
+//
+// Types that implement json.Unmarshaler, as well as interface{} and
+// json.RawMessage, accept all fields.
+type StrictDecoder struct {
+	// Decoder decodes the data. DefaultDecoder is used if it is nil.
+	Decoder Decoder
+	// Fail returns an *UnknownFieldsError from Decode if there are
+	// unknown fields. Otherwise the unknown fields are only reported.
+	Fail bool
+	// Report is called with the unknown fields, if any.
+	Report func(err *UnknownFieldsError)
+}
+
+// Decode decodes data into v and checks it for unknown fields.
+func (d *StrictDecoder) Decode(data []byte, v interface{}) error {
+	dec := d.Decoder
+	if dec == nil {
+		dec = &DefaultDecoder{}
+	}
+	if err := dec.Decode(data, v); err != nil {
+		return err
+	}
+	paths, err := unknownFields(data, v)
+	if err != nil || len(paths) == 0 {
+		// If the Decoder accepted data that is not JSON, we can't check it
+		return nil
+	}
+	uerr := &UnknownFieldsError{Type: fmt.Sprintf("%T", v), Paths: paths}
+	if d.Report != nil {
+		d.Report(uerr)
+	}
+	if d.Fail {
+		return uerr
+	}
+	return nil
+}
+
+// unknownFields returns the sorted JSON paths of the fields in data
+// that are not decoded into v.
+func unknownFields(data []byte, v interface{}) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	found := make(map[string]struct{})
+	collectUnknownFields(doc, reflect.TypeOf(v), "", found)
+	if len(found) == 0 {
+		return nil, nil
+	}
+	paths := make([]string, 0, len(found))
+	for path := range found {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// collectUnknownFields walks doc along type t and adds the paths of
+// unknown fields to found.
+func collectUnknownFields(doc interface{}, t reflect.Type, path string, found map[string]struct{}) {
+	if t == nil {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := cachedJSONFields(t)
+		for key, value := range obj {
+			field, ok := fields.lookup(key)
+			if !ok {
+				found[joinJSONPath(path, key)] = struct{}{}
+				continue
+			}
+			collectUnknownFields(value, field, joinJSONPath(path, key), found)
+		}
+	case reflect.Map:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, value := range obj {
+			collectUnknownFields(value, t.Elem(), joinJSONPath(path, "*"), found)
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := doc.([]interface{})
+		if !ok {
+			return
+		}
+		for _, value := range arr {
+			collectUnknownFields(value, t.Elem(), path+"[]", found)
+		}
+	}
+}
+
+// joinJSONPath appends key to a JSON path.
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonFields maps the JSON names of the fields of a struct to their types.
+type jsonFields map[string]reflect.Type
+
+// lookup returns the type of a field like encoding/json, i.e. preferring
+// an exact match of the name over a case-insensitive one.
+func (f jsonFields) lookup(name string) (reflect.Type, bool) {
+	if t, ok := f[name]; ok {
+		return t, true
+	}
+	for key, t := range f {
+		if strings.EqualFold(key, name) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+var jsonFieldsCache sync.Map // map[reflect.Type]jsonFields
+
+// cachedJSONFields returns the JSON fields of struct type t.
+func cachedJSONFields(t reflect.Type) jsonFields {
+	if f, ok := jsonFieldsCache.Load(t); ok {
+		return f.(jsonFields)
+	}
+	fields := make(jsonFields)
+	addJSONFields(fields, t)
+	f, _ := jsonFieldsCache.LoadOrStore(t, fields)
+	return f.(jsonFields)
+}
+
+// addJSONFields adds the fields of struct type t to fields, including
+// those of embedded structs. Like in encoding/json, fields of the outer
+// struct take precedence over those of embedded structs.
+func addJSONFields(fields jsonFields, t reflect.Type) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := sf.Type
+		if sf.Anonymous && name == "" {
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue // unexported
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields[name] = ft
+	}
+	for _, et := range embedded {
+		inner := make(jsonFields)
+		addJSONFields(inner, et)
+		for name, ft := range inner {
+			if _, exists := fields[name]; !exists {
+				fields[name] = ft
+			}
+		}
+	}
+}
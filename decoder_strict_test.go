@@ -0,0 +1,193 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type strictTestBase struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type strictTestDoc struct {
+	strictTestBase
+	Name    string                    `json:"title"`
+	Count   int                       `json:"count,omitempty"`
+	Nested  *strictTestBase           `json:"nested"`
+	ByKey   map[string]strictTestBase `json:"by_key"`
+	List    []strictTestBase          `json:"list"`
+	Raw     json.RawMessage           `json:"raw"`
+	Any     interface{}               `json:"any"`
+	AnyMap  map[string]interface{}    `json:"any_map"`
+	Ignored string                    `json:"-"`
+	NoTag   string
+	Custom  *strictTestCustom          `json:"custom"`
+	RawMap  map[string]json.RawMessage `json:"raw_map"`
+}
+
+type strictTestCustom struct {
+	Value string
+}
+
+func (c *strictTestCustom) UnmarshalJSON(data []byte) error {
+	c.Value = string(data)
+	return nil
+}
+
+func TestStrictDecoder(t *testing.T) {
+	data := []byte(`{
+		"id": "1",
+		"name": "embedded",
+		"title": "outer",
+		"COUNT": 1,
+		"nested": {"id": "2", "unknown1": true},
+		"by_key": {"a": {"id": "3", "unknown2": 1}, "b": {"unknown2": 2, "unknown3": null}},
+		"list": [{"id": "4"}, {"unknown4": {"x": 1}}],
+		"raw": {"anything": "goes"},
+		"any": {"anything": "goes"},
+		"any_map": {"a": {"anything": "goes"}},
+		"Ignored": "x",
+		"NoTag": "y",
+		"custom": {"anything": "goes"},
+		"raw_map": {"a": {"anything": "goes"}},
+		"unknown5": [1, 2, 3]
+	}`)
+
+	var reported *UnknownFieldsError
+	dec := &StrictDecoder{
+		Report: func(err *UnknownFieldsError) {
+			reported = err
+		},
+	}
+	var doc strictTestDoc
+	if err := dec.Decode(data, &doc); err != nil {
+		t.Fatalf("expected no error in log-only mode; got: %v", err)
+	}
+	if want, have := "outer", doc.Name; want != have {
+		t.Fatalf("expected Name = %q; got: %q", want, have)
+	}
+	if reported == nil {
+		t.Fatal("expected unknown fields to be reported")
+	}
+	if want, have := "*elastic.strictTestDoc", reported.Type; want != have {
+		t.Fatalf("expected Type = %q; got: %q", want, have)
+	}
+	want := []string{
+		"Ignored",
+		"by_key.*.unknown2",
+		"by_key.*.unknown3",
+		"list[].unknown4",
+		"nested.unknown1",
+		"unknown5",
+	}
+	if have := reported.Paths; strings.Join(want, " ") != strings.Join(have, " ") {
+		t.Fatalf("expected paths\n%v\ngot:\n%v", want, have)
+	}
+
+	// Fail mode
+	dec.Fail = true
+	err := dec.Decode(data, &doc)
+	var uerr *UnknownFieldsError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("expected *UnknownFieldsError; got: %v", err)
+	}
+	if want, have := 6, len(uerr.Paths); want != have {
+		t.Fatalf("expected %d paths; got: %d", want, have)
+	}
+
+	// No unknown fields
+	reported = nil
+	if err := dec.Decode([]byte(`{"id":"1","list":[],"nested":null}`), &doc); err != nil {
+		t.Fatalf("expected no error; got: %v", err)
+	}
+	if reported != nil {
+		t.Fatalf("expected no unknown fields; got: %v", reported)
+	}
+
+	// Errors of the Decoder are returned as is
+	if err := dec.Decode([]byte(`{"id":1}`), &doc); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+type strictTestLogger struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (l *strictTestLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.msgs = append(l.msgs, fmt.Sprintf(format, v...))
+}
+
+func TestSetStrictDecoding(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"cluster_name": "elasticsearch",
+			"nodes": {
+				"node-1": {"name": "node-1", "jvm": {"uptime_in_millis": 1, "new_jvm_field": 1}},
+				"node-2": {"name": "node-2", "new_node_field": "x"}
+			}
+		}`)
+	}))
+	defer ts.Close()
+
+	// Log-only mode
+	logger := &strictTestLogger{}
+	client, err := NewSimpleClient(SetURL(ts.URL), SetErrorLog(logger), SetStrictDecoding(StrictDecodingLog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.NodesStats().Do(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error in log-only mode; got: %v", err)
+	}
+	if want, have := "elasticsearch", res.ClusterName; want != have {
+		t.Fatalf("expected ClusterName = %q; got: %q", want, have)
+	}
+	logger.mu.Lock()
+	logged := strings.Join(logger.msgs, "\n")
+	logger.mu.Unlock()
+	for _, path := range []string{"nodes.*.jvm.new_jvm_field", "nodes.*.new_node_field"} {
+		if !strings.Contains(logged, path) {
+			t.Fatalf("expected %q to be logged; got: %q", path, logged)
+		}
+	}
+
+	// Fail mode, with a custom Decoder
+	dec := &decoder{}
+	client, err = NewSimpleClient(SetURL(ts.URL), SetStrictDecoding(StrictDecodingFail), SetDecoder(dec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.NodesStats().Do(context.Background())
+	var uerr *UnknownFieldsError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("expected *UnknownFieldsError; got: %v", err)
+	}
+	if want, have := "*elastic.NodesStatsResponse", uerr.Type; want != have {
+		t.Fatalf("expected Type = %q; got: %q", want, have)
+	}
+	if dec.N == 0 {
+		t.Fatal("expected the custom decoder to be used")
+	}
+
+	// Invalid mode
+	if _, err := NewSimpleClient(SetURL(ts.URL), SetStrictDecoding(StrictDecodingMode(42))); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeprecationWarning is a warning returned by Elasticsearch in the
+// Warning header of a response, e.g. when using a deprecated parameter.
+//
+// See https://tools.ietf.org/html/rfc7234#section-5.5 for the format
+// of the Warning header.
+type DeprecationWarning struct {
+	Code  int       // warn-code, e.g. 299 for a persistent warning
+	Agent string    // warn-agent, e.g. "Elasticsearch-7.17.0-bee8632"
+	Text  string    // warn-text, i.e. the actual warning
+	Date  time.Time // warn-date, or the zero time if Elasticsearch didn't send it
+}
+
+// String returns the warning in the format of the Warning header.
+func (w DeprecationWarning) String() string {
+	s := fmt.Sprintf("%d %s %s", w.Code, w.Agent, strconv.Quote(w.Text))
+	if !w.Date.IsZero() {
+		s += " " + strconv.Quote(w.Date.UTC().Format(http.TimeFormat))
+	}
+	return s
+}
+
+// DeprecationHandler is called for each new deprecation warning returned
+// by Elasticsearch. Use SetDeprecationHandler to install a handler, e.g.
+// to fail tests on deprecations.
+type DeprecationHandler func(ctx context.Context, req *http.Request, warning DeprecationWarning)
+
+// SetDeprecationHandler specifies a handler that is called for every
+// deprecation warning returned by Elasticsearch. Warnings are deduplicated
+// by their text, so the handler is called only once per warning, unless
+// the client has seen many other warnings since then.
+func SetDeprecationHandler(handler DeprecationHandler) ClientOptionFunc {
+	return func(c *Client) error {
+		c.deprecationHandler = handler
+		return nil
+	}
+}
+
+// maxDeprecationsSeen limits the number of warnings remembered for
+// deduplication.
+const maxDeprecationsSeen = 1000
+
+// deprecationTracker remembers the deprecation warnings seen by the
+// client. If it is full, it forgets the least recently seen warning, so
+// that frequent warnings are never reported again. The zero value is
+// ready to use.
+type deprecationTracker struct {
+	mu   sync.Mutex
+	seen map[string]*list.Element
+	lru  list.List // texts, most recently seen first
+}
+
+// isNew returns true if the warning has not been seen before.
+func (t *deprecationTracker) isNew(warning DeprecationWarning) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if elem, found := t.seen[warning.Text]; found {
+		t.lru.MoveToFront(elem)
+		return false
+	}
+	if t.seen == nil {
+		t.seen = make(map[string]*list.Element)
+	}
+	if t.lru.Len() >= maxDeprecationsSeen {
+		oldest := t.lru.Back()
+		t.lru.Remove(oldest)
+		delete(t.seen, oldest.Value.(string))
+	}
+	t.seen[warning.Text] = t.lru.PushFront(warning.Text)
+	return true
+}
+
+// handleDeprecations logs the new deprecation warnings of a response
+// and passes them to the deprecation handler.
+func (c *Client) handleDeprecations(ctx context.Context, req *http.Request, warnings []DeprecationWarning) {
+	if len(warnings) == 0 {
+		return
+	}
+	c.mu.RLock()
+	handler := c.deprecationHandler
+	c.mu.RUnlock()
+
+	for _, warning := range warnings {
+		if !c.deprecations.isNew(warning) {
+			continue
+		}
+		c.log(ctx, LogLevelWarn, fmt.Sprintf("Deprecation warning: %s", warning.Text),
+			"method", req.Method,
+			"path", req.URL.Path,
+			"warning", warning.Text)
+		if handler != nil {
+			handler(ctx, req, warning)
+		}
+	}
+}
+
+// parseWarnings parses the Warning headers of a response. Values that
+// cannot be parsed are returned as the Text of a warning.
+func parseWarnings(header http.Header) []DeprecationWarning {
+	values := header.Values("Warning")
+	if len(values) == 0 {
+		return nil
+	}
+	warnings := make([]DeprecationWarning, 0, len(values))
+	for _, value := range values {
+		parsed, ok := parseWarningHeader(value)
+		if !ok {
+			warnings = append(warnings, DeprecationWarning{Text: value})
+			continue
+		}
+		warnings = append(warnings, parsed...)
+	}
+	return warnings
+}
+
+// parseWarningHeader parses a single Warning header, which may contain
+// a comma-separated list of warnings of the form
+//
+//	warn-code SP warn-agent SP warn-text [ SP warn-date ]
+func parseWarningHeader(value string) ([]DeprecationWarning, bool) {
+	var warnings []DeprecationWarning
+	s := strings.TrimSpace(value)
+	for s != "" {
+		var w DeprecationWarning
+
+		// warn-code
+		i := strings.IndexByte(s, ' ')
+		if i <= 0 {
+			return nil, false
+		}
+		code, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return nil, false
+		}
+		w.Code = code
+		s = strings.TrimLeft(s[i:], " ")
+
+		// warn-agent
+		i = strings.IndexByte(s, ' ')
+		if i <= 0 {
+			return nil, false
+		}
+		w.Agent = s[:i]
+		s = strings.TrimLeft(s[i:], " ")
+
+		// warn-text
+		text, rest, ok := parseQuotedString(s)
+		if !ok {
+			return nil, false
+		}
+		w.Text = text
+		s = strings.TrimLeft(rest, " ")
+
+		// warn-date (optional)
+		if strings.HasPrefix(s, `"`) {
+			date, rest, ok := parseQuotedString(s)
+			if !ok {
+				return nil, false
+			}
+			if t, err := http.ParseTime(date); err == nil {
+				w.Date = t
+			}
+			s = strings.TrimLeft(rest, " ")
+		}
+		warnings = append(warnings, w)
+
+		// Next warning, if any
+		if s != "" {
+			if s[0] != ',' {
+				return nil, false
+			}
+			s = strings.TrimLeft(s[1:], " ")
+		}
+	}
+	return warnings, len(warnings) > 0
+}
+
+// parseQuotedString parses a quoted-string at the start of s, and
+// returns its unescaped content and the rest of s.
+func parseQuotedString(s string) (string, string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", s, false
+	}
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+		case '"':
+			return sb.String(), s[i+1:], true
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", s, false
+}
@@ -0,0 +1,182 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseWarnings(t *testing.T) {
+	date := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		Header []string
+		Want   []DeprecationWarning
+	}{
+		{
+			Header: nil,
+			Want:   nil,
+		},
+		{
+			Header: []string{`299 Elasticsearch-7.17.0-bee8632 "[types removal] Specifying types in search requests is deprecated."`},
+			Want: []DeprecationWarning{
+				{Code: 299, Agent: "Elasticsearch-7.17.0-bee8632", Text: "[types removal] Specifying types in search requests is deprecated."},
+			},
+		},
+		{
+			Header: []string{`299 Elasticsearch-7.17.0-bee8632 "the \"xyz\" parameter is deprecated" "Mon, 01 Jan 2024 12:00:00 GMT"`},
+			Want: []DeprecationWarning{
+				{Code: 299, Agent: "Elasticsearch-7.17.0-bee8632", Text: `the "xyz" parameter is deprecated`, Date: date},
+			},
+		},
+		{
+			Header: []string{`299 es "first", 299 es "second" "Mon, 01 Jan 2024 12:00:00 GMT"`, `199 - "third"`},
+			Want: []DeprecationWarning{
+				{Code: 299, Agent: "es", Text: "first"},
+				{Code: 299, Agent: "es", Text: "second", Date: date},
+				{Code: 199, Agent: "-", Text: "third"},
+			},
+		},
+		{
+			Header: []string{`not a warning`, `299 es "unterminated`},
+			Want: []DeprecationWarning{
+				{Text: "not a warning"},
+				{Text: `299 es "unterminated`},
+			},
+		},
+	}
+	for i, tt := range tests {
+		header := make(http.Header)
+		for _, value := range tt.Header {
+			header.Add("Warning", value)
+		}
+		have := parseWarnings(header)
+		if len(tt.Want) != len(have) {
+			t.Fatalf("#%d: expected %d warnings; got: %d (%v)", i, len(tt.Want), len(have), have)
+		}
+		for j := range tt.Want {
+			want := tt.Want[j]
+			if want.Code != have[j].Code || want.Agent != have[j].Agent || want.Text != have[j].Text || !want.Date.Equal(have[j].Date) {
+				t.Fatalf("#%d.%d: expected %+v; got: %+v", i, j, want, have[j])
+			}
+		}
+	}
+}
+
+func TestDeprecationWarningString(t *testing.T) {
+	w := DeprecationWarning{
+		Code:  299,
+		Agent: "Elasticsearch-7.17.0",
+		Text:  `the "xyz" parameter is deprecated`,
+		Date:  time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC),
+	}
+	want := `299 Elasticsearch-7.17.0 "the \"xyz\" parameter is deprecated" "Mon, 01 Jan 2024 12:00:00 GMT"`
+	if have := w.String(); want != have {
+		t.Fatalf("expected %q; got: %q", want, have)
+	}
+	parsed := parseWarnings(http.Header{"Warning": []string{w.String()}})
+	if len(parsed) != 1 || parsed[0].Text != w.Text || !parsed[0].Date.Equal(w.Date) {
+		t.Fatalf("expected %+v; got: %+v", w, parsed)
+	}
+}
+
+func TestDeprecationHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Warning", `299 Elasticsearch-7.17.0 "first deprecation"`)
+		if r.URL.Path == "/twitter/_search" {
+			w.Header().Add("Warning", `299 Elasticsearch-7.17.0 "second deprecation"`)
+		}
+		w.Write([]byte(`{"took":1,"hits":{"total":{"value":0,"relation":"eq"},"hits":[]}}`))
+	}))
+	defer ts.Close()
+
+	var (
+		mu       sync.Mutex
+		handled  []DeprecationWarning
+		requests []string
+	)
+	client, err := NewSimpleClient(
+		SetURL(ts.URL),
+		SetDeprecationHandler(func(ctx context.Context, req *http.Request, warning DeprecationWarning) {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, warning)
+			requests = append(requests, req.URL.Path)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each response has all of its warnings
+	for i := 0; i < 3; i++ {
+		res, err := client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Warnings) != 1 || res.Warnings[0].Text != "first deprecation" {
+			t.Fatalf("expected 1 warning; got: %+v", res.Warnings)
+		}
+	}
+	searchResult, err := client.Search("twitter").Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, len(searchResult.Warnings); want != have {
+		t.Fatalf("expected %d warnings on SearchResult; got: %d", want, have)
+	}
+
+	// The handler is called only once per warning
+	mu.Lock()
+	defer mu.Unlock()
+	if want, have := 2, len(handled); want != have {
+		t.Fatalf("expected %d calls to the deprecation handler; got: %d (%+v)", want, have, handled)
+	}
+	if want, have := "first deprecation", handled[0].Text; want != have {
+		t.Fatalf("expected %q; got: %q", want, have)
+	}
+	if want, have := 299, handled[0].Code; want != have {
+		t.Fatalf("expected Code = %d; got: %d", want, have)
+	}
+	if want, have := "second deprecation", handled[1].Text; want != have {
+		t.Fatalf("expected %q; got: %q", want, have)
+	}
+	if want, have := "/twitter/_search", requests[1]; want != have {
+		t.Fatalf("expected request path %q; got: %q", want, have)
+	}
+}
+
+func TestDeprecationTrackerIsBounded(t *testing.T) {
+	var tracker deprecationTracker
+	if !tracker.isNew(DeprecationWarning{Text: "frequent"}) {
+		t.Fatal("expected warning to be new")
+	}
+	for i := 0; i < 2*maxDeprecationsSeen; i++ {
+		tracker.isNew(DeprecationWarning{Text: time.Duration(i).String()})
+		if tracker.isNew(DeprecationWarning{Text: "frequent"}) {
+			t.Fatalf("expected frequent warning to be remembered after %d other warnings", i+1)
+		}
+	}
+	if have := len(tracker.seen); have > maxDeprecationsSeen {
+		t.Fatalf("expected at most %d warnings to be remembered; got: %d", maxDeprecationsSeen, have)
+	}
+	if have := tracker.lru.Len(); have != len(tracker.seen) {
+		t.Fatalf("expected %d warnings in the LRU list; got: %d", len(tracker.seen), have)
+	}
+	if !tracker.isNew(DeprecationWarning{Text: time.Duration(0).String()}) {
+		t.Fatal("expected least recently seen warning to be forgotten")
+	}
+	if !tracker.isNew(DeprecationWarning{Text: "new"}) {
+		t.Fatal("expected warning to be new")
+	}
+	if tracker.isNew(DeprecationWarning{Text: "new"}) {
+		t.Fatal("expected warning to be seen")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Encoder is used to encode the bodies of requests sent to Elasticsearch.
+// Users of elastic can implement their own marshaler for advanced purposes,
+// e.g. to use a faster JSON library, and set them per Client (see
+// SetEncoder). If none is specified, DefaultEncoder is used.
+//
+// The writer passed to Encode is backed by a pooled buffer, so the
+// encoder should write to it directly instead of allocating its own
+// buffers where possible.
+//
+// Bodies of type string and json.RawMessage are sent as is and are never
+// passed to the Encoder.
+//
+// The Encoder is also used for the action lines and documents of bulk
+// requests added to a BulkService (unless they use easyjson). These must
+// be encoded on a single line, i.e. the Encoder must not indent its output.
+type Encoder interface {
+	Encode(w io.Writer, v interface{}) error
+}
+
+// DefaultEncoder uses json.Marshal from the Go standard library
+// to encode JSON data.
+type DefaultEncoder struct{}
+
+// Encode encodes with json.Marshal from the Go standard library.
+func (e *DefaultEncoder) Encode(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// encodeBody writes the JSON representation of v to w. A json.RawMessage
+// is written as is, everything else is encoded with enc, or
+// DefaultEncoder if enc is nil.
+func encodeBody(w io.Writer, v interface{}, enc Encoder) error {
+	if raw, ok := v.(json.RawMessage); ok {
+		if raw == nil {
+			raw = json.RawMessage("null")
+		}
+		_, err := w.Write(raw)
+		return err
+	}
+	if enc == nil {
+		enc = &DefaultEncoder{}
+	}
+	return enc.Encode(w, v)
+}
+
+// encodeBulkLine returns the JSON representation of v for a line of a
+// bulk request, encoded with enc into a pooled buffer. Trailing newlines,
+// e.g. of a json.Encoder, are removed.
+func encodeBulkLine(v interface{}, enc Encoder) ([]byte, error) {
+	buf := getBodyBuffer()
+	defer putBodyBuffer(buf)
+	if err := encodeBody(buf, v, enc); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), bytes.TrimRight(buf.Bytes(), "\n")...), nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type encoder struct {
+	N int64
+}
+
+func (e *encoder) Encode(w io.Writer, v interface{}) error {
+	atomic.AddInt64(&e.N, 1)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// bodyRecorder is a test server that records the last request body.
+func bodyRecorder(t *testing.T, body *string, contentEncoding *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rd io.Reader = r.Body
+		*contentEncoding = r.Header.Get("Content-Encoding")
+		if *contentEncoding == "gzip" {
+			gr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("expected gzip body; got: %v", err)
+				return
+			}
+			rd = gr
+		}
+		data, err := ioutil.ReadAll(rd)
+		if err != nil {
+			t.Errorf("expected to read body; got: %v", err)
+		}
+		*body = string(data)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+}
+
+func TestEncoder(t *testing.T) {
+	for _, gzipEnabled := range []bool{false, true} {
+		var body, contentEncoding string
+		ts := bodyRecorder(t, &body, &contentEncoding)
+
+		enc := &encoder{}
+		client, err := NewSimpleClient(SetURL(ts.URL), SetEncoder(enc), SetGzip(gzipEnabled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Bodies are encoded with the Encoder
+		_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+			Method: "POST",
+			Path:   "/",
+			Body:   map[string]interface{}{"query": map[string]interface{}{"match_all": struct{}{}}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := int64(1), enc.N; want != have {
+			t.Fatalf("gzip=%v: expected %d calls of encoder; got: %d", gzipEnabled, want, have)
+		}
+		if want, have := "{\"query\":{\"match_all\":{}}}\n", body; want != have {
+			t.Fatalf("gzip=%v: expected body %q; got: %q", gzipEnabled, want, have)
+		}
+		if gzipEnabled && contentEncoding != "gzip" {
+			t.Fatalf("expected Content-Encoding = %q; got: %q", "gzip", contentEncoding)
+		}
+
+		// Pre-encoded JSON is sent as is
+		_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+			Method: "POST",
+			Path:   "/",
+			Body:   json.RawMessage(`{ "query": { "match_all": {} } }`),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := int64(1), enc.N; want != have {
+			t.Fatalf("gzip=%v: expected %d calls of encoder; got: %d", gzipEnabled, want, have)
+		}
+		if want, have := `{ "query": { "match_all": {} } }`, body; want != have {
+			t.Fatalf("gzip=%v: expected body %q; got: %q", gzipEnabled, want, have)
+		}
+		ts.Close()
+	}
+}
+
+func TestEncoderForBulkRequests(t *testing.T) {
+	enc := &encoder{}
+	client, err := NewSimpleClient(SetURL("http://127.0.0.1:0"), SetEncoder(enc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Source was called before the request is added, e.g. for validation
+	index := NewBulkIndexRequest().Index("twitter").Id("1").Doc(tweet{User: "olivere"})
+	if _, err := index.Source(); err != nil {
+		t.Fatal(err)
+	}
+	update := NewBulkUpdateRequest().Index("twitter").Id("2").Doc(map[string]interface{}{"retweets": 1})
+	bulk := client.Bulk().Add(index, update, NewBulkDeleteRequest().Index("twitter").Id("3"))
+	body, err := bulk.bodyAsString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(5), atomic.LoadInt64(&enc.N); want != have {
+		t.Fatalf("expected %d calls of encoder; got: %d", want, have)
+	}
+	expected := `{"index":{"_index":"twitter","_id":"1"}}
+{"user":"olivere","message":"","retweets":0,"created":"0001-01-01T00:00:00Z"}
+{"update":{"_index":"twitter","_id":"2"}}
+{"doc":{"retweets":1}}
+{"delete":{"_index":"twitter","_id":"3"}}
+`
+	if body != expected {
+		t.Fatalf("expected\n%s\ngot:\n%s", expected, body)
+	}
+}
+
+func TestEncoderDefault(t *testing.T) {
+	req, err := NewRequest("POST", "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.SetBody(map[string]interface{}{"a": "b"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(len(`{"a":"b"}`)), req.ContentLength; want != have {
+		t.Fatalf("expected ContentLength = %d; got: %d", want, have)
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `{"a":"b"}`, string(data); want != have {
+		t.Fatalf("expected body %q; got: %q", want, have)
+	}
+	if err := req.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPooledBody(t *testing.T) {
+	buf := getBodyBuffer()
+	buf.WriteString("hello")
+	body := newPooledBody(buf)
+	if want, have := 5, body.Len(); want != have {
+		t.Fatalf("expected Len = %d; got: %d", want, have)
+	}
+	p := make([]byte, 2)
+	if n, err := body.Read(p); err != nil || n != 2 || string(p) != "he" {
+		t.Fatalf("expected to read %q; got: %q (%v)", "he", p[:n], err)
+	}
+	if err := body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := body.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := body.Read(p); err != io.EOF || n != 0 {
+		t.Fatalf("expected io.EOF after Close; got: %d, %v", n, err)
+	}
+	if want, have := 0, body.Len(); want != have {
+		t.Fatalf("expected Len = %d; got: %d", want, have)
+	}
+}
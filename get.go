@@ -42,6 +42,7 @@ type GetService struct {
 	versionType                   string
 	parent                        string
 	ignoreErrorsOnGeneratedFields *bool
+	hedge                         HedgePolicy
 }
 
 // NewGetService creates a new GetService.
@@ -184,6 +185,14 @@ func (s *GetService) IgnoreErrorsOnGeneratedFields(ignore bool) *GetService {
 	return s
 }
 
+// Hedge enables hedged requests: If no response arrives in time, a
+// duplicate request is sent to a different node, and the first response
+// wins (see HedgePolicy).
+func (s *GetService) Hedge(policy HedgePolicy) *GetService {
+	s.hedge = policy
+	return s
+}
+
 // Validate checks if the operation is valid.
 func (s *GetService) Validate() error {
 	var invalid []string
@@ -282,6 +291,7 @@ func (s *GetService) Do(ctx context.Context) (*GetResult, error) {
 		Path:    path,
 		Params:  params,
 		Headers: s.headers,
+		Hedge:   s.hedge,
 	})
 	if err != nil {
 		return nil, err
@@ -1,6 +1,6 @@
 module github.com/olivere/elastic/v7
 
-go 1.17
+go 1.18
 
 require (
 	github.com/aws/aws-sdk-go v1.43.21
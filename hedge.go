@@ -0,0 +1,279 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HedgePolicy decides when to send a hedged request, i.e. a duplicate of
+// a request that is sent to a different node if the original request
+// didn't return in time. The first response wins, and the other request
+// is cancelled.
+//
+// Hedging only applies to idempotent requests like searches or
+// getting documents, and not to streaming requests. It is enabled per
+// request, e.g. via SearchService.Hedge or PerformRequestOptions.Hedge.
+type HedgePolicy interface {
+	// Delay returns the time to wait for a response before sending the
+	// hedged request. A value <= 0 disables hedging.
+	Delay() time.Duration
+
+	// Observe is called with the latency of every request that uses
+	// this policy.
+	Observe(took time.Duration)
+}
+
+// -- FixedHedgePolicy --
+
+// FixedHedgePolicy sends a hedged request after a fixed delay.
+type FixedHedgePolicy struct {
+	delay time.Duration
+}
+
+// NewFixedHedgePolicy returns a HedgePolicy that sends a hedged request
+// after the given delay.
+func NewFixedHedgePolicy(delay time.Duration) *FixedHedgePolicy {
+	return &FixedHedgePolicy{delay: delay}
+}
+
+// Delay returns the fixed delay.
+func (p *FixedHedgePolicy) Delay() time.Duration {
+	return p.delay
+}
+
+// Observe is a no-op.
+func (p *FixedHedgePolicy) Observe(took time.Duration) {}
+
+// -- PercentileHedgePolicy --
+
+// PercentileHedgePolicy sends a hedged request after a delay that is
+// derived from the observed latencies, e.g. its 95th percentile. That way,
+// only the slowest requests are hedged.
+type PercentileHedgePolicy struct {
+	mu         sync.Mutex
+	percentile float64
+	minDelay   time.Duration
+	minSamples int
+	samples    []time.Duration // ring buffer of latencies
+	next       int             // next index into samples
+	full       bool            // true if the ring buffer has wrapped
+	dirty      int             // number of samples since delay was computed
+	delay      time.Duration   // cached delay
+}
+
+// NewPercentileHedgePolicy returns a HedgePolicy that sends a hedged
+// request after the given percentile in (0..1] of the latencies of the
+// last 1000 requests, e.g. 0.95. Hedging is disabled until 20 requests
+// have been observed.
+func NewPercentileHedgePolicy(percentile float64) *PercentileHedgePolicy {
+	if percentile <= 0 || percentile > 1 {
+		percentile = 0.95
+	}
+	return &PercentileHedgePolicy{
+		percentile: percentile,
+		minSamples: 20,
+		samples:    make([]time.Duration, 1000),
+	}
+}
+
+// MinDelay sets the lower bound of the delay. It prevents hedging too
+// aggressively when latencies are very low.
+func (p *PercentileHedgePolicy) MinDelay(minDelay time.Duration) *PercentileHedgePolicy {
+	p.mu.Lock()
+	p.minDelay = minDelay
+	p.delay = 0 // recompute
+	p.mu.Unlock()
+	return p
+}
+
+// MinSamples sets the number of requests that need to be observed
+// before hedging is enabled (20 by default).
+func (p *PercentileHedgePolicy) MinSamples(n int) *PercentileHedgePolicy {
+	p.mu.Lock()
+	p.minSamples = n
+	p.mu.Unlock()
+	return p
+}
+
+// Delay returns the percentile of the observed latencies.
+func (p *PercentileHedgePolicy) Delay() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := p.next
+	if p.full {
+		n = len(p.samples)
+	}
+	if n == 0 || n < p.minSamples {
+		return 0
+	}
+	// Recompute only every now and then
+	if p.delay > 0 && p.dirty < 16 {
+		return p.delay
+	}
+	sorted := make([]time.Duration, n)
+	copy(sorted, p.samples[:n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(float64(n)*p.percentile+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= n {
+		i = n - 1
+	}
+	p.delay = sorted[i]
+	if p.delay < p.minDelay {
+		p.delay = p.minDelay
+	}
+	p.dirty = 0
+	return p.delay
+}
+
+// Observe adds the latency of a request.
+func (p *PercentileHedgePolicy) Observe(took time.Duration) {
+	p.mu.Lock()
+	p.samples[p.next] = took
+	p.next++
+	if p.next >= len(p.samples) {
+		p.next = 0
+		p.full = true
+	}
+	p.dirty++
+	p.mu.Unlock()
+}
+
+// -- Hedged requests --
+
+// isIdempotentRequest returns true if the request with the given method
+// and path can be sent more than once without side effects. Besides GET
+// and HEAD, this includes the read-only APIs that are sent via POST,
+// e.g. searches. Scrolling is never idempotent, as it moves the cursor.
+func isIdempotentRequest(method, path string) bool {
+	if strings.Contains(path, "/_search/scroll") {
+		return false
+	}
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD":
+		return true
+	case "POST":
+		switch path[strings.LastIndex(path, "/")+1:] {
+		case "_search", "_msearch", "_count", "_mget", "_field_caps":
+			return true
+		}
+	}
+	return false
+}
+
+// hasConn returns true if there is a connection that is alive and
+// accepted by the given filter.
+func (c *Client) hasConn(filter NodeFilter) bool {
+	c.connsMu.RLock()
+	defer c.connsMu.RUnlock()
+	for _, conn := range c.conns {
+		if !conn.IsDead() && filter(conn) {
+			return true
+		}
+	}
+	return false
+}
+
+// hedgeResult is the outcome of one of the requests of a hedged request.
+type hedgeResult struct {
+	resp  *Response
+	err   error
+	start time.Time
+}
+
+// performHedgedRequest sends the request and, if it doesn't return
+// within the delay of the hedge policy, a duplicate request to a
+// different node. The first response wins, and the other request is
+// cancelled.
+func (c *Client) performHedgedRequest(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
+	policy := opt.Hedge
+	opt.Hedge = nil
+
+	filter := opt.NodeFilter
+	if filter == nil {
+		c.mu.RLock()
+		filter = c.nodeFilter
+		c.mu.RUnlock()
+	}
+
+	// Remember the connections used, so the hedged request goes elsewhere
+	var usedMu sync.Mutex
+	used := make(map[*conn]bool)
+	connUsed := func(conn *conn) {
+		usedMu.Lock()
+		used[conn] = true
+		usedMu.Unlock()
+	}
+	unused := func(candidate Connection) bool {
+		usedMu.Lock()
+		defer usedMu.Unlock()
+		cn, ok := candidate.(*conn)
+		return filter(candidate) && !(ok && used[cn])
+	}
+
+	results := make(chan hedgeResult, 2)
+	send := func(ctx context.Context, opt PerformRequestOptions) {
+		start := time.Now()
+		resp, err := c.performRequest(ctx, opt, connUsed)
+		results <- hedgeResult{resp: resp, err: err, start: start}
+	}
+
+	primaryCtx, cancelPrimary := context.WithCancel(ctx)
+	defer cancelPrimary()
+	go send(primaryCtx, opt)
+	pending := 1
+
+	var hedgeTimer <-chan time.Time
+	if delay := policy.Delay(); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedgeTimer = timer.C
+	}
+
+	var hedgeCtx context.Context
+	var cancelHedge context.CancelFunc
+	var last hedgeResult
+	for pending > 0 {
+		select {
+		case <-hedgeTimer:
+			hedgeTimer = nil
+			if !c.hasConn(unused) {
+				continue // no other node to send the request to
+			}
+			hedgeOpt := opt
+			hedgeOpt.NodeFilter = unused
+			hedgeCtx, cancelHedge = context.WithCancel(ctx)
+			defer cancelHedge()
+			c.log(ctx, LogLevelInfo, fmt.Sprintf("elastic: sending hedged request %s %s", strings.ToUpper(opt.Method), opt.Path),
+				"method", strings.ToUpper(opt.Method),
+				"path", opt.Path)
+			go send(hedgeCtx, hedgeOpt)
+			pending++
+		case res := <-results:
+			pending--
+			last = res
+			if res.resp == nil && res.err != nil && pending > 0 {
+				continue // no response; wait for the other request
+			}
+			policy.Observe(time.Since(res.start))
+			// Cancel the other request (if any); results is buffered,
+			// so it won't block
+			cancelPrimary()
+			if cancelHedge != nil {
+				cancelHedge()
+			}
+			return res.resp, res.err
+		}
+	}
+	return last.resp, last.err
+}
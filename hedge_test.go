@@ -0,0 +1,172 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsIdempotentRequest(t *testing.T) {
+	tests := []struct {
+		Method   string
+		Path     string
+		Expected bool
+	}{
+		{"GET", "/twitter/_doc/1", true},
+		{"HEAD", "/twitter/_doc/1", true},
+		{"POST", "/twitter/_search", true},
+		{"POST", "/_search", true},
+		{"POST", "/twitter/_count", true},
+		{"GET", "/_msearch", true},
+		{"POST", "/twitter/_doc", false},
+		{"PUT", "/twitter/_doc/1", false},
+		{"DELETE", "/twitter/_doc/1", false},
+		{"POST", "/_bulk", false},
+		{"POST", "/_search/scroll", false},
+		{"GET", "/_search/scroll", false},
+	}
+	for _, test := range tests {
+		if want, have := test.Expected, isIdempotentRequest(test.Method, test.Path); want != have {
+			t.Errorf("%s %s: expected %v; got: %v", test.Method, test.Path, want, have)
+		}
+	}
+}
+
+func TestPercentileHedgePolicy(t *testing.T) {
+	p := NewPercentileHedgePolicy(0.95).MinSamples(10)
+	for i := 1; i <= 9; i++ {
+		p.Observe(time.Duration(i) * time.Millisecond)
+	}
+	if want, have := time.Duration(0), p.Delay(); want != have {
+		t.Fatalf("expected %v before enough samples; got: %v", want, have)
+	}
+	for i := 10; i <= 100; i++ {
+		p.Observe(time.Duration(i) * time.Millisecond)
+	}
+	if want, have := 95*time.Millisecond, p.Delay(); want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+
+	p.MinDelay(time.Second)
+	if want, have := time.Second, p.Delay(); want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+}
+
+func newHedgeTestServer(delay time.Duration, hits, cancelled *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(hits, 1)
+		io.Copy(ioutil.Discard, r.Body) // cancellation is only detected after reading the body
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			atomic.AddInt64(cancelled, 1)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"delay":%q}`, delay)
+	}))
+}
+
+func TestClientHedgedRequest(t *testing.T) {
+	var slowHits, slowCancelled, fastHits, fastCancelled int64
+	slow := newHedgeTestServer(2*time.Second, &slowHits, &slowCancelled)
+	defer slow.Close()
+	fast := newHedgeTestServer(0, &fastHits, &fastCancelled)
+	defer fast.Close()
+
+	client, err := NewClient(SetURL(slow.URL, fast.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	res, err := client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "POST",
+		Path:   "/twitter/_search",
+		Body:   `{"query":{"match_all":{}}}`,
+		Hedge:  NewFixedHedgePolicy(20 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("expected hedged request to return early; took %v", took)
+	}
+	if want, have := `{"delay":"0s"}`, string(res.Body); want != have {
+		t.Fatalf("expected response %s; got: %s", want, have)
+	}
+	if want, have := int64(1), atomic.LoadInt64(&slowHits); want != have {
+		t.Fatalf("expected %d requests to slow node; got: %d", want, have)
+	}
+	if want, have := int64(1), atomic.LoadInt64(&fastHits); want != have {
+		t.Fatalf("expected %d requests to fast node; got: %d", want, have)
+	}
+
+	// The slow request must have been cancelled
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&slowCancelled) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if want, have := int64(1), atomic.LoadInt64(&slowCancelled); want != have {
+		t.Fatalf("expected slow request to be cancelled; got: %d", have)
+	}
+}
+
+func TestClientHedgedRequestIgnoredForNonIdempotentRequests(t *testing.T) {
+	var slowHits, slowCancelled, fastHits, fastCancelled int64
+	slow := newHedgeTestServer(100*time.Millisecond, &slowHits, &slowCancelled)
+	defer slow.Close()
+	fast := newHedgeTestServer(0, &fastHits, &fastCancelled)
+	defer fast.Close()
+
+	client, err := NewClient(SetURL(slow.URL, fast.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "POST",
+		Path:   "/twitter/_doc",
+		Body:   `{"user":"olivere"}`,
+		Hedge:  NewFixedHedgePolicy(10 * time.Millisecond),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(1), atomic.LoadInt64(&slowHits); want != have {
+		t.Fatalf("expected %d requests to slow node; got: %d", want, have)
+	}
+	if want, have := int64(0), atomic.LoadInt64(&fastHits); want != have {
+		t.Fatalf("expected %d requests to fast node; got: %d", want, have)
+	}
+}
+
+func TestClientHedgedRequestWithSingleNode(t *testing.T) {
+	var hits, cancelled int64
+	ts := newHedgeTestServer(50*time.Millisecond, &hits, &cancelled)
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get().Index("twitter").Id("1").Hedge(NewFixedHedgePolicy(10 * time.Millisecond)).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(1), atomic.LoadInt64(&hits); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+}
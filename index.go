@@ -226,13 +226,19 @@ func (s *IndexService) buildURL() (string, string, url.Values, error) {
 	var err error
 	var method, path string
 
+	typ := s.typ
+	if s.client.compatibilityEnabled() {
+		// Mapping types have been removed in Elasticsearch 8
+		typ = "_doc"
+	}
+
 	if s.id != "" {
 		// Create document with manual id
 		method = "PUT"
 		path, err = uritemplates.Expand("/{index}/{type}/{id}", map[string]string{
 			"id":    s.id,
 			"index": s.index,
-			"type":  s.typ,
+			"type":  typ,
 		})
 	} else {
 		// Automatic ID generation
@@ -240,7 +246,7 @@ func (s *IndexService) buildURL() (string, string, url.Values, error) {
 		method = "POST"
 		path, err = uritemplates.Expand("/{index}/{type}/", map[string]string{
 			"index": s.index,
-			"type":  s.typ,
+			"type":  typ,
 		})
 	}
 	if err != nil {
@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -191,7 +190,7 @@ func (s *IngestSimulatePipelineService) Do(ctx context.Context) (*IngestSimulate
 
 	// Return operation response
 	ret := new(IngestSimulatePipelineResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -0,0 +1,99 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RequestHandler performs a single attempt of a request to Elasticsearch.
+type RequestHandler func(ctx context.Context, opt PerformRequestOptions) (*Response, error)
+
+// RequestInterceptor intercepts every attempt of a request to
+// Elasticsearch, including retries. It may inspect or change the options
+// of the request, e.g. the path, the parameters, the headers, or the body
+// before it is serialized, then calls next to perform the attempt, and
+// may inspect or change the response or error returned.
+//
+// An interceptor can skip the request by not calling next. The options
+// passed to an interceptor are a copy, so changes to the parameters and
+// headers don't leak into subsequent attempts.
+//
+// If the attempt is to be retried, next returns an error for which
+// IsRetry returns true, and the request is retried if the chain of
+// interceptors returns that error. An interceptor can prevent the retry
+// by returning a response or another error instead.
+//
+// Use SetRequestInterceptors to install interceptors. Example:
+//
+//	tenant := func(ctx context.Context, opt elastic.PerformRequestOptions, next elastic.RequestHandler) (*elastic.Response, error) {
+//	  if opt.Headers == nil {
+//	    opt.Headers = http.Header{}
+//	  }
+//	  opt.Headers.Set("X-Tenant", "acme")
+//	  return next(ctx, opt)
+//	}
+//	client, err := elastic.NewClient(elastic.SetRequestInterceptors(tenant))
+type RequestInterceptor func(ctx context.Context, opt PerformRequestOptions, next RequestHandler) (*Response, error)
+
+// retryError is returned from an attempt of a request that is to be
+// retried after waiting for the given duration.
+type retryError struct {
+	wait time.Duration
+	err  error
+}
+
+// Error returns the error of the attempt.
+func (e *retryError) Error() string {
+	if e.err == nil {
+		return "elastic: retrying request"
+	}
+	return e.err.Error()
+}
+
+// Cause returns the error of the attempt.
+func (e *retryError) Cause() error { return e.err }
+
+// Unwrap returns the error of the attempt.
+func (e *retryError) Unwrap() error { return e.err }
+
+// IsRetry returns true if err was returned by an attempt of a request
+// that is going to be retried (see RequestInterceptor). Use errors.Cause
+// to get the error of the attempt.
+func IsRetry(err error) bool {
+	var retry *retryError
+	return errors.As(err, &retry)
+}
+
+// chainRequestInterceptors returns a RequestHandler that runs the
+// interceptors in order, with handler being the innermost one.
+func chainRequestInterceptors(interceptors []RequestInterceptor, handler RequestHandler) RequestHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
+			return interceptor(ctx, opt, next)
+		}
+	}
+	return handler
+}
+
+// clone returns a copy of the options with its own parameters and headers.
+func (opt PerformRequestOptions) clone() PerformRequestOptions {
+	if opt.Params != nil {
+		params := make(url.Values, len(opt.Params))
+		for k, v := range opt.Params {
+			params[k] = append([]string(nil), v...)
+		}
+		opt.Params = params
+	}
+	if opt.Headers != nil {
+		opt.Headers = opt.Headers.Clone()
+	}
+	return opt
+}
@@ -0,0 +1,222 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRequestInterceptorsOrder(t *testing.T) {
+	var path, tenant string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		tenant = r.Header.Get("X-Tenant")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	var calls []string
+	rewrite := func(ctx context.Context, opt PerformRequestOptions, next RequestHandler) (*Response, error) {
+		calls = append(calls, "rewrite:before")
+		opt.Path = strings.Replace(opt.Path, "/twitter/", "/acme-twitter/", 1)
+		res, err := next(ctx, opt)
+		calls = append(calls, "rewrite:after")
+		return res, err
+	}
+	tenantHeader := func(ctx context.Context, opt PerformRequestOptions, next RequestHandler) (*Response, error) {
+		calls = append(calls, "tenant:before")
+		if opt.Headers == nil {
+			opt.Headers = http.Header{}
+		}
+		opt.Headers.Set("X-Tenant", "acme")
+		res, err := next(ctx, opt)
+		calls = append(calls, "tenant:after")
+		return res, err
+	}
+
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRequestInterceptors(rewrite, tenantHeader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "POST",
+		Path:   "/twitter/_search",
+		Body:   map[string]interface{}{"query": map[string]interface{}{"match_all": struct{}{}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "/acme-twitter/_search", path; want != have {
+		t.Fatalf("expected path %q; got: %q", want, have)
+	}
+	if want, have := "acme", tenant; want != have {
+		t.Fatalf("expected header %q; got: %q", want, have)
+	}
+	expected := []string{"rewrite:before", "tenant:before", "tenant:after", "rewrite:after"}
+	if want, have := strings.Join(expected, ","), strings.Join(calls, ","); want != have {
+		t.Fatalf("expected calls %s; got: %s", want, have)
+	}
+}
+
+func TestRequestInterceptorsRunOnEveryAttempt(t *testing.T) {
+	var requests int64
+	var headerValues []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headerValues = append(headerValues, len(r.Header["X-Attempt"]))
+		if atomic.AddInt64(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status":503}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer ts.Close()
+
+	var statuses []int
+	audit := func(ctx context.Context, opt PerformRequestOptions, next RequestHandler) (*Response, error) {
+		opt.Headers.Add("X-Attempt", "1")
+		res, err := next(ctx, opt)
+		switch {
+		case err != nil:
+			if e, ok := errors.Cause(err).(*Error); ok {
+				statuses = append(statuses, e.Status)
+			}
+		case res != nil:
+			statuses = append(statuses, res.StatusCode)
+		}
+		return res, err
+	}
+
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetrier(NewBackoffRetrier(ZeroBackoff{})),
+		SetRetryStatusCodes(http.StatusServiceUnavailable),
+		SetRequestInterceptors(audit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers := http.Header{}
+	res, err := client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method:  "GET",
+		Path:    "/",
+		Headers: headers,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		OK bool `json:"ok"`
+	}
+	if err := json.Unmarshal(res.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if !body.OK {
+		t.Fatalf("expected response of last attempt; got: %s", string(res.Body))
+	}
+	if want, have := "503,503,200", joinInts(statuses); want != have {
+		t.Fatalf("expected statuses %s; got: %s", want, have)
+	}
+	if want, have := "1,1,1", joinInts(headerValues); want != have {
+		t.Fatalf("expected header values per attempt %s; got: %s", want, have)
+	}
+	if len(headers) > 0 {
+		t.Fatalf("expected headers of caller to be unchanged; got: %v", headers)
+	}
+}
+
+func TestRequestInterceptorsCanPreventRetry(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"status":503}`))
+	}))
+	defer ts.Close()
+
+	// The interceptor serves a fallback instead of retrying
+	fallback := func(ctx context.Context, opt PerformRequestOptions, next RequestHandler) (*Response, error) {
+		res, err := next(ctx, opt)
+		if IsRetry(err) {
+			return &Response{StatusCode: http.StatusOK, Body: []byte(`{"fallback":true}`)}, nil
+		}
+		return res, err
+	}
+
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetrier(NewBackoffRetrier(ZeroBackoff{})),
+		SetRetryStatusCodes(http.StatusServiceUnavailable),
+		SetRequestInterceptors(fallback))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `{"fallback":true}`, string(res.Body); want != have {
+		t.Fatalf("expected response %s; got: %s", want, have)
+	}
+	if want, have := int64(1), atomic.LoadInt64(&requests); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+}
+
+func TestRequestInterceptorsShortCircuit(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+	}))
+	defer ts.Close()
+
+	cached := func(ctx context.Context, opt PerformRequestOptions, next RequestHandler) (*Response, error) {
+		return &Response{StatusCode: http.StatusOK, Body: json.RawMessage(`{"cached":true}`)}, nil
+	}
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRequestInterceptors(cached))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `{"cached":true}`, string(res.Body); want != have {
+		t.Fatalf("expected %s; got: %s", want, have)
+	}
+	if want, have := int64(0), atomic.LoadInt64(&requests); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
@@ -4,7 +4,133 @@
 
 package elastic
 
+import "context"
+
 // Logger specifies the interface for all log operations.
 type Logger interface {
 	Printf(format string, v ...interface{})
 }
+
+// LogLevel is the severity of a log message. The values are the same
+// as those of the levels in the log/slog package, plus LogLevelTrace
+// for dumps of HTTP requests and responses.
+type LogLevel int
+
+const (
+	// LogLevelTrace is used for dumps of HTTP requests and responses.
+	LogLevelTrace LogLevel = -8
+	// LogLevelDebug is used for details like retries or bulk commits.
+	LogLevelDebug LogLevel = -4
+	// LogLevelInfo is used for informational messages, e.g. requests
+	// and their response times.
+	LogLevelInfo LogLevel = 0
+	// LogLevelWarn is used for problems the client recovers from.
+	LogLevelWarn LogLevel = 4
+	// LogLevelError is used for critical messages like nodes leaving
+	// the cluster or failing requests.
+	LogLevelError LogLevel = 8
+)
+
+// String returns a textual representation of the level.
+func (l LogLevel) String() string {
+	switch {
+	case l <= LogLevelTrace:
+		return "TRACE"
+	case l <= LogLevelDebug:
+		return "DEBUG"
+	case l <= LogLevelInfo:
+		return "INFO"
+	case l <= LogLevelWarn:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// StructuredLogger specifies the interface for leveled logging with
+// key/value pairs, e.g. "method", "GET", "status", 200. It has the same
+// shape as *slog.Logger, so an adapter is trivial:
+//
+//	type slogLogger struct{ *slog.Logger }
+//
+//	func (l slogLogger) Enabled(ctx context.Context, level elastic.LogLevel) bool {
+//		return l.Logger.Enabled(ctx, slog.Level(level))
+//	}
+//
+//	func (l slogLogger) Log(ctx context.Context, level elastic.LogLevel, msg string, keyvals ...interface{}) {
+//		l.Logger.Log(ctx, slog.Level(level), msg, keyvals...)
+//	}
+//
+// The keys used by the client are: "method", "path", "url", "node",
+// "status", "duration", "attempt", "wait", "error", "nodes", "processor",
+// "worker", "execution_id", "requests", "warning", "from" and "to".
+//
+// Implementations must be safe for concurrent use. Use SetLogger to
+// install a StructuredLogger.
+type StructuredLogger interface {
+	// Enabled returns true if messages of the given level are logged.
+	Enabled(ctx context.Context, level LogLevel) bool
+
+	// Log logs msg with the given level and key/value pairs.
+	Log(ctx context.Context, level LogLevel, msg string, keyvals ...interface{})
+}
+
+// printfLogger adapts the loggers of SetErrorLog, SetInfoLog and
+// SetTraceLog to a StructuredLogger. It only prints the message, so the
+// output is the same as before structured logging was introduced.
+type printfLogger struct {
+	errorlog Logger // used for LogLevelWarn and above
+	infolog  Logger // used for LogLevelInfo
+	tracelog Logger // used for LogLevelDebug and below
+}
+
+// logger returns the Logger to use for the given level, or nil.
+func (l printfLogger) logger(level LogLevel) Logger {
+	switch {
+	case level >= LogLevelWarn:
+		return l.errorlog
+	case level >= LogLevelInfo:
+		return l.infolog
+	default:
+		return l.tracelog
+	}
+}
+
+// Enabled returns true if there is a Logger for the given level.
+func (l printfLogger) Enabled(ctx context.Context, level LogLevel) bool {
+	return l.logger(level) != nil
+}
+
+// Log prints msg to the Logger of the given level, ignoring keyvals.
+func (l printfLogger) Log(ctx context.Context, level LogLevel, msg string, keyvals ...interface{}) {
+	if logger := l.logger(level); logger != nil {
+		logger.Printf("%s", msg)
+	}
+}
+
+// logEnabled returns true if messages of the given level are logged
+// anywhere. Use it to skip expensive work like dumping requests.
+func (c *Client) logEnabled(ctx context.Context, level LogLevel) bool {
+	if c.logger != nil && c.logger.Enabled(ctx, level) {
+		return true
+	}
+	return c.printfLogger().Enabled(ctx, level)
+}
+
+// log logs msg with the given level and key/value pairs to the
+// structured logger and to the Printf-style loggers.
+func (c *Client) log(ctx context.Context, level LogLevel, msg string, keyvals ...interface{}) {
+	if c.logger != nil && c.logger.Enabled(ctx, level) {
+		c.logger.Log(ctx, level, msg, keyvals...)
+	}
+	c.printfLogger().Log(ctx, level, msg, keyvals...)
+}
+
+// printfLogger returns the adapter for the Printf-style loggers.
+func (c *Client) printfLogger() printfLogger {
+	return printfLogger{
+		errorlog: c.errorlog,
+		infolog:  c.infolog,
+		tracelog: c.tracelog,
+	}
+}
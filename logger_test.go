@@ -0,0 +1,194 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type logEntry struct {
+	Level   LogLevel
+	Msg     string
+	KeyVals map[string]interface{}
+}
+
+// recordingLogger is a StructuredLogger that records all entries.
+type recordingLogger struct {
+	mu       sync.Mutex
+	minLevel LogLevel
+	entries  []logEntry
+}
+
+func (l *recordingLogger) Enabled(ctx context.Context, level LogLevel) bool {
+	return level >= l.minLevel
+}
+
+func (l *recordingLogger) Log(ctx context.Context, level LogLevel, msg string, keyvals ...interface{}) {
+	kv := make(map[string]interface{})
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		kv[keyvals[i].(string)] = keyvals[i+1]
+	}
+	l.mu.Lock()
+	l.entries = append(l.entries, logEntry{Level: level, Msg: msg, KeyVals: kv})
+	l.mu.Unlock()
+}
+
+func (l *recordingLogger) find(level LogLevel, pattern string) *logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	re := regexp.MustCompile(pattern)
+	for i := range l.entries {
+		if l.entries[i].Level == level && re.MatchString(l.entries[i].Msg) {
+			return &l.entries[i]
+		}
+	}
+	return nil
+}
+
+func TestLogLevelString(t *testing.T) {
+	tests := []struct {
+		Level    LogLevel
+		Expected string
+	}{
+		{LogLevelTrace, "TRACE"},
+		{LogLevelDebug, "DEBUG"},
+		{LogLevelInfo, "INFO"},
+		{LogLevelWarn, "WARN"},
+		{LogLevelError, "ERROR"},
+	}
+	for _, test := range tests {
+		if want, have := test.Expected, test.Level.String(); want != have {
+			t.Errorf("expected %q; got: %q", want, have)
+		}
+	}
+}
+
+func TestStructuredLoggerOnPerformRequest(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	logger := &recordingLogger{minLevel: LogLevelDebug}
+	infolog := &customLogger{}
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetrier(NewBackoffRetrier(ZeroBackoff{})),
+		SetRetryStatusCodes(http.StatusTooManyRequests),
+		SetLogger(logger),
+		SetInfoLog(infolog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "GET",
+		Path:   "/twitter/_doc/1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Retry
+	entry := logger.find(LogLevelDebug, `^elastic: retrying GET /twitter/_doc/1`)
+	if entry == nil {
+		t.Fatalf("expected retry to be logged; got: %+v", logger.entries)
+	}
+	if want, have := http.StatusTooManyRequests, entry.KeyVals["status"]; want != have {
+		t.Fatalf("expected status %v; got: %v", want, have)
+	}
+	if want, have := 2, entry.KeyVals["attempt"]; want != have {
+		t.Fatalf("expected attempt %v; got: %v", want, have)
+	}
+
+	// Request
+	entry = logger.find(LogLevelInfo, `^GET `)
+	if entry == nil {
+		t.Fatalf("expected request to be logged; got: %+v", logger.entries)
+	}
+	expected := map[string]interface{}{
+		"method":  "GET",
+		"path":    "/twitter/_doc/1",
+		"node":    ts.URL,
+		"status":  http.StatusOK,
+		"attempt": 2,
+	}
+	for key, value := range expected {
+		if want, have := value, entry.KeyVals[key]; want != have {
+			t.Errorf("expected %s=%v; got: %v", key, want, have)
+		}
+	}
+	if d, ok := entry.KeyVals["duration"].(time.Duration); !ok || d <= 0 {
+		t.Errorf("expected duration; got: %v", entry.KeyVals["duration"])
+	}
+
+	// The info log gets the same message as before
+	if !regexp.MustCompile(`^GET http://127\.0\.0\.1:\d+/twitter/_doc/1 \[status:200, request:\d+\.\d{3}s\]\n$`).MatchString(infolog.out.String()) {
+		t.Fatalf("unexpected info log output: %q", infolog.out.String())
+	}
+}
+
+func TestStructuredLoggerLevels(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	logger := &recordingLogger{minLevel: LogLevelWarn}
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.entries) > 0 {
+		t.Fatalf("expected no entries below the minimum level; got: %+v", logger.entries)
+	}
+}
+
+func TestPrintfLoggerLevels(t *testing.T) {
+	errorlog, infolog, tracelog := &customLogger{}, &customLogger{}, &customLogger{}
+	l := printfLogger{errorlog: errorlog, infolog: infolog, tracelog: tracelog}
+	l.Log(context.Background(), LogLevelError, "error", "key", "value")
+	l.Log(context.Background(), LogLevelWarn, "warn")
+	l.Log(context.Background(), LogLevelInfo, "info")
+	l.Log(context.Background(), LogLevelDebug, "debug")
+	l.Log(context.Background(), LogLevelTrace, "trace")
+	if want, have := "error\nwarn\n", errorlog.out.String(); want != have {
+		t.Errorf("expected %q; got: %q", want, have)
+	}
+	if want, have := "info\n", infolog.out.String(); want != have {
+		t.Errorf("expected %q; got: %q", want, have)
+	}
+	if want, have := "debug\ntrace\n", tracelog.out.String(); want != have {
+		t.Errorf("expected %q; got: %q", want, have)
+	}
+
+	l = printfLogger{}
+	if l.Enabled(context.Background(), LogLevelError) {
+		t.Error("expected printf logger without loggers to be disabled")
+	}
+}
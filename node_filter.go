@@ -0,0 +1,89 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import "strings"
+
+const (
+	// NodeRoleMaster is the role of master-eligible nodes.
+	NodeRoleMaster = "master"
+	// NodeRoleVotingOnly is the role of master-eligible nodes that only
+	// vote in master elections, but never become the elected master.
+	NodeRoleVotingOnly = "voting_only"
+	// NodeRoleData is the role of generic data nodes. Nodes of a data tier
+	// have roles prefixed with "data_", e.g. "data_hot".
+	NodeRoleData = "data"
+	// NodeRoleIngest is the role of ingest nodes.
+	NodeRoleIngest = "ingest"
+)
+
+// NodeFilter decides whether a request may be sent to the node of the
+// given connection. Use SetNodeFilter to specify the filter for all
+// requests of a Client, or PerformRequestOptions.NodeFilter to override
+// it for a single request.
+//
+// Connections that are not sniffed from the cluster have no roles.
+type NodeFilter func(conn Connection) bool
+
+// DefaultNodeFilter is the NodeFilter used by default. It routes requests
+// to all nodes except dedicated master nodes, i.e. nodes whose only
+// roles are "master" and, optionally, "voting_only". Nodes with other
+// roles, e.g. "ml" or "remote_cluster_client", are accepted. Use
+// NodeRoleFilter to restrict requests to e.g. data or ingest nodes.
+//
+// Connections without roles, e.g. when sniffing is disabled, are
+// treated as coordinating-only nodes.
+func DefaultNodeFilter(conn Connection) bool {
+	roles := conn.Roles()
+	if len(roles) == 0 {
+		return true // coordinating-only or unknown
+	}
+	for _, role := range roles {
+		if role != NodeRoleMaster && role != NodeRoleVotingOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyNodeFilter is a NodeFilter that accepts all nodes, including
+// dedicated master nodes.
+func AnyNodeFilter(conn Connection) bool {
+	return true
+}
+
+// NodeRoleFilter returns a NodeFilter that accepts all nodes with at
+// least one of the given roles. Passing "data" also accepts the nodes
+// of all data tiers, e.g. "data_hot" or "data_content".
+func NodeRoleFilter(roles ...string) NodeFilter {
+	return func(conn Connection) bool {
+		for _, have := range conn.Roles() {
+			for _, want := range roles {
+				if have == want || (want == NodeRoleData && isDataRole(have)) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// connsInZone returns the connections whose node attribute holds the
+// given zone.
+func connsInZone(conns []Connection, attribute, zone string) []Connection {
+	var local []Connection
+	for _, conn := range conns {
+		if conn.Attributes()[attribute] == zone {
+			local = append(local, conn)
+		}
+	}
+	return local
+}
+
+// isDataRole returns true if role is the generic data role or the role
+// of a data tier.
+func isDataRole(role string) bool {
+	return role == NodeRoleData || strings.HasPrefix(role, NodeRoleData+"_")
+}
@@ -0,0 +1,227 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultNodeFilter(t *testing.T) {
+	tests := []struct {
+		Roles    []string
+		Expected bool
+	}{
+		{nil, true},
+		{[]string{}, true},
+		{[]string{"master"}, false},
+		{[]string{"master", "voting_only"}, false},
+		{[]string{"master", "ml"}, true},
+		{[]string{"master", "data"}, true},
+		{[]string{"data_hot"}, true},
+		{[]string{"ingest"}, true},
+		{[]string{"master", "ingest"}, true},
+		{[]string{"ml"}, true},
+		{[]string{"ml", "remote_cluster_client"}, true},
+	}
+	for i, test := range tests {
+		conn := newConn("node", "http://127.0.0.1:9200")
+		conn.setNodeInfo(test.Roles, nil)
+		if want, have := test.Expected, DefaultNodeFilter(conn); want != have {
+			t.Errorf("#%d: expected %v for roles %v; got: %v", i, want, test.Roles, have)
+		}
+	}
+}
+
+func TestNodeRoleFilter(t *testing.T) {
+	filter := NodeRoleFilter("data")
+	tests := []struct {
+		Roles    []string
+		Expected bool
+	}{
+		{nil, false},
+		{[]string{"master"}, false},
+		{[]string{"data"}, true},
+		{[]string{"data_content", "ingest"}, true},
+		{[]string{"ingest"}, false},
+	}
+	for i, test := range tests {
+		conn := newConn("node", "http://127.0.0.1:9200")
+		conn.setNodeInfo(test.Roles, nil)
+		if want, have := test.Expected, filter(conn); want != have {
+			t.Errorf("#%d: expected %v for roles %v; got: %v", i, want, test.Roles, have)
+		}
+	}
+}
+
+func TestClientNextSkipsMasterOnlyNodes(t *testing.T) {
+	client, err := NewClient(
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetURL("http://127.0.0.1:9200", "http://127.0.0.1:9201", "http://127.0.0.1:9202"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.conns[0].setNodeInfo([]string{"master"}, nil)
+	client.conns[1].setNodeInfo([]string{"data", "ingest"}, nil)
+	client.conns[2].setNodeInfo([]string{"master", "voting_only"}, nil)
+
+	for i := 0; i < 3; i++ {
+		c, err := client.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := client.conns[1].URL(), c.URL(); want != have {
+			t.Fatalf("#%d: expected %s; got: %s", i, want, have)
+		}
+	}
+
+	// Override per request
+	c, err := client.nextMatching(NodeRoleFilter(NodeRoleMaster))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.URL() == client.conns[1].URL() {
+		t.Fatalf("expected master node; got: %s", c.URL())
+	}
+
+	// No eligible node
+	client.conns[1].MarkAsDead()
+	_, err = client.next()
+	if !IsConnErr(err) {
+		t.Fatalf("expected connection error; got: %v", err)
+	}
+	if client.conns[0].IsDead() || client.conns[2].IsDead() {
+		t.Fatal("expected master nodes to stay alive")
+	}
+}
+
+func TestClientSniffNodeRoles(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := strings.TrimPrefix(ts.URL, "http://")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{
+			"cluster_name": "elasticsearch",
+			"nodes": {
+				"node1": {
+					"name": "node1",
+					"roles": ["data", "ingest"],
+					"attributes": {"zone": "eu-west-1a"},
+					"http": {"publish_address": %q}
+				}
+			}
+		}`, addr)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := client.sniffNode(context.Background(), ts.URL)
+	if want, have := 1, len(nodes); want != have {
+		t.Fatalf("expected %d nodes; got: %d", want, have)
+	}
+	if want, have := []string{"data", "ingest"}, nodes[0].Roles(); fmt.Sprint(want) != fmt.Sprint(have) {
+		t.Fatalf("expected roles %v; got: %v", want, have)
+	}
+	if want, have := "eu-west-1a", nodes[0].Attributes()["zone"]; want != have {
+		t.Fatalf("expected zone %q; got: %q", want, have)
+	}
+}
+
+func TestClientNextPrefersLocalZone(t *testing.T) {
+	client, err := NewClient(
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetZoneAwareness("node.attr.zone", "a"),
+		SetURL("http://127.0.0.1:9200", "http://127.0.0.1:9201", "http://127.0.0.1:9202"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.conns[0].setNodeInfo([]string{"data"}, map[string]string{"zone": "b"})
+	client.conns[1].setNodeInfo([]string{"data"}, map[string]string{"zone": "a"})
+	client.conns[2].setNodeInfo([]string{"data"}, map[string]string{"zone": "c"})
+
+	for i := 0; i < 3; i++ {
+		c, err := client.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := client.conns[1].URL(), c.URL(); want != have {
+			t.Fatalf("#%d: expected %s; got: %s", i, want, have)
+		}
+	}
+
+	// Fall back to other zones if all nodes in the local zone are dead
+	client.conns[1].MarkAsDead()
+	seen := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		c, err := client.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[c.URL()] = true
+	}
+	if !seen[client.conns[0].URL()] || !seen[client.conns[2].URL()] {
+		t.Fatalf("expected requests to go to other zones; got: %v", seen)
+	}
+
+	// Prefer the local zone again once the node is back
+	client.conns[1].MarkAsAlive()
+	c, err := client.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := client.conns[1].URL(), c.URL(); want != have {
+		t.Fatalf("expected %s; got: %s", want, have)
+	}
+}
+
+func TestClientNextProbesLocalZoneFirst(t *testing.T) {
+	client, err := NewClient(
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetZoneAwareness("node.attr.zone", "a"),
+		SetResurrectTimeout(10*time.Millisecond, 10*time.Millisecond),
+		SetURL("http://127.0.0.1:9200", "http://127.0.0.1:9201", "http://127.0.0.1:9202"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.conns[0].setNodeInfo([]string{"data"}, map[string]string{"zone": "b"})
+	client.conns[1].setNodeInfo([]string{"data"}, map[string]string{"zone": "a"})
+	client.conns[2].setNodeInfo([]string{"data"}, map[string]string{"zone": "a"})
+
+	// A due node in another zone is not probed while the local zone is healthy
+	client.conns[0].MarkAsDead()
+	time.Sleep(20 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		c, err := client.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.URL() == client.conns[0].URL() {
+			t.Fatalf("#%d: expected no probe of %s in another zone", i, c.URL())
+		}
+	}
+
+	// Dead nodes in the local zone are probed before those in other zones
+	client.conns[1].MarkAsDead()
+	client.conns[2].MarkAsDead()
+	time.Sleep(20 * time.Millisecond)
+	c, err := client.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zone := c.Attributes()["zone"]; zone != "a" {
+		t.Fatalf("expected probe in the local zone; got: %s in zone %q", c.URL(), zone)
+	}
+}
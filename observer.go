@@ -0,0 +1,179 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"time"
+)
+
+// Observer receives events from the internals of the client, e.g. to
+// collect metrics (see PrometheusCollector). Use SetObserver to install
+// an Observer.
+//
+// Methods are called synchronously, so implementations must be fast and
+// safe for concurrent use. Embed NopObserver to only implement the
+// events you are interested in.
+type Observer interface {
+	// RequestStart is called before an attempt of a request is sent
+	// to Elasticsearch.
+	RequestStart(ctx context.Context, event RequestStartEvent)
+
+	// RequestFinish is called after an attempt of a request returned.
+	RequestFinish(ctx context.Context, event RequestFinishEvent)
+
+	// Retry is called when a request is going to be retried.
+	Retry(ctx context.Context, event RetryEvent)
+
+	// NodeDead is called when a node is marked as dead.
+	NodeDead(ctx context.Context, event NodeEvent)
+
+	// NodeAlive is called when a dead node is marked as alive again.
+	NodeAlive(ctx context.Context, event NodeEvent)
+
+	// Sniff is called after the cluster has been sniffed.
+	Sniff(ctx context.Context, event SniffEvent)
+
+	// Healthcheck is called after a node has been health-checked.
+	Healthcheck(ctx context.Context, event HealthcheckEvent)
+
+	// BulkCommit is called after a BulkProcessor committed its requests.
+	BulkCommit(ctx context.Context, event BulkCommitEvent)
+}
+
+// RequestStartEvent is passed to Observer.RequestStart.
+type RequestStartEvent struct {
+	Method  string // HTTP method, e.g. "GET"
+	Path    string // path without parameters, e.g. "/twitter/_search"
+	Node    string // URL of the node
+	Attempt int    // 1 for the first attempt, 2 for the first retry etc.
+}
+
+// RequestFinishEvent is passed to Observer.RequestFinish.
+type RequestFinishEvent struct {
+	Method     string        // HTTP method, e.g. "GET"
+	Path       string        // path without parameters, e.g. "/twitter/_search"
+	Node       string        // URL of the node
+	Attempt    int           // 1 for the first attempt, 2 for the first retry etc.
+	StatusCode int           // HTTP status code, or 0 if there is no response
+	Duration   time.Duration // duration of the round trip
+	Err        error         // transport error, if any
+}
+
+// RetryEvent is passed to Observer.Retry.
+type RetryEvent struct {
+	Method     string        // HTTP method, e.g. "GET"
+	Path       string        // path without parameters, e.g. "/twitter/_search"
+	Node       string        // URL of the node of the failed attempt
+	Attempt    int           // number of the next attempt
+	Wait       time.Duration // time to wait before the next attempt
+	StatusCode int           // HTTP status code of the failed attempt, or 0
+	Err        error         // transport error of the failed attempt, if any
+}
+
+// NodeEvent is passed to Observer.NodeDead and Observer.NodeAlive.
+type NodeEvent struct {
+	NodeID string // ID of the node, if known from sniffing
+	Node   string // URL of the node
+}
+
+// SniffEvent is passed to Observer.Sniff.
+type SniffEvent struct {
+	Nodes    int           // number of nodes found
+	Duration time.Duration // duration of the sniff process
+	Err      error         // error, if sniffing failed
+}
+
+// HealthcheckEvent is passed to Observer.Healthcheck.
+type HealthcheckEvent struct {
+	NodeID     string        // ID of the node, if known from sniffing
+	Node       string        // URL of the node
+	Healthy    bool          // true if the node responded successfully
+	StatusCode int           // HTTP status code, or 0 if there is no response
+	Duration   time.Duration // duration of the health check
+	Err        error         // error, if the health check failed
+}
+
+// BulkCommitEvent is passed to Observer.BulkCommit.
+type BulkCommitEvent struct {
+	Processor   string        // name of the BulkProcessor
+	Worker      int           // number of the worker
+	ExecutionID int64         // ID of the commit, as passed to the callbacks
+	Requests    int           // number of requests committed
+	Succeeded   int           // number of requests that succeeded
+	Failed      int           // number of requests that failed
+	Duration    time.Duration // duration of the commit, including retries
+	Err         error         // error, if the commit failed
+}
+
+// NopObserver is an Observer that ignores all events. Embed it into
+// your own Observer to only implement the events you need.
+type NopObserver struct{}
+
+// RequestStart is a no-op.
+func (NopObserver) RequestStart(ctx context.Context, event RequestStartEvent) {}
+
+// RequestFinish is a no-op.
+func (NopObserver) RequestFinish(ctx context.Context, event RequestFinishEvent) {}
+
+// Retry is a no-op.
+func (NopObserver) Retry(ctx context.Context, event RetryEvent) {}
+
+// NodeDead is a no-op.
+func (NopObserver) NodeDead(ctx context.Context, event NodeEvent) {}
+
+// NodeAlive is a no-op.
+func (NopObserver) NodeAlive(ctx context.Context, event NodeEvent) {}
+
+// Sniff is a no-op.
+func (NopObserver) Sniff(ctx context.Context, event SniffEvent) {}
+
+// Healthcheck is a no-op.
+func (NopObserver) Healthcheck(ctx context.Context, event HealthcheckEvent) {}
+
+// BulkCommit is a no-op.
+func (NopObserver) BulkCommit(ctx context.Context, event BulkCommitEvent) {}
+
+// -- Marking connections --
+
+// markConnDead marks the connection as dead and notifies the observer
+// if it was alive before.
+func (c *Client) markConnDead(ctx context.Context, conn *conn) {
+	if conn.markAsDead() && c.observer != nil {
+		c.observer.NodeDead(ctx, NodeEvent{NodeID: conn.NodeID(), Node: conn.URL()})
+	}
+}
+
+// markConnAlive marks the connection as alive and notifies the observer
+// if it was dead before.
+func (c *Client) markConnAlive(ctx context.Context, conn *conn) {
+	if conn.markAsAlive() && c.observer != nil {
+		c.observer.NodeAlive(ctx, NodeEvent{NodeID: conn.NodeID(), Node: conn.URL()})
+	}
+}
+
+// markConnHealthy marks the connection as healthy and notifies the
+// observer if it was dead before.
+func (c *Client) markConnHealthy(ctx context.Context, conn *conn) {
+	if conn.markAsHealthy() && c.observer != nil {
+		c.observer.NodeAlive(ctx, NodeEvent{NodeID: conn.NodeID(), Node: conn.URL()})
+	}
+}
+
+// observeHealthcheck notifies the observer about the outcome of the
+// health check of the given connection.
+func (c *Client) observeHealthcheck(ctx context.Context, conn *conn, start time.Time, status int, err error) {
+	if c.observer == nil {
+		return
+	}
+	c.observer.Healthcheck(ctx, HealthcheckEvent{
+		NodeID:     conn.NodeID(),
+		Node:       conn.URL(),
+		Healthy:    err == nil && status >= 200 && status < 300,
+		StatusCode: status,
+		Duration:   time.Since(start),
+		Err:        err,
+	})
+}
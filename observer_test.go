@@ -0,0 +1,193 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// recordingObserver is an Observer that records all events.
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []interface{}
+}
+
+func (o *recordingObserver) record(event interface{}) {
+	o.mu.Lock()
+	o.events = append(o.events, event)
+	o.mu.Unlock()
+}
+
+func (o *recordingObserver) RequestStart(ctx context.Context, event RequestStartEvent) {
+	o.record(event)
+}
+
+func (o *recordingObserver) RequestFinish(ctx context.Context, event RequestFinishEvent) {
+	o.record(event)
+}
+
+func (o *recordingObserver) Retry(ctx context.Context, event RetryEvent) {
+	o.record(event)
+}
+
+func (o *recordingObserver) NodeDead(ctx context.Context, event NodeEvent) {
+	o.record(event)
+}
+
+func (o *recordingObserver) NodeAlive(ctx context.Context, event NodeEvent) {
+	o.record(event)
+}
+
+func (o *recordingObserver) Sniff(ctx context.Context, event SniffEvent) {
+	o.record(event)
+}
+
+func (o *recordingObserver) Healthcheck(ctx context.Context, event HealthcheckEvent) {
+	o.record(event)
+}
+
+func (o *recordingObserver) BulkCommit(ctx context.Context, event BulkCommitEvent) {
+	o.record(event)
+}
+
+func TestObserverOnPerformRequest(t *testing.T) {
+	var requests int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	observer := &recordingObserver{}
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetrier(NewBackoffRetrier(ZeroBackoff{})),
+		SetRetryStatusCodes(http.StatusServiceUnavailable),
+		SetObserver(observer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "get", Path: "/twitter/_doc/1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if want, have := 5, len(observer.events); want != have {
+		t.Fatalf("expected %d events; got: %d (%+v)", want, have, observer.events)
+	}
+	start, ok := observer.events[0].(RequestStartEvent)
+	if !ok {
+		t.Fatalf("expected RequestStartEvent; got: %T", observer.events[0])
+	}
+	if want, have := (RequestStartEvent{Method: "GET", Path: "/twitter/_doc/1", Node: ts.URL, Attempt: 1}), start; want != have {
+		t.Fatalf("expected %+v; got: %+v", want, have)
+	}
+	finish, ok := observer.events[1].(RequestFinishEvent)
+	if !ok {
+		t.Fatalf("expected RequestFinishEvent; got: %T", observer.events[1])
+	}
+	if want, have := http.StatusServiceUnavailable, finish.StatusCode; want != have {
+		t.Fatalf("expected status %d; got: %d", want, have)
+	}
+	if finish.Duration <= 0 {
+		t.Fatalf("expected duration > 0; got: %v", finish.Duration)
+	}
+	retry, ok := observer.events[2].(RetryEvent)
+	if !ok {
+		t.Fatalf("expected RetryEvent; got: %T", observer.events[2])
+	}
+	if want, have := 2, retry.Attempt; want != have {
+		t.Fatalf("expected attempt %d; got: %d", want, have)
+	}
+	if want, have := http.StatusServiceUnavailable, retry.StatusCode; want != have {
+		t.Fatalf("expected status %d; got: %d", want, have)
+	}
+	if _, ok := observer.events[3].(RequestStartEvent); !ok {
+		t.Fatalf("expected RequestStartEvent; got: %T", observer.events[3])
+	}
+	finish, ok = observer.events[4].(RequestFinishEvent)
+	if !ok {
+		t.Fatalf("expected RequestFinishEvent; got: %T", observer.events[4])
+	}
+	if want, have := http.StatusOK, finish.StatusCode; want != have {
+		t.Fatalf("expected status %d; got: %d", want, have)
+	}
+	if want, have := 2, finish.Attempt; want != have {
+		t.Fatalf("expected attempt %d; got: %d", want, have)
+	}
+}
+
+func TestObserverOnNodeStateChanges(t *testing.T) {
+	observer := &recordingObserver{}
+	client, err := NewClient(SetURL("http://127.0.0.1:9200"), SetSniff(false), SetHealthcheck(false), SetObserver(observer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := client.conns[0]
+	ctx := context.Background()
+
+	client.markConnDead(ctx, conn)
+	client.markConnDead(ctx, conn) // no event, as it is dead already
+	client.markConnAlive(ctx, conn)
+	client.markConnHealthy(ctx, conn) // no event, as it is alive already
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if want, have := 2, len(observer.events); want != have {
+		t.Fatalf("expected %d events; got: %d (%+v)", want, have, observer.events)
+	}
+	if _, ok := observer.events[0].(NodeEvent); !ok {
+		t.Fatalf("expected NodeEvent; got: %T", observer.events[0])
+	}
+	if want, have := "http://127.0.0.1:9200", observer.events[1].(NodeEvent).Node; want != have {
+		t.Fatalf("expected node %q; got: %q", want, have)
+	}
+}
+
+func TestObserverOnHealthcheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	observer := &recordingObserver{}
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false), SetObserver(observer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.healthcheck(context.Background(), DefaultHealthcheckTimeout, true)
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	if want, have := 2, len(observer.events); want != have {
+		t.Fatalf("expected %d events; got: %d (%+v)", want, have, observer.events)
+	}
+	if _, ok := observer.events[0].(NodeEvent); !ok {
+		t.Fatalf("expected NodeEvent; got: %T", observer.events[0])
+	}
+	hc, ok := observer.events[1].(HealthcheckEvent)
+	if !ok {
+		t.Fatalf("expected HealthcheckEvent; got: %T", observer.events[1])
+	}
+	if hc.Healthy {
+		t.Fatal("expected node to be unhealthy")
+	}
+	if want, have := http.StatusServiceUnavailable, hc.StatusCode; want != have {
+		t.Fatalf("expected status %d; got: %d", want, have)
+	}
+}
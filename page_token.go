@@ -0,0 +1,200 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidPageToken is returned by PageTokenCodec if a page token is
+	// malformed or has an invalid signature.
+	ErrInvalidPageToken = errors.New("elastic: invalid page token")
+
+	// ErrPageTokenMismatch is returned by PageTokenCodec if a page token
+	// was created for a search with another query, sort order, indices
+	// or routing.
+	ErrPageTokenMismatch = errors.New("elastic: page token does not match the search")
+)
+
+// pageTokenVersion is the version of the format of page tokens.
+const pageTokenVersion = 1
+
+// PageTokenCodec creates and reads page tokens, i.e. cursors for
+// paginating searches with search_after that can be handed out to
+// the consumers of an API.
+//
+// A page token contains the sort values of the last hit of a page, the
+// point in time of the search, if any, and a fingerprint of the query
+// and sort order. It is signed with HMAC-SHA256, and encoded with URL-safe
+// base64. Page tokens are opaque, but not encrypted, i.e. consumers cannot
+// modify them, but may be able to read the sort values.
+//
+// Example:
+//
+//	codec := elastic.NewPageTokenCodec(secret)
+//
+//	search := client.Search("tweets").Query(q).Sort("created", false).Sort("_id", true).Size(20)
+//	if token != "" {
+//		search, err = codec.Decode(token, search)
+//		if err != nil {
+//			...
+//		}
+//	}
+//	res, err := search.Do(ctx)
+//	...
+//	next, err := codec.Encode(search, res)
+type PageTokenCodec struct {
+	key []byte
+}
+
+// NewPageTokenCodec creates a new PageTokenCodec that signs page tokens
+// with the given secret key. The key should be at least 32 bytes long.
+func NewPageTokenCodec(key []byte) *PageTokenCodec {
+	return &PageTokenCodec{key: append([]byte(nil), key...)}
+}
+
+// pageToken is the content of a page token.
+type pageToken struct {
+	Version     int           `json:"v"`
+	SearchAfter []interface{} `json:"a"`
+	PitId       string        `json:"p,omitempty"`
+	KeepAlive   string        `json:"k,omitempty"`
+	Fingerprint string        `json:"f"`
+}
+
+// Encode returns the page token for the page after res, which is a page
+// of results of search. The search must be sorted, preferably by a unique
+// tiebreaker like _id or _shard_doc. Encode returns an empty token if res
+// has no hits, i.e. if there are no more pages.
+//
+// If the search uses a point in time, the token contains the most recent
+// id of the point in time as returned with res.
+func (c *PageTokenCodec) Encode(search *SearchService, res *SearchResult) (string, error) {
+	if len(c.key) == 0 {
+		return "", errors.New("elastic: missing key for page tokens")
+	}
+	if res == nil || res.Hits == nil || len(res.Hits.Hits) == 0 {
+		return "", nil
+	}
+	last := res.Hits.Hits[len(res.Hits.Hits)-1]
+	if len(last.Sort) == 0 {
+		return "", errors.New("elastic: page tokens require a sorted search")
+	}
+	token := pageToken{
+		Version:     pageTokenVersion,
+		SearchAfter: last.Sort,
+	}
+	if pit := search.searchSource.pointInTime; pit != nil {
+		token.PitId, token.KeepAlive = pit.Id, pit.KeepAlive
+	}
+	if res.PitId != "" {
+		token.PitId = res.PitId
+	}
+	fingerprint, err := pageTokenFingerprint(search, token.PitId == "")
+	if err != nil {
+		return "", err
+	}
+	token.Fingerprint = fingerprint
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return "", errors.Wrap(err, "elastic: cannot encode page token")
+	}
+	return base64.RawURLEncoding.EncodeToString(append(c.sign(payload), payload...)), nil
+}
+
+// Decode reads a page token created by Encode and configures search to
+// return the page after it, i.e. it sets SearchAfter and, if the token
+// has one, the PointInTime. Searches with a point in time must not specify
+// indices, routing or preference, so these are removed from search.
+//
+// Decode returns ErrInvalidPageToken if the token is malformed or not
+// signed with the key of the codec, and ErrPageTokenMismatch if search has
+// another query or sort order than the search the token was created for,
+// or, without a point in time, other indices or routing.
+func (c *PageTokenCodec) Decode(token string, search *SearchService) (*SearchService, error) {
+	if len(c.key) == 0 {
+		return nil, errors.New("elastic: missing key for page tokens")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) <= sha256.Size {
+		return nil, ErrInvalidPageToken
+	}
+	mac, payload := data[:sha256.Size], data[sha256.Size:]
+	if !hmac.Equal(mac, c.sign(payload)) {
+		return nil, ErrInvalidPageToken
+	}
+
+	// Keep numbers as they are, e.g. large longs of dates or _shard_doc
+	var t pageToken
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&t); err != nil || t.Version != pageTokenVersion || len(t.SearchAfter) == 0 {
+		return nil, ErrInvalidPageToken
+	}
+
+	fingerprint, err := pageTokenFingerprint(search, t.PitId == "")
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != t.Fingerprint {
+		return nil, ErrPageTokenMismatch
+	}
+	search.searchSource.searchAfterSortValues = t.SearchAfter
+	if t.PitId != "" {
+		search.PointInTime(NewPointInTimeWithKeepAlive(t.PitId, t.KeepAlive))
+		clearPointInTimeParams(search)
+	}
+	return search, nil
+}
+
+// sign returns the signature of payload.
+func (c *PageTokenCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// pageTokenFingerprint returns the fingerprint of the query and sort order
+// of search, i.e. of its SearchSource without pagination. If withTarget is
+// true, i.e. for searches without a point in time, it also covers the
+// indices and routing of search, as these aren't pinned by a point in time.
+func pageTokenFingerprint(search *SearchService, withTarget bool) (string, error) {
+	if search.source != nil {
+		return "", errors.New("elastic: page tokens require a SearchSource instead of Source")
+	}
+	src := *search.searchSource
+	src.from = -1
+	src.size = -1
+	src.searchAfterSortValues = nil
+	src.pointInTime = nil
+	body, err := src.Source()
+	if err != nil {
+		return "", err
+	}
+	fp := struct {
+		Source  interface{} `json:"s"`
+		Index   []string    `json:"i,omitempty"`
+		Routing string      `json:"r,omitempty"`
+	}{Source: body}
+	if withTarget {
+		fp.Index = append(fp.Index, search.index...)
+		sort.Strings(fp.Index)
+		fp.Routing = search.routing
+	}
+	data, err := json.Marshal(fp)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:16]), nil
+}
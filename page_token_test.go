@@ -0,0 +1,173 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+)
+
+func TestPageTokenCodec(t *testing.T) {
+	client, err := NewSimpleClient(SetURL("http://127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec := NewPageTokenCodec([]byte("0123456789abcdef0123456789abcdef"))
+	newSearch := func() *SearchService {
+		return client.Search("tweets").
+			Query(NewTermQuery("user", "olivere")).
+			Sort("created", false).
+			Sort("_id", true)
+	}
+
+	search := newSearch().Size(2).PointInTime(NewPointInTimeWithKeepAlive("pit-1", "1m"))
+	res := &SearchResult{
+		PitId: "pit-2",
+		Hits: &SearchHits{
+			Hits: []*SearchHit{
+				{Id: "1", Sort: []interface{}{json.Number("1234567890123456788"), "1"}},
+				{Id: "2", Sort: []interface{}{json.Number("1234567890123456789"), "2"}},
+			},
+		},
+	}
+	token, err := codec.Encode(search, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == "" || url.QueryEscape(token) != token {
+		t.Fatalf("expected URL-safe token; got: %q", token)
+	}
+
+	// The token configures the next page, even with another page size
+	next, err := codec.Decode(token, newSearch().Size(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := next.searchSource.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"pit":{"id":"pit-2","keep_alive":"1m"},"query":{"term":{"user":"olivere"}},"search_after":[1234567890123456789,"2"],"size":10,"sort":[{"created":{"order":"desc"}},{"_id":{"order":"asc"}}]}`
+	if got := string(data); got != expected {
+		t.Fatalf("expected\n%s\n,got:\n%s", expected, got)
+	}
+	if len(next.index) != 0 {
+		t.Fatalf("expected no indices with a point in time; got: %v", next.index)
+	}
+
+	// No more pages
+	token, err = codec.Encode(search, &SearchResult{Hits: &SearchHits{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		t.Fatalf("expected no token for an empty page; got: %q", token)
+	}
+}
+
+func TestPageTokenCodecRejectsTokens(t *testing.T) {
+	client, err := NewSimpleClient(SetURL("http://127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec := NewPageTokenCodec([]byte("0123456789abcdef0123456789abcdef"))
+	res := &SearchResult{
+		Hits: &SearchHits{
+			Hits: []*SearchHit{{Id: "1", Sort: []interface{}{float64(1), "1"}}},
+		},
+	}
+	token, err := codec.Encode(client.Search("tweets").Query(NewMatchAllQuery()).Sort("created", true).Sort("_id", true), res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(token)
+	if tampered[len(tampered)-2] == 'A' {
+		tampered[len(tampered)-2] = 'B'
+	} else {
+		tampered[len(tampered)-2] = 'A'
+	}
+
+	tests := []struct {
+		Name   string
+		Codec  *PageTokenCodec
+		Token  string
+		Search *SearchService
+		Want   error
+	}{
+		{
+			Name:   "tampered",
+			Codec:  codec,
+			Token:  string(tampered),
+			Search: client.Search("tweets").Query(NewMatchAllQuery()).Sort("created", true).Sort("_id", true),
+			Want:   ErrInvalidPageToken,
+		},
+		{
+			Name:   "other key",
+			Codec:  NewPageTokenCodec([]byte("another key")),
+			Token:  token,
+			Search: client.Search("tweets").Query(NewMatchAllQuery()).Sort("created", true).Sort("_id", true),
+			Want:   ErrInvalidPageToken,
+		},
+		{
+			Name:   "malformed",
+			Codec:  codec,
+			Token:  "not a token",
+			Search: client.Search("tweets").Query(NewMatchAllQuery()).Sort("created", true).Sort("_id", true),
+			Want:   ErrInvalidPageToken,
+		},
+		{
+			Name:   "other query",
+			Codec:  codec,
+			Token:  token,
+			Search: client.Search("tweets").Query(NewTermQuery("user", "olivere")).Sort("created", true).Sort("_id", true),
+			Want:   ErrPageTokenMismatch,
+		},
+		{
+			Name:   "other sort",
+			Codec:  codec,
+			Token:  token,
+			Search: client.Search("tweets").Query(NewMatchAllQuery()).Sort("created", false).Sort("_id", true),
+			Want:   ErrPageTokenMismatch,
+		},
+		{
+			Name:   "other index",
+			Codec:  codec,
+			Token:  token,
+			Search: client.Search("users").Query(NewMatchAllQuery()).Sort("created", true).Sort("_id", true),
+			Want:   ErrPageTokenMismatch,
+		},
+		{
+			Name:   "other routing",
+			Codec:  codec,
+			Token:  token,
+			Search: client.Search("tweets").Routing("olivere").Query(NewMatchAllQuery()).Sort("created", true).Sort("_id", true),
+			Want:   ErrPageTokenMismatch,
+		},
+	}
+	for _, tt := range tests {
+		if _, err := tt.Codec.Decode(tt.Token, tt.Search); err != tt.Want {
+			t.Errorf("%s: expected %v; got: %v", tt.Name, tt.Want, err)
+		}
+	}
+
+	// The order of indices doesn't matter
+	token, err = codec.Encode(client.Search("tweets", "users").Query(NewMatchAllQuery()).Sort("_id", true), res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := codec.Decode(token, client.Search("users", "tweets").Query(NewMatchAllQuery()).Sort("_id", true)); err != nil {
+		t.Fatalf("expected token to match the search; got: %v", err)
+	}
+
+	// Unsorted searches cannot be paginated
+	if _, err := codec.Encode(client.Search("tweets"), &SearchResult{Hits: &SearchHits{Hits: []*SearchHit{{Id: "1"}}}}); err == nil {
+		t.Fatal("expected error for unsorted hits")
+	}
+}
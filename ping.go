@@ -121,9 +121,9 @@ func (s *PingService) HttpHeadOnly(httpHeadOnly bool) *PingService {
 // server, and an error.
 func (s *PingService) Do(ctx context.Context) (*PingResult, int, error) {
 	s.client.mu.RLock()
-	basicAuth := s.client.basicAuthUsername != "" || s.client.basicAuthPassword != ""
 	basicAuthUsername := s.client.basicAuthUsername
 	basicAuthPassword := s.client.basicAuthPassword
+	authProvider := s.client.authProvider
 	defaultHeaders := s.client.headers
 	s.client.mu.RUnlock()
 
@@ -174,8 +174,8 @@ func (s *PingService) Do(ctx context.Context) (*PingResult, int, error) {
 		}
 	}
 
-	if basicAuth {
-		req.SetBasicAuth(basicAuthUsername, basicAuthPassword)
+	if err := setAuthorization(ctx, (*http.Request)(req), authProvider, basicAuthUsername, basicAuthPassword); err != nil {
+		return nil, 0, err
 	}
 
 	if req.Header.Get("User-Agent") == "" {
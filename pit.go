@@ -4,6 +4,12 @@
 
 package elastic
 
+const (
+	// DefaultPointInTimeKeepAlive is the default time a point in time
+	// is kept alive by SearchIterator and PointInTimePaginator.
+	DefaultPointInTimeKeepAlive = "5m"
+)
+
 // PointInTime is a lightweight view into the state of the data that existed
 // when initiated. It can be created with OpenPointInTime API and be used
 // when searching, e.g. in Search API or with SearchSource.
@@ -0,0 +1,65 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"time"
+)
+
+// PaginationCheckpoint is the position of a PointInTimePaginator, e.g. to
+// resume a long-running export in a new process after a failure. It can
+// be serialized as JSON.
+//
+// A checkpoint is taken after a page has been processed, i.e. a resumed
+// paginator continues with the page after it. See
+// PointInTimePaginator.Checkpoint and PointInTimePaginator.Resume, as well
+// as SlicedScanService.Checkpoint and SlicedScanService.Resume.
+type PaginationCheckpoint struct {
+	// PitId is the most recent id of the point in time.
+	PitId string `json:"pit_id,omitempty"`
+	// KeepAlive is the keep alive of the point in time, e.g. "5m".
+	KeepAlive string `json:"keep_alive,omitempty"`
+	// Expires is the time when the point in time expires if it isn't used
+	// again. It is zero if unknown.
+	Expires time.Time `json:"expires"`
+	// SearchAfter are the sort values of the last hit that was read.
+	SearchAfter []interface{} `json:"search_after,omitempty"`
+	// Slice is the id of the slice of a sliced search.
+	Slice int `json:"slice,omitempty"`
+	// Slices is the number of slices of a sliced search, or 0 if the
+	// search is not sliced.
+	Slices int `json:"slices,omitempty"`
+	// Done is true if all pages have been read.
+	Done bool `json:"done,omitempty"`
+}
+
+// Expired returns true if the point in time of the checkpoint has expired.
+// The search can still be resumed with a new point in time if it sorts by
+// a unique key (see PointInTimePaginator.Resume), but it may then see
+// changes made after the original point in time was opened.
+func (c *PaginationCheckpoint) Expired() bool {
+	return c.PitId == "" || (!c.Expires.IsZero() && time.Now().After(c.Expires))
+}
+
+// isPointInTimeMissing returns true if err indicates that a point in time
+// does not exist (anymore), e.g. because it has expired.
+func isPointInTimeMissing(err error) bool {
+	if IsNotFound(err) {
+		return true
+	}
+	e, ok := err.(*Error)
+	if !ok || e.Details == nil {
+		return false
+	}
+	if e.Details.Type == "search_context_missing_exception" {
+		return true
+	}
+	for _, cause := range e.Details.RootCause {
+		if cause != nil && cause.Type == "search_context_missing_exception" {
+			return true
+		}
+	}
+	return false
+}
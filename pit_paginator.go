@@ -0,0 +1,505 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// PointInTimePaginator pages through the results of a search with a point
+// in time and search_after, the recommended replacement of the Scroll API
+// for deep pagination.
+//
+// The paginator opens a point in time on the indices of the search, and
+// adds the _shard_doc tiebreaker to the sort order. Each page continues
+// after the sort values of the last hit of the previous page, using the
+// point in time id returned with the previous page.
+//
+// While a page is being processed, the paginator keeps the point in time
+// alive in the background, so slow consumers don't lose it (see
+// RefreshInterval). The point in time is closed when the last page has
+// been read, when the context passed to Next is cancelled, or on Close.
+// A paginator must always be closed.
+//
+// If the search already has a PointInTime, it is used instead and is left
+// open, i.e. it is neither refreshed nor closed.
+//
+// Use Checkpoint and Resume to continue paging in another process, e.g.
+// after a long-running export has failed.
+//
+// Example:
+//
+//	p := elastic.NewPointInTimePaginator(client.Search("tweets").Query(q).Size(1000))
+//	defer p.Close(context.Background())
+//	for {
+//		res, err := p.Next(ctx)
+//		if err == io.EOF {
+//			break
+//		}
+//		if err != nil {
+//			...
+//		}
+//		for _, hit := range res.Hits.Hits {
+//			...
+//		}
+//	}
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/7.x/paginate-search-results.html#search-after
+// for details.
+type PointInTimePaginator struct {
+	search *SearchService // copy of the search, without indices
+	open   *OpenPointInTimeService
+	err    error // error preparing the search
+
+	mu              sync.Mutex
+	keepAlive       string
+	refreshInterval time.Duration
+	pitID           string
+	ownPIT          bool // true if the point in time was opened by the paginator
+	pitClosed       bool // true if the point in time was closed by the paginator
+	resumed         bool // true if the point in time was taken from a checkpoint
+	slice, slices   int  // slice of the search, if any
+	shardDoc        int  // index of the _shard_doc sorter
+	shardDocAsc     bool // true if the _shard_doc sorter is ascending
+	after           []interface{}
+	eof             bool // true if all pages have been read
+	done            bool
+	lastUsed        time.Time
+	refreshing      bool
+	stopRefresh     func()
+}
+
+// NewPointInTimePaginator creates a paginator for the given search. The
+// search must be specified by a SearchSource, i.e. not via Source. The
+// search is copied, so it can be reused afterwards.
+func NewPointInTimePaginator(search *SearchService) *PointInTimePaginator {
+	p := &PointInTimePaginator{keepAlive: DefaultPointInTimeKeepAlive}
+	if search.source != nil {
+		p.err = errors.New("elastic: point in time paginator requires a SearchSource instead of Source")
+		return p
+	}
+
+	// Indices, routing and preference go into opening the point in time
+	s := *search
+	clearPointInTimeParams(&s)
+	src := *search.searchSource
+	src.sorters = append([]Sorter(nil), src.sorters...)
+	src.searchAfterSortValues = nil
+	p.shardDoc, p.shardDocAsc = shardDocSort(src.sorters)
+	if p.shardDoc < 0 {
+		src.sorters = append(src.sorters, SortInfo{Field: "_shard_doc", Ascending: true})
+		p.shardDoc, p.shardDocAsc = len(src.sorters)-1, true
+	}
+	s.searchSource = &src
+	p.search = &s
+	if q, ok := src.sliceQuery.(*SliceQuery); ok && q.id != nil && q.max != nil {
+		p.slice, p.slices = *q.id, *q.max
+	}
+
+	if pit := src.pointInTime; pit != nil && pit.Id != "" {
+		p.pitID = pit.Id
+		if pit.KeepAlive != "" {
+			p.keepAlive = pit.KeepAlive
+		}
+		return p
+	}
+	open := search.client.OpenPointInTime(search.index...).
+		Headers(search.headers).
+		Routing(search.routing).
+		Preference(search.preference).
+		ExpandWildcards(search.expandWildcards)
+	if search.ignoreUnavailable != nil {
+		open = open.IgnoreUnavailable(*search.ignoreUnavailable)
+	}
+	p.open = open
+	return p
+}
+
+// KeepAlive specifies how long Elasticsearch keeps the point in time alive
+// between two requests, e.g. "1m" (DefaultPointInTimeKeepAlive by default).
+func (p *PointInTimePaginator) KeepAlive(keepAlive string) *PointInTimePaginator {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keepAlive = keepAlive
+	return p
+}
+
+// RefreshInterval specifies how long the paginator waits for the next call
+// to Next before it extends the keep alive of the point in time in the
+// background. By default, it is half of the keep alive. Use a negative
+// interval to disable refreshing.
+func (p *PointInTimePaginator) RefreshInterval(interval time.Duration) *PointInTimePaginator {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refreshInterval = interval
+	return p
+}
+
+// PitId returns the most recent id of the point in time. It is empty
+// before the first page has been fetched.
+func (p *PointInTimePaginator) PitId() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pitID
+}
+
+// Checkpoint returns the current position of the paginator, i.e. the
+// position after the most recent page returned from Next. Pass it to
+// Resume to continue paging with the next page, e.g. in a new process.
+func (p *PointInTimePaginator) Checkpoint() *PaginationCheckpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cp := &PaginationCheckpoint{
+		KeepAlive:   p.keepAlive,
+		SearchAfter: append([]interface{}(nil), p.after...),
+		Slice:       p.slice,
+		Slices:      p.slices,
+		Done:        p.eof,
+	}
+	if !p.pitClosed {
+		cp.PitId = p.pitID
+		if d := parseKeepAlive(p.keepAlive); d > 0 && !p.lastUsed.IsZero() {
+			cp.Expires = p.lastUsed.Add(d)
+		}
+	}
+	return cp
+}
+
+// Resume continues paging from the given checkpoint, i.e. Next returns the
+// page after the one the checkpoint was taken at. It must be called before
+// the first call to Next, and the search must be the same as the one of
+// the checkpoint, including its slice.
+//
+// The paginator takes over the point in time of the checkpoint, i.e. it
+// refreshes and closes it. If the search already has a PointInTime, only
+// the sort values of the checkpoint are used, and the point in time of the
+// search must be the one of the checkpoint unless the checkpoint has expired.
+//
+// IMPORTANT: If the point in time of the checkpoint has expired, the
+// paginator continues after the sort values of the checkpoint with a new
+// point in time. The _shard_doc tiebreaker is only meaningful within the
+// point in time that returned it, so this requires the search to sort by
+// a unique key before _shard_doc, e.g. a unique field of the documents.
+// Otherwise, Next returns an error instead of skipping or repeating hits.
+// The paginator cannot check if the sort is unique, so a sort by a
+// non-unique field may still skip or repeat hits with the same sort values.
+func (p *PointInTimePaginator) Resume(checkpoint *PaginationCheckpoint) *PointInTimePaginator {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil || checkpoint == nil {
+		return p
+	}
+	if checkpoint.Slices != p.slices || checkpoint.Slice != p.slice {
+		p.err = fmt.Errorf("elastic: checkpoint of slice %d/%d does not match slice %d/%d of the search",
+			checkpoint.Slice, checkpoint.Slices, p.slice, p.slices)
+		return p
+	}
+	p.after = append([]interface{}(nil), checkpoint.SearchAfter...)
+	p.eof = checkpoint.Done
+	p.done = checkpoint.Done
+	if checkpoint.Done {
+		return p
+	}
+	if checkpoint.Expired() {
+		p.err = p.rebaseAfter()
+	} else if p.open != nil {
+		p.pitID = checkpoint.PitId
+		p.ownPIT = true
+		p.resumed = true
+	}
+	return p
+}
+
+// Next returns the next page of results. It returns io.EOF, along with
+// the empty page, if there are no more results.
+func (p *PointInTimePaginator) Next(ctx context.Context) (*SearchResult, error) {
+	res, err := p.next(ctx)
+	if err == io.EOF || ctx.Err() != nil {
+		// Close the point in time when finished or cancelled
+		if cerr := p.Close(ctx); cerr != nil && err == io.EOF {
+			return res, cerr
+		}
+	}
+	return res, err
+}
+
+// next fetches the next page.
+func (p *PointInTimePaginator) next(ctx context.Context) (*SearchResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.done {
+		return nil, io.EOF
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if p.pitID == "" {
+		if err := p.openPointInTime(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if p.ownPIT && !p.refreshing {
+		p.startRefresh()
+	}
+
+	res, err := p.searchPage(ctx)
+	if err != nil && p.resumed && isPointInTimeMissing(err) {
+		// The point in time of the checkpoint has expired, so continue
+		// after the same sort values with a new one
+		if err := p.rebaseAfter(); err != nil {
+			return nil, err
+		}
+		if err := p.openPointInTime(ctx); err != nil {
+			return nil, err
+		}
+		res, err = p.searchPage(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.resumed = false
+	// Elasticsearch may return an updated id with every response
+	if res.PitId != "" {
+		p.pitID = res.PitId
+	}
+	if res.Hits == nil || len(res.Hits.Hits) == 0 {
+		p.eof = true
+		p.done = true
+		return res, io.EOF
+	}
+	p.after = res.Hits.Hits[len(res.Hits.Hits)-1].Sort
+	return res, nil
+}
+
+// openPointInTime opens a new point in time. It must be called with
+// p.mu held.
+func (p *PointInTimePaginator) openPointInTime(ctx context.Context) error {
+	res, err := p.open.KeepAlive(p.keepAlive).Do(ctx)
+	if err != nil {
+		return err
+	}
+	p.pitID = res.Id
+	p.ownPIT = true
+	p.resumed = false
+	return nil
+}
+
+// rebaseAfter prepares the sort values of a checkpoint for a new point in
+// time. The _shard_doc value of the checkpoint is only meaningful within
+// the point in time that returned it, so it is moved past all documents
+// with the same sort values, i.e. the document of the checkpoint. This
+// requires a unique sort before _shard_doc. It must be called with p.mu
+// held.
+func (p *PointInTimePaginator) rebaseAfter() error {
+	if len(p.after) == 0 {
+		return nil // nothing read yet
+	}
+	if p.shardDoc < 1 || p.shardDoc >= len(p.after) {
+		return errors.New("elastic: cannot resume after the point in time of the checkpoint has expired: the search must sort by a unique key before _shard_doc")
+	}
+	after := append([]interface{}(nil), p.after...)
+	if p.shardDocAsc {
+		after[p.shardDoc] = int64(math.MaxInt64)
+	} else {
+		after[p.shardDoc] = int64(math.MinInt64)
+	}
+	p.after = after
+	return nil
+}
+
+// searchPage searches for the page after p.after. It must be called with
+// p.mu held.
+func (p *PointInTimePaginator) searchPage(ctx context.Context) (*SearchResult, error) {
+	src := p.search.searchSource
+	src.pointInTime = NewPointInTimeWithKeepAlive(p.pitID, p.keepAlive)
+	src.searchAfterSortValues = p.after
+	res, err := p.search.Do(ctx)
+	p.lastUsed = time.Now()
+	return res, err
+}
+
+// Close stops paging and closes the point in time, if it was opened by
+// the paginator. It is safe to call Close more than once.
+//
+// Close releases the point in time even if ctx is already done, e.g. when
+// paging was cancelled. In that case, it waits at most 30 seconds.
+func (p *PointInTimePaginator) Close(ctx context.Context) error {
+	p.mu.Lock()
+	p.done = true
+	stop := p.stopRefresh
+	p.stopRefresh = nil
+	p.mu.Unlock()
+
+	// Stop refreshing before closing the point in time, without holding
+	// the lock the refresher might be waiting for
+	if stop != nil {
+		stop()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.ownPIT || p.pitID == "" {
+		return nil
+	}
+	ctx, cancel := releaseContext(ctx)
+	defer cancel()
+	_, err := p.search.client.ClosePointInTime(p.pitID).Do(ctx)
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	p.ownPIT = false
+	p.pitClosed = true
+	return nil
+}
+
+// startRefresh starts refreshing the keep alive of the point in time in
+// the background. It must be called with p.mu held.
+func (p *PointInTimePaginator) startRefresh() {
+	interval := p.refreshInterval
+	if interval == 0 {
+		interval = parseKeepAlive(p.keepAlive) / 2
+	}
+	p.refreshing = true
+	if interval <= 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.lastUsed = time.Now()
+	p.stopRefresh = func() {
+		cancel()
+		<-done
+	}
+	go func() {
+		defer close(done)
+		p.refresh(ctx, interval)
+	}()
+}
+
+// refresh extends the keep alive of the point in time whenever it hasn't
+// been used for the given interval, until ctx is done.
+func (p *PointInTimePaginator) refresh(ctx context.Context, interval time.Duration) {
+	// Check twice per interval, so the point in time is refreshed at
+	// most 1.5 intervals after it has last been used
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		if p.done || time.Since(p.lastUsed) < interval {
+			p.mu.Unlock()
+			continue
+		}
+		pitID, keepAlive := p.pitID, p.keepAlive
+		p.lastUsed = time.Now()
+		p.mu.Unlock()
+
+		// A search without hits extends the keep alive
+		res, err := p.search.client.Search().
+			PointInTime(NewPointInTimeWithKeepAlive(pitID, keepAlive)).
+			Headers(p.search.headers).
+			Size(0).
+			TrackTotalHits(false).
+			Do(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				p.search.client.log(ctx, LogLevelWarn, fmt.Sprintf("elastic: cannot refresh point in time: %v", err),
+					"error", err)
+			}
+			continue
+		}
+		p.mu.Lock()
+		if res.PitId != "" && p.pitID == pitID {
+			p.pitID = res.PitId
+		}
+		p.mu.Unlock()
+	}
+}
+
+// parseKeepAlive parses a time unit of Elasticsearch, e.g. "5m" or "1d".
+// It returns 0 if the value cannot be parsed.
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/7.x/common-options.html#time-units
+// for details.
+func parseKeepAlive(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"nanos", time.Nanosecond},
+		{"micros", time.Microsecond},
+		{"ms", time.Millisecond},
+		{"d", 24 * time.Hour},
+		{"h", time.Hour},
+		{"m", time.Minute},
+		{"s", time.Second},
+	}
+	for _, u := range units {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSuffix(s, u.suffix), 10, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		return time.Duration(n) * u.unit
+	}
+	return 0
+}
+
+// clearPointInTimeParams removes the parameters of a search that
+// Elasticsearch does not accept for searches with a point in time,
+// i.e. indices, routing and preference.
+func clearPointInTimeParams(s *SearchService) {
+	s.index = nil
+	s.routing = ""
+	s.preference = ""
+	s.ignoreUnavailable = nil
+	s.expandWildcards = ""
+}
+
+// shardDocSort returns the index of the _shard_doc sorter in sorters,
+// or -1 if there is none, and whether it is ascending.
+func shardDocSort(sorters []Sorter) (int, bool) {
+	for i, sorter := range sorters {
+		src, err := sorter.Source()
+		if err != nil {
+			continue
+		}
+		switch v := src.(type) {
+		case string:
+			if v == "_shard_doc" {
+				return i, true
+			}
+		case map[string]interface{}:
+			if opts, found := v["_shard_doc"]; found {
+				if m, ok := opts.(map[string]interface{}); ok && m["order"] == "desc" {
+					return i, false
+				}
+				return i, true
+			}
+		}
+	}
+	return -1, true
+}
@@ -0,0 +1,292 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPointInTimePaginator(t *testing.T) {
+	ts := newIteratorTestServer(t, 3)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPointInTimePaginator(client.Search("twitter").Size(2)).KeepAlive("1m").RefreshInterval(-1)
+	defer p.Close(context.Background())
+
+	var ids []string
+	var pages int
+	for {
+		res, err := p.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, hit := range res.Hits.Hits {
+			ids = append(ids, hit.Id)
+		}
+		if want, have := res.PitId, p.PitId(); want != have {
+			t.Fatalf("expected PitId = %q; got: %q", want, have)
+		}
+	}
+	if want, have := "1,2,3", strings.Join(ids, ","); want != have {
+		t.Fatalf("expected ids %s; got: %s", want, have)
+	}
+	if want, have := 2, pages; want != have {
+		t.Fatalf("expected %d pages; got: %d", want, have)
+	}
+	if !strings.Contains(ts.openParams[0], "keep_alive=1m") {
+		t.Fatalf("expected keep_alive=1m when opening the point in time; got: %s", ts.openParams[0])
+	}
+	for i, req := range ts.searches {
+		pit, _ := req["pit"].(map[string]interface{})
+		if want, have := "1m", pit["keep_alive"]; want != have {
+			t.Fatalf("#%d: expected keep_alive %v; got: %v", i, want, have)
+		}
+	}
+
+	// The point in time is closed after the last page
+	if want, have := "pit-4", strings.Join(ts.closedPITs, ","); want != have {
+		t.Fatalf("expected closed point in time %s; got: %s", want, have)
+	}
+	if _, err := p.Next(context.Background()); err != io.EOF {
+		t.Fatalf("expected io.EOF; got: %v", err)
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(ts.closedPITs); want != have {
+		t.Fatalf("expected %d closed points in time; got: %d", want, have)
+	}
+}
+
+func TestPointInTimePaginatorRefreshesKeepAlive(t *testing.T) {
+	ts := newIteratorTestServer(t, 10)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPointInTimePaginator(client.Search("twitter").Size(2)).RefreshInterval(20 * time.Millisecond)
+	if _, err := p.Next(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A slow consumer
+	refreshes := func() int {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		var n int
+		for _, req := range ts.searches {
+			if size, ok := req["size"].(float64); ok && size == 0 && req["track_total_hits"] == false {
+				n++
+			}
+		}
+		return n
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for refreshes() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the point in time to be refreshed in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Paging continues with the id returned by the refresh
+	res, err := p.Next(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "3", res.Hits.Hits[0].Id; want != have {
+		t.Fatalf("expected first hit %s; got: %s", want, have)
+	}
+
+	// No more refreshes after Close
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	n := refreshes()
+	time.Sleep(100 * time.Millisecond)
+	if have := refreshes(); have != n {
+		t.Fatalf("expected no refreshes after Close; got %d more", have-n)
+	}
+	if want, have := 1, len(ts.closedPITs); want != have {
+		t.Fatalf("expected %d closed points in time; got: %d", want, have)
+	}
+}
+
+func TestPointInTimePaginatorCancel(t *testing.T) {
+	ts := newIteratorTestServer(t, 10)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := NewPointInTimePaginator(client.Search("twitter").Size(2))
+	if _, err := p.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := p.Next(ctx); err != context.Canceled {
+		t.Fatalf("expected %v; got: %v", context.Canceled, err)
+	}
+	// The point in time is closed when cancelled
+	ts.mu.Lock()
+	closed := strings.Join(ts.closedPITs, ",")
+	ts.mu.Unlock()
+	if want, have := "pit-2", closed; want != have {
+		t.Fatalf("expected closed point in time %s; got: %s", want, have)
+	}
+}
+
+func TestParseKeepAlive(t *testing.T) {
+	tests := []struct {
+		Input string
+		Want  time.Duration
+	}{
+		{"5m", 5 * time.Minute},
+		{"30s", 30 * time.Second},
+		{"1h", time.Hour},
+		{"2d", 48 * time.Hour},
+		{"500ms", 500 * time.Millisecond},
+		{"10micros", 10 * time.Microsecond},
+		{"10nanos", 10},
+		{"", 0},
+		{"5", 0},
+		{"1.5m", 0},
+		{"-1m", 0},
+	}
+	for _, tt := range tests {
+		if have := parseKeepAlive(tt.Input); tt.Want != have {
+			t.Errorf("parseKeepAlive(%q): expected %v; got: %v", tt.Input, tt.Want, have)
+		}
+	}
+}
+
+func TestPointInTimePaginatorResume(t *testing.T) {
+	ts := newIteratorTestServer(t, 10)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read a page and take a checkpoint, but don't close the paginator
+	p := NewPointInTimePaginator(client.Search("twitter").Sort("id", true).Size(2)).RefreshInterval(-1)
+	if _, err := p.Next(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(p.Checkpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp PaginationCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "pit-2", cp.PitId; want != have {
+		t.Fatalf("expected PitId %s; got: %s", want, have)
+	}
+	if cp.Expired() || cp.Done {
+		t.Fatalf("expected checkpoint to be neither expired nor done; got: %+v", cp)
+	}
+
+	tests := []struct {
+		Name   string
+		Modify func(cp *PaginationCheckpoint)
+		Opened int
+	}{
+		{"alive", func(cp *PaginationCheckpoint) {}, 1},
+		{"expired", func(cp *PaginationCheckpoint) { cp.Expires = time.Now().Add(-time.Minute) }, 2},
+		{"missing", func(cp *PaginationCheckpoint) { cp.PitId = "missing" }, 3},
+	}
+	for i, tt := range tests {
+		resumed := cp
+		tt.Modify(&resumed)
+
+		p := NewPointInTimePaginator(client.Search("twitter").Sort("id", true).Size(2)).RefreshInterval(-1).Resume(&resumed)
+		res, err := p.Next(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.Name, err)
+		}
+		if want, have := "3", res.Hits.Hits[0].Id; want != have {
+			t.Fatalf("%s: expected first hit %s; got: %s", tt.Name, want, have)
+		}
+		// A new point in time skips the _shard_doc value of the checkpoint
+		ts.mu.Lock()
+		after := ts.searches[len(ts.searches)-1]["search_after"].([]interface{})
+		ts.mu.Unlock()
+		wantAfter := float64(2)
+		if tt.Opened > 1 {
+			wantAfter = float64(math.MaxInt64)
+		}
+		if want, have := wantAfter, after[1]; want != have {
+			t.Fatalf("%s: expected _shard_doc value %v in search_after; got: %v", tt.Name, want, have)
+		}
+		if err := p.Close(context.Background()); err != nil {
+			t.Fatalf("%s: %v", tt.Name, err)
+		}
+		ts.mu.Lock()
+		opened, closed := len(ts.opened), len(ts.closedPITs)
+		ts.mu.Unlock()
+		if want, have := tt.Opened, opened; want != have {
+			t.Fatalf("%s: expected %d opened points in time; got: %d", tt.Name, want, have)
+		}
+		// The paginator takes over the point in time of the checkpoint
+		if want, have := i+1, closed; want != have {
+			t.Fatalf("%s: expected %d closed points in time; got: %d", tt.Name, want, have)
+		}
+	}
+
+	// A closed paginator has no point in time to resume with
+	if cp := p.Checkpoint(); cp.PitId != "pit-2" {
+		t.Fatalf("expected PitId pit-2; got: %s", cp.PitId)
+	}
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cp := p.Checkpoint(); !cp.Expired() {
+		t.Fatalf("expected checkpoint of closed paginator to be expired; got: %+v", cp)
+	}
+}
+
+func TestPointInTimePaginatorResumeRequiresUniqueSort(t *testing.T) {
+	client, err := NewSimpleClient(SetURL("http://127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := &PaginationCheckpoint{SearchAfter: []interface{}{2}}
+	p := NewPointInTimePaginator(client.Search("twitter")).Resume(cp)
+	if _, err := p.Next(context.Background()); err == nil || !strings.Contains(err.Error(), "unique") {
+		t.Fatalf("expected error for resuming without a unique sort; got: %v", err)
+	}
+}
+
+func TestPointInTimePaginatorResumeOtherSlice(t *testing.T) {
+	client, err := NewSimpleClient(SetURL("http://127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := client.Search("twitter").SearchSource(NewSearchSource().Slice(NewSliceQuery().Id(1).Max(2)))
+	p := NewPointInTimePaginator(search).Resume(&PaginationCheckpoint{PitId: "pit-1", Slice: 0, Slices: 2})
+	if _, err := p.Next(context.Background()); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("expected error for checkpoint of other slice; got: %v", err)
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	promCounter   = "counter"
+	promGauge     = "gauge"
+	promHistogram = "histogram"
+)
+
+// DefaultPrometheusBuckets are the default upper bounds (in seconds)
+// of the buckets of the histograms of a PrometheusCollector.
+var DefaultPrometheusBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// promMetrics are the metrics collected by PrometheusCollector,
+// with their type and help text.
+var promMetrics = map[string]struct{ typ, help string }{
+	"requests_total":               {promCounter, "Number of requests sent to Elasticsearch, including retries."},
+	"requests_in_flight":           {promGauge, "Number of requests currently in flight."},
+	"request_duration_seconds":     {promHistogram, "Duration of requests to Elasticsearch."},
+	"retries_total":                {promCounter, "Number of retried requests."},
+	"node_up":                      {promGauge, "Whether a node is alive (1) or dead (0)."},
+	"node_dead_total":              {promCounter, "Number of times a node has been marked as dead."},
+	"sniffs_total":                 {promCounter, "Number of sniff operations."},
+	"sniff_nodes":                  {promGauge, "Number of nodes found by the last successful sniff operation."},
+	"sniff_duration_seconds":       {promHistogram, "Duration of sniff operations."},
+	"healthchecks_total":           {promCounter, "Number of health checks per node."},
+	"bulk_commits_total":           {promCounter, "Number of commits of bulk processors."},
+	"bulk_requests_total":          {promCounter, "Number of bulk requests committed by bulk processors."},
+	"bulk_commit_duration_seconds": {promHistogram, "Duration of commits of bulk processors, including retries."},
+}
+
+// PrometheusCollector is an Observer that collects metrics in memory,
+// and writes them in the Prometheus text exposition format. Use it with
+// SetObserver, and expose it e.g. via its ServeHTTP method:
+//
+//	metrics := elastic.NewPrometheusCollector()
+//	client, err := elastic.NewClient(elastic.SetObserver(metrics))
+//	...
+//	http.Handle("/metrics", metrics)
+//
+// The names of all metrics are prefixed with the namespace, "elastic"
+// by default, e.g. "elastic_requests_total".
+type PrometheusCollector struct {
+	mu        sync.Mutex
+	namespace string
+	buckets   []float64
+	families  map[string]*promFamily
+}
+
+// promFamily is a metric with all of its series.
+type promFamily struct {
+	typ    string
+	help   string
+	series map[string]*promSeries // by rendered labels
+}
+
+// promSeries is a metric with a specific set of labels.
+type promSeries struct {
+	labels string   // rendered labels, e.g. `method="GET",status="200"`
+	value  float64  // value of a counter or gauge
+	counts []uint64 // number of observations per bucket of a histogram
+	sum    float64  // sum of observations of a histogram
+	count  uint64   // number of observations of a histogram
+}
+
+// NewPrometheusCollector creates a new PrometheusCollector.
+func NewPrometheusCollector() *PrometheusCollector {
+	return &PrometheusCollector{
+		namespace: "elastic",
+		buckets:   DefaultPrometheusBuckets,
+		families:  make(map[string]*promFamily),
+	}
+}
+
+// Namespace sets the prefix of the names of all metrics ("elastic"
+// by default). Use an empty string to disable the prefix.
+func (c *PrometheusCollector) Namespace(namespace string) *PrometheusCollector {
+	c.mu.Lock()
+	c.namespace = namespace
+	c.mu.Unlock()
+	return c
+}
+
+// Buckets sets the upper bounds (in seconds) of the buckets of the
+// histograms (see DefaultPrometheusBuckets). Call it before the
+// collector is used.
+func (c *PrometheusCollector) Buckets(buckets ...float64) *PrometheusCollector {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	c.mu.Lock()
+	c.buckets = sorted
+	c.mu.Unlock()
+	return c
+}
+
+// RequestStart is called before an attempt of a request is sent.
+func (c *PrometheusCollector) RequestStart(ctx context.Context, event RequestStartEvent) {
+	c.add("requests_in_flight", 1)
+}
+
+// RequestFinish is called after an attempt of a request returned.
+func (c *PrometheusCollector) RequestFinish(ctx context.Context, event RequestFinishEvent) {
+	status := "error"
+	if event.Err == nil {
+		status = strconv.Itoa(event.StatusCode)
+	}
+	c.add("requests_in_flight", -1)
+	c.add("requests_total", 1, "method", event.Method, "node", event.Node, "status", status)
+	c.observe("request_duration_seconds", event.Duration.Seconds(), "method", event.Method)
+}
+
+// Retry is called when a request is going to be retried.
+func (c *PrometheusCollector) Retry(ctx context.Context, event RetryEvent) {
+	c.add("retries_total", 1, "method", event.Method, "node", event.Node)
+}
+
+// NodeDead is called when a node is marked as dead.
+func (c *PrometheusCollector) NodeDead(ctx context.Context, event NodeEvent) {
+	c.set("node_up", 0, "node", event.Node)
+	c.add("node_dead_total", 1, "node", event.Node)
+}
+
+// NodeAlive is called when a dead node is marked as alive again.
+func (c *PrometheusCollector) NodeAlive(ctx context.Context, event NodeEvent) {
+	c.set("node_up", 1, "node", event.Node)
+}
+
+// Sniff is called after the cluster has been sniffed.
+func (c *PrometheusCollector) Sniff(ctx context.Context, event SniffEvent) {
+	if event.Err != nil {
+		c.add("sniffs_total", 1, "result", "error")
+		return
+	}
+	c.add("sniffs_total", 1, "result", "success")
+	c.set("sniff_nodes", float64(event.Nodes))
+	c.observe("sniff_duration_seconds", event.Duration.Seconds())
+}
+
+// Healthcheck is called after a node has been health-checked.
+func (c *PrometheusCollector) Healthcheck(ctx context.Context, event HealthcheckEvent) {
+	if event.Healthy {
+		c.add("healthchecks_total", 1, "node", event.Node, "result", "healthy")
+		c.set("node_up", 1, "node", event.Node)
+	} else {
+		c.add("healthchecks_total", 1, "node", event.Node, "result", "unhealthy")
+		c.set("node_up", 0, "node", event.Node)
+	}
+}
+
+// BulkCommit is called after a BulkProcessor committed its requests.
+func (c *PrometheusCollector) BulkCommit(ctx context.Context, event BulkCommitEvent) {
+	if event.Err != nil {
+		c.add("bulk_commits_total", 1, "processor", event.Processor, "result", "error")
+	} else {
+		c.add("bulk_commits_total", 1, "processor", event.Processor, "result", "success")
+	}
+	c.add("bulk_requests_total", float64(event.Succeeded), "processor", event.Processor, "result", "succeeded")
+	c.add("bulk_requests_total", float64(event.Failed), "processor", event.Processor, "result", "failed")
+	c.observe("bulk_commit_duration_seconds", event.Duration.Seconds(), "processor", event.Processor)
+}
+
+// series returns the series of the given metric and labels, and creates
+// it if necessary. The caller must hold the lock.
+func (c *PrometheusCollector) series(name string, labels []string) *promSeries {
+	f, found := c.families[name]
+	if !found {
+		m := promMetrics[name]
+		f = &promFamily{typ: m.typ, help: m.help, series: make(map[string]*promSeries)}
+		c.families[name] = f
+	}
+	key := promLabels(labels)
+	s, found := f.series[key]
+	if !found {
+		s = &promSeries{labels: key}
+		if f.typ == promHistogram {
+			s.counts = make([]uint64, len(c.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// add adds delta to a counter or gauge.
+func (c *PrometheusCollector) add(name string, delta float64, labels ...string) {
+	c.mu.Lock()
+	c.series(name, labels).value += delta
+	c.mu.Unlock()
+}
+
+// set sets a gauge.
+func (c *PrometheusCollector) set(name string, value float64, labels ...string) {
+	c.mu.Lock()
+	c.series(name, labels).value = value
+	c.mu.Unlock()
+}
+
+// observe adds an observation to a histogram.
+func (c *PrometheusCollector) observe(name string, value float64, labels ...string) {
+	c.mu.Lock()
+	s := c.series(name, labels)
+	if i := sort.SearchFloat64s(c.buckets, value); i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+	c.mu.Unlock()
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (c *PrometheusCollector) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	c.mu.Lock()
+	names := make([]string, 0, len(c.families))
+	for name := range c.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := c.families[name]
+		fullName := name
+		if c.namespace != "" {
+			fullName = c.namespace + "_" + name
+		}
+		bw.WriteString("# HELP " + fullName + " " + f.help + "\n")
+		bw.WriteString("# TYPE " + fullName + " " + f.typ + "\n")
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.typ != promHistogram {
+				writePromSample(bw, fullName, s.labels, s.value)
+				continue
+			}
+			var cumulative uint64
+			for i, upper := range c.buckets {
+				if i < len(s.counts) {
+					cumulative += s.counts[i]
+				}
+				writePromSample(bw, fullName+"_bucket", joinPromLabels(s.labels, `le="`+formatPromValue(upper)+`"`), float64(cumulative))
+			}
+			writePromSample(bw, fullName+"_bucket", joinPromLabels(s.labels, `le="+Inf"`), float64(s.count))
+			writePromSample(bw, fullName+"_sum", s.labels, s.sum)
+			writePromSample(bw, fullName+"_count", s.labels, float64(s.count))
+		}
+	}
+	c.mu.Unlock()
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format.
+func (c *PrometheusCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// promLabels renders the given label names and values.
+func promLabels(labels []string) string {
+	var sb strings.Builder
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(labels[i])
+		sb.WriteString(`="`)
+		sb.WriteString(promLabelEscaper.Replace(labels[i+1]))
+		sb.WriteByte('"')
+	}
+	return sb.String()
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// joinPromLabels appends a rendered label to rendered labels.
+func joinPromLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+// writePromSample writes a single line of the text exposition format.
+func writePromSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(name)
+	if labels != "" {
+		w.WriteString("{" + labels + "}")
+	}
+	w.WriteString(" " + formatPromValue(value) + "\n")
+}
+
+// formatPromValue formats a value for the text exposition format.
+func formatPromValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
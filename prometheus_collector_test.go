@@ -0,0 +1,122 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusCollectorWriteTo(t *testing.T) {
+	c := NewPrometheusCollector().Buckets(0.1, 1)
+	ctx := context.Background()
+
+	c.RequestStart(ctx, RequestStartEvent{Method: "GET", Path: "/", Node: "http://127.0.0.1:9200", Attempt: 1})
+	c.RequestFinish(ctx, RequestFinishEvent{Method: "GET", Path: "/", Node: "http://127.0.0.1:9200", Attempt: 1, StatusCode: 200, Duration: 50 * time.Millisecond})
+	c.RequestStart(ctx, RequestStartEvent{Method: "GET", Path: "/", Node: "http://127.0.0.1:9200", Attempt: 1})
+	c.RequestFinish(ctx, RequestFinishEvent{Method: "GET", Path: "/", Node: "http://127.0.0.1:9200", Attempt: 1, Duration: 2 * time.Second, Err: errors.New("timeout")})
+	c.Retry(ctx, RetryEvent{Method: "GET", Path: "/", Node: "http://127.0.0.1:9200", Attempt: 2})
+	c.NodeDead(ctx, NodeEvent{Node: "http://127.0.0.1:9200"})
+	c.BulkCommit(ctx, BulkCommitEvent{Processor: `my "bulk"`, Requests: 3, Succeeded: 2, Failed: 1, Duration: 500 * time.Millisecond})
+
+	var buf bytes.Buffer
+	n, err := c.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(buf.Len()), n; want != have {
+		t.Fatalf("expected %d bytes; got: %d", want, have)
+	}
+	expected := `# HELP elastic_bulk_commit_duration_seconds Duration of commits of bulk processors, including retries.
+# TYPE elastic_bulk_commit_duration_seconds histogram
+elastic_bulk_commit_duration_seconds_bucket{processor="my \"bulk\"",le="0.1"} 0
+elastic_bulk_commit_duration_seconds_bucket{processor="my \"bulk\"",le="1"} 1
+elastic_bulk_commit_duration_seconds_bucket{processor="my \"bulk\"",le="+Inf"} 1
+elastic_bulk_commit_duration_seconds_sum{processor="my \"bulk\""} 0.5
+elastic_bulk_commit_duration_seconds_count{processor="my \"bulk\""} 1
+# HELP elastic_bulk_commits_total Number of commits of bulk processors.
+# TYPE elastic_bulk_commits_total counter
+elastic_bulk_commits_total{processor="my \"bulk\"",result="success"} 1
+# HELP elastic_bulk_requests_total Number of bulk requests committed by bulk processors.
+# TYPE elastic_bulk_requests_total counter
+elastic_bulk_requests_total{processor="my \"bulk\"",result="failed"} 1
+elastic_bulk_requests_total{processor="my \"bulk\"",result="succeeded"} 2
+# HELP elastic_node_dead_total Number of times a node has been marked as dead.
+# TYPE elastic_node_dead_total counter
+elastic_node_dead_total{node="http://127.0.0.1:9200"} 1
+# HELP elastic_node_up Whether a node is alive (1) or dead (0).
+# TYPE elastic_node_up gauge
+elastic_node_up{node="http://127.0.0.1:9200"} 0
+# HELP elastic_request_duration_seconds Duration of requests to Elasticsearch.
+# TYPE elastic_request_duration_seconds histogram
+elastic_request_duration_seconds_bucket{method="GET",le="0.1"} 1
+elastic_request_duration_seconds_bucket{method="GET",le="1"} 1
+elastic_request_duration_seconds_bucket{method="GET",le="+Inf"} 2
+elastic_request_duration_seconds_sum{method="GET"} 2.05
+elastic_request_duration_seconds_count{method="GET"} 2
+# HELP elastic_requests_in_flight Number of requests currently in flight.
+# TYPE elastic_requests_in_flight gauge
+elastic_requests_in_flight 0
+# HELP elastic_requests_total Number of requests sent to Elasticsearch, including retries.
+# TYPE elastic_requests_total counter
+elastic_requests_total{method="GET",node="http://127.0.0.1:9200",status="200"} 1
+elastic_requests_total{method="GET",node="http://127.0.0.1:9200",status="error"} 1
+# HELP elastic_retries_total Number of retried requests.
+# TYPE elastic_retries_total counter
+elastic_retries_total{method="GET",node="http://127.0.0.1:9200"} 1
+`
+	if want, have := expected, buf.String(); want != have {
+		t.Fatalf("expected\n%s\ngot:\n%s", want, have)
+	}
+}
+
+func TestPrometheusCollectorNamespace(t *testing.T) {
+	c := NewPrometheusCollector().Namespace("es")
+	c.Sniff(context.Background(), SniffEvent{Err: errors.New("sniff timeout")})
+
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\nes_sniffs_total{result=\"error\"} 1\n") {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestPrometheusCollectorOnClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	metrics := NewPrometheusCollector()
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false), SetObserver(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if want, have := "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"); want != have {
+		t.Fatalf("expected Content-Type %q; got: %q", want, have)
+	}
+	line := `elastic_requests_total{method="GET",node="` + ts.URL + `",status="200"} 3`
+	if !strings.Contains(rec.Body.String(), line+"\n") {
+		t.Fatalf("expected %q in output:\n%s", line, rec.Body.String())
+	}
+}
@@ -13,6 +13,9 @@
 // The speedup of sliced scrolling can be significant but is very
 // dependent on the specific use case.
 //
+// The elastic.SlicedScanService implements the same pattern, including
+// bounded concurrency and cleanup of all scrolls on errors.
+//
 // See https://www.elastic.co/guide/en/elasticsearch/reference/7.0/search-request-scroll.html#sliced-scroll
 // for details on sliced scrolling in Elasticsearch.
 //
@@ -0,0 +1,204 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+// ReloadService changes the URLs, credentials, default headers and
+// retrier of a running Client, e.g. to rotate credentials or to move
+// to a different cluster without restarting the application.
+//
+// All changes are applied atomically when calling Do. Requests started
+// afterwards use the new settings, while requests in flight finish with
+// the settings they started with. Settings that are not specified are
+// left unchanged.
+//
+// Example:
+//
+//	err := client.Reload().
+//		URL("https://es1:9200", "https://es2:9200").
+//		APIKey(id, key).
+//		Do(ctx)
+type ReloadService struct {
+	client *Client
+
+	urls         []string
+	setAuth      bool
+	username     string
+	password     string
+	authProvider AuthProvider
+	setHeaders   bool
+	headers      http.Header
+	setRetrier   bool
+	retrier      Retrier
+}
+
+// Reload returns a service to change the settings of the client
+// while it is running.
+func (c *Client) Reload() *ReloadService {
+	return &ReloadService{client: c}
+}
+
+// URL replaces the URLs of the client. If sniffing is enabled, the
+// cluster is sniffed again via the new URLs.
+func (s *ReloadService) URL(urls ...string) *ReloadService {
+	s.urls = urls
+	return s
+}
+
+// BasicAuth replaces the credentials of the client by the given HTTP
+// Basic Auth credentials. It removes an API key or AuthProvider.
+func (s *ReloadService) BasicAuth(username, password string) *ReloadService {
+	s.setAuth = true
+	s.username = username
+	s.password = password
+	s.authProvider = nil
+	return s
+}
+
+// APIKey replaces the credentials of the client by the given API key.
+// It removes the HTTP Basic Auth credentials.
+func (s *ReloadService) APIKey(id, key string) *ReloadService {
+	return s.AuthProvider(NewAPIKeyAuth(id, key))
+}
+
+// AuthProvider replaces the credentials of the client by the given
+// AuthProvider. It removes the HTTP Basic Auth credentials.
+func (s *ReloadService) AuthProvider(provider AuthProvider) *ReloadService {
+	s.setAuth = true
+	s.username = ""
+	s.password = ""
+	s.authProvider = provider
+	return s
+}
+
+// Headers replaces the default HTTP headers of the client. Use nil to
+// remove all default headers.
+func (s *ReloadService) Headers(headers http.Header) *ReloadService {
+	s.setHeaders = true
+	s.headers = headers
+	return s
+}
+
+// Retrier replaces the retrier of the client. Use nil to disable retries.
+func (s *ReloadService) Retrier(retrier Retrier) *ReloadService {
+	s.setRetrier = true
+	s.retrier = retrier
+	return s
+}
+
+// Do applies the changes to the client.
+func (s *ReloadService) Do(ctx context.Context) error {
+	c := s.client
+
+	var urls []string
+	if s.urls != nil {
+		urls = canonicalize(s.urls...)
+		if len(urls) == 0 {
+			return errors.Wrap(ErrNoClient, "no valid URLs to reload")
+		}
+	}
+	var headers http.Header
+	if s.headers != nil {
+		headers = s.headers.Clone()
+	}
+	retrier := s.retrier
+	if retrier == nil {
+		retrier = noRetries
+	}
+
+	c.mu.Lock()
+	if urls != nil {
+		c.urls = urls
+
+		// If the URLs have auth info, use them as with SetURL
+		if !s.setAuth && c.authProvider == nil {
+			for _, urlStr := range urls {
+				u, err := url.Parse(urlStr)
+				if err == nil && u.User != nil {
+					c.basicAuthUsername = u.User.Username()
+					c.basicAuthPassword, _ = u.User.Password()
+					break
+				}
+			}
+		}
+	}
+	if s.setAuth {
+		c.basicAuthUsername = s.username
+		c.basicAuthPassword = s.password
+		c.authProvider = s.authProvider
+	}
+	if s.setHeaders {
+		c.headers = headers
+	}
+	if s.setRetrier {
+		c.retrier = retrier
+	}
+	if urls != nil {
+		// Replace the connections while holding the lock, so that new
+		// requests never see the new settings with the old connections
+		c.replaceConns(urls)
+	}
+	running := c.running
+	snifferEnabled := c.snifferEnabled
+	healthcheckEnabled := c.healthcheckEnabled
+	c.mu.Unlock()
+
+	if urls == nil {
+		return nil
+	}
+	c.log(ctx, LogLevelInfo, fmt.Sprintf("elastic: reloaded URLs %v", urls),
+		"nodes", len(urls))
+
+	// Let the background processes pick up the new URLs straight away
+	if running && snifferEnabled {
+		notifyNow(c.snifferNow)
+	}
+	if running && healthcheckEnabled {
+		notifyNow(c.healthcheckNow)
+	}
+	return nil
+}
+
+// replaceConns replaces all connections by connections to the given
+// URLs. Existing connections to one of the URLs are kept, including their
+// state. Sniff operations started before are discarded.
+func (c *Client) replaceConns(urls []string) {
+	c.connsMu.Lock()
+	defer c.connsMu.Unlock()
+
+	conns := make([]*conn, 0, len(urls))
+	for _, u := range urls {
+		var found *conn
+		for _, oldConn := range c.conns {
+			if oldConn.URL() == u {
+				found = oldConn
+				break
+			}
+		}
+		if found == nil {
+			found = newConn(u, u)
+		}
+		conns = append(conns, found)
+	}
+	c.conns = conns
+	c.connsGen++
+}
+
+// notifyNow notifies a background process without blocking. A pending
+// notification is not duplicated.
+func notifyNow(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
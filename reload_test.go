@@ -0,0 +1,300 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reloadTestServer is a fake Elasticsearch node that records requests.
+type reloadTestServer struct {
+	*httptest.Server
+	nodeID string
+
+	mu       sync.Mutex
+	requests []*http.Request
+	sniffed  chan struct{}
+	checked  chan struct{}
+}
+
+func newReloadTestServer(nodeID string) *reloadTestServer {
+	s := &reloadTestServer{
+		nodeID:  nodeID,
+		sniffed: make(chan struct{}, 100),
+		checked: make(chan struct{}, 100),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == "HEAD":
+			s.checked <- struct{}{}
+		case r.URL.Path == "/_nodes/http":
+			u, _ := url.Parse(s.URL)
+			fmt.Fprintf(w, `{"nodes":{%q:{"name":%q,"http":{"publish_address":%q}}}}`, s.nodeID, s.nodeID, u.Host)
+			s.sniffed <- struct{}{}
+		default:
+			fmt.Fprintf(w, `{"name":%q}`, s.nodeID)
+		}
+	}))
+	return s
+}
+
+// lastRequest returns the last request that was not a health check
+// or sniff, or nil.
+func (s *reloadTestServer) lastRequest() *http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.requests) - 1; i >= 0; i-- {
+		if r := s.requests[i]; r.Method != "HEAD" && r.URL.Path != "/_nodes/http" {
+			return r
+		}
+	}
+	return nil
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected %s", what)
+	}
+}
+
+func TestReloadURLsAndCredentials(t *testing.T) {
+	a := newReloadTestServer("a")
+	defer a.Close()
+	b := newReloadTestServer("b")
+	defer b.Close()
+
+	client, err := NewSimpleClient(
+		SetURL(a.URL),
+		SetBasicAuth("user", "secret"),
+		SetHeaders(http.Header{"X-Old": []string{"1"}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := a.lastRequest()
+	if r == nil {
+		t.Fatal("expected a request to the old URL")
+	}
+	if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+		t.Fatalf("expected basic auth user:secret; got: %q:%q", username, password)
+	}
+
+	err = client.Reload().
+		URL(b.URL+"/").
+		APIKey("id", "key").
+		Headers(http.Header{"X-New": []string{"2"}}).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{b.URL}, client.urls; len(have) != 1 || have[0] != want[0] {
+		t.Fatalf("expected urls = %v; got: %v", want, have)
+	}
+	if want, have := 1, len(client.conns); want != have {
+		t.Fatalf("expected %d connections; got: %d", want, have)
+	}
+
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = b.lastRequest()
+	if r == nil {
+		t.Fatal("expected a request to the new URL")
+	}
+	if want, have := "ApiKey aWQ6a2V5", r.Header.Get("Authorization"); want != have {
+		t.Fatalf("expected Authorization = %q; got: %q", want, have)
+	}
+	if want, have := "2", r.Header.Get("X-New"); want != have {
+		t.Fatalf("expected X-New = %q; got: %q", want, have)
+	}
+	if have := r.Header.Get("X-Old"); have != "" {
+		t.Fatalf("expected no X-Old header; got: %q", have)
+	}
+}
+
+func TestReloadRetrier(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL), SetRetryStatusCodes(http.StatusServiceUnavailable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrier := &testRetrier{Retrier: NewStopRetrier()}
+	if err := client.Reload().Retrier(retrier).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if want, have := int64(1), retrier.N; want != have {
+		t.Fatalf("expected %d calls to the retrier; got: %d", want, have)
+	}
+}
+
+func TestReloadSniffsNewURLs(t *testing.T) {
+	a := newReloadTestServer("a")
+	defer a.Close()
+	b := newReloadTestServer("b")
+	defer b.Close()
+
+	client, err := NewClient(
+		SetURL(a.URL),
+		SetSniff(true),
+		SetSnifferInterval(time.Hour),
+		SetHealthcheck(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+	if want, have := "a", client.conns[0].NodeID(); want != have {
+		t.Fatalf("expected NodeID = %q; got: %q", want, have)
+	}
+
+	if err := client.Reload().URL(b.URL).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, b.sniffed, "the sniffer to sniff the new URL")
+
+	// Wait for the sniffer to update the connections
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		client.connsMu.RLock()
+		conns := client.conns
+		client.connsMu.RUnlock()
+		if len(conns) == 1 && conns[0].NodeID() == "b" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected connections to node b; got: %v", conns)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReloadHealthchecksNewURLs(t *testing.T) {
+	a := newReloadTestServer("a")
+	defer a.Close()
+	b := newReloadTestServer("b")
+	defer b.Close()
+
+	client, err := NewClient(
+		SetURL(a.URL),
+		SetSniff(false),
+		SetHealthcheck(true),
+		SetHealthcheckInterval(time.Hour),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	if err := client.Reload().URL(b.URL).Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, b.checked, "the healthchecker to check the new URL")
+}
+
+func TestReloadDiscardsSniffOfOldURLs(t *testing.T) {
+	client, err := NewSimpleClient(SetURL("http://127.0.0.1:9200"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.connsMu.RLock()
+	gen := client.connsGen
+	client.connsMu.RUnlock()
+
+	if err := client.Reload().URL("http://127.0.0.1:9201").Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sniff that started before the reload must not restore the old nodes
+	client.updateConns([]*conn{newConn("old", "http://127.0.0.1:9200")}, gen)
+	if want, have := "http://127.0.0.1:9201", client.conns[0].URL(); len(client.conns) != 1 || want != have {
+		t.Fatalf("expected connection to %s; got: %v", want, client.conns)
+	}
+}
+
+func TestReloadWithInvalidURL(t *testing.T) {
+	client, err := NewSimpleClient(SetURL("http://127.0.0.1:9200"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Reload().URL("ftp://127.0.0.1").BasicAuth("user", "secret").Do(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if want, have := "http://127.0.0.1:9200", client.urls[0]; want != have {
+		t.Fatalf("expected URL = %q; got: %q", want, have)
+	}
+	if have := client.basicAuthUsername; have != "" {
+		t.Fatalf("expected credentials to be unchanged; got: %q", have)
+	}
+}
+
+func TestReloadConcurrently(t *testing.T) {
+	a := newReloadTestServer("a")
+	defer a.Close()
+	b := newReloadTestServer("b")
+	defer b.Close()
+
+	client, err := NewSimpleClient(SetURL(a.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				_, err := client.PerformRequest(ctx, PerformRequestOptions{Method: "GET", Path: "/"})
+				if err != nil && ctx.Err() == nil {
+					t.Errorf("expected no error; got: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		u := a.URL
+		if i%2 == 0 {
+			u = b.URL
+		}
+		err := client.Reload().URL(u).BasicAuth("user", fmt.Sprint(i)).Headers(http.Header{"X-Reload": []string{fmt.Sprint(i)}}).Do(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+}
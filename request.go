@@ -7,11 +7,11 @@ package elastic
 import (
 	"bytes"
 	"compress/gzip"
-	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 // Elasticsearch-specific HTTP request
@@ -36,29 +36,35 @@ func (r *Request) SetBasicAuth(username, password string) {
 // SetBody encodes the body in the request. You may pass a flag to
 // compress the request via gzip.
 func (r *Request) SetBody(body interface{}, gzipCompress bool) error {
+	return r.setBody(body, gzipCompress, nil)
+}
+
+// setBody encodes the body in the request like SetBody, using enc to
+// encode bodies that are not strings. If enc is nil, DefaultEncoder is used.
+func (r *Request) setBody(body interface{}, gzipCompress bool, enc Encoder) error {
 	switch b := body.(type) {
 	case string:
 		if gzipCompress {
-			return r.setBodyGzip(b)
+			return r.setBodyGzip(b, enc)
 		}
 		return r.setBodyString(b)
 	default:
 		if gzipCompress {
-			return r.setBodyGzip(body)
+			return r.setBodyGzip(body, enc)
 		}
-		return r.setBodyJson(body)
+		return r.setBodyJson(body, enc)
 	}
 }
 
-// setBodyJson encodes the body as a struct to be marshaled via json.Marshal.
-func (r *Request) setBodyJson(data interface{}) error {
-	body, err := json.Marshal(data)
-	if err != nil {
+// setBodyJson encodes the body as a struct to be marshaled via enc.
+func (r *Request) setBodyJson(data interface{}, enc Encoder) error {
+	buf := getBodyBuffer()
+	if err := encodeBody(buf, data, enc); err != nil {
+		putBodyBuffer(buf)
 		return err
 	}
 	r.Header.Set("Content-Type", "application/json")
-	r.setBodyReader(bytes.NewReader(body))
-	return nil
+	return r.setBodyReader(newPooledBody(buf))
 }
 
 // setBodyString encodes the body as a string.
@@ -67,39 +73,31 @@ func (r *Request) setBodyString(body string) error {
 }
 
 // setBodyGzip gzip's the body. It accepts both strings and structs as body.
-// The latter will be encoded via json.Marshal.
-func (r *Request) setBodyGzip(body interface{}) error {
+// The latter will be encoded via enc.
+func (r *Request) setBodyGzip(body interface{}, enc Encoder) error {
+	buf := getBodyBuffer()
+	w := getGzipWriter(buf)
+	var err error
 	switch b := body.(type) {
 	case string:
-		buf := new(bytes.Buffer)
-		w := gzip.NewWriter(buf)
-		if _, err := w.Write([]byte(b)); err != nil {
-			return err
-		}
-		if err := w.Close(); err != nil {
-			return err
-		}
-		r.Header.Add("Content-Encoding", "gzip")
-		r.Header.Add("Vary", "Accept-Encoding")
-		return r.setBodyReader(bytes.NewReader(buf.Bytes()))
+		_, err = io.WriteString(w, b)
 	default:
-		data, err := json.Marshal(b)
-		if err != nil {
-			return err
-		}
-		buf := new(bytes.Buffer)
-		w := gzip.NewWriter(buf)
-		if _, err := w.Write(data); err != nil {
-			return err
-		}
-		if err := w.Close(); err != nil {
-			return err
-		}
-		r.Header.Add("Content-Encoding", "gzip")
-		r.Header.Add("Vary", "Accept-Encoding")
+		err = encodeBody(w, b, enc)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	putGzipWriter(w)
+	if err != nil {
+		putBodyBuffer(buf)
+		return err
+	}
+	r.Header.Add("Content-Encoding", "gzip")
+	r.Header.Add("Vary", "Accept-Encoding")
+	if _, ok := body.(string); !ok {
 		r.Header.Set("Content-Type", "application/json")
-		return r.setBodyReader(bytes.NewReader(buf.Bytes()))
 	}
+	return r.setBodyReader(newPooledBody(buf))
 }
 
 // setBodyReader writes the body from an io.Reader.
@@ -115,7 +113,95 @@ func (r *Request) setBodyReader(body io.Reader) error {
 			r.ContentLength = int64(v.Len())
 		case *bytes.Buffer:
 			r.ContentLength = int64(v.Len())
+		case *pooledBody:
+			r.ContentLength = int64(v.Len())
 		}
 	}
 	return nil
 }
+
+// maxPooledBufferSize is the capacity of the largest buffer that is
+// returned to bodyBufferPool. Larger buffers are left to the garbage
+// collector so that a few large requests don't pin memory.
+const maxPooledBufferSize = 64 << 10
+
+var (
+	bodyBufferPool = sync.Pool{
+		New: func() interface{} { return new(bytes.Buffer) },
+	}
+	gzipWriterPool = sync.Pool{
+		New: func() interface{} { return gzip.NewWriter(nil) },
+	}
+)
+
+// getBodyBuffer returns an empty buffer from the pool.
+func getBodyBuffer() *bytes.Buffer {
+	buf := bodyBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBodyBuffer returns a buffer to the pool.
+func putBodyBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bodyBufferPool.Put(buf)
+}
+
+// getGzipWriter returns a gzip.Writer from the pool that writes to w.
+func getGzipWriter(w io.Writer) *gzip.Writer {
+	gw := gzipWriterPool.Get().(*gzip.Writer)
+	gw.Reset(w)
+	return gw
+}
+
+// putGzipWriter returns a gzip.Writer to the pool.
+func putGzipWriter(gw *gzip.Writer) {
+	gw.Reset(nil)
+	gzipWriterPool.Put(gw)
+}
+
+// pooledBody is a request body that returns its buffer to the pool when
+// it is closed. The transport closes the body after sending the request,
+// possibly from a different goroutine, so access is synchronized.
+type pooledBody struct {
+	mu  sync.Mutex
+	buf *bytes.Buffer
+}
+
+func newPooledBody(buf *bytes.Buffer) *pooledBody {
+	return &pooledBody{buf: buf}
+}
+
+// Len returns the number of unread bytes of the body.
+func (b *pooledBody) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf == nil {
+		return 0
+	}
+	return b.buf.Len()
+}
+
+// Read reads from the body. It returns io.EOF after the body is closed.
+func (b *pooledBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf == nil {
+		return 0, io.EOF
+	}
+	return b.buf.Read(p)
+}
+
+// Close returns the buffer to the pool. It is safe to call Close
+// more than once.
+func (b *pooledBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.buf != nil {
+		putBodyBuffer(b.buf)
+		b.buf = nil
+	}
+	return nil
+}
@@ -4,7 +4,10 @@
 
 package elastic
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+)
 
 var testReq *Request // used as a temporary variable to avoid compiler optimizations in tests/benchmarks
 
@@ -128,3 +131,20 @@ func BenchmarkRequestSetBodyMapGzip(b *testing.B) {
 	testReq = req
 	b.ReportAllocs()
 }
+
+func BenchmarkRequestSetBodyRawMessage(b *testing.B) {
+	req, err := NewRequest("GET", "/")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		body := json.RawMessage(`{"query":{"match_all":{}}}`)
+		err = req.SetBody(body, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		req.Body.Close()
+	}
+	testReq = req
+	b.ReportAllocs()
+}
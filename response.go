@@ -29,6 +29,9 @@ type Response struct {
 	// DeprecationWarnings lists all deprecation warnings returned from
 	// Elasticsearch.
 	DeprecationWarnings []string
+	// Warnings lists all deprecation warnings returned from Elasticsearch,
+	// parsed from the Warning headers.
+	Warnings []DeprecationWarning
 	// BodyReader is the body as a reader. Only available if streaming is enabled.
 	BodyReader io.ReadCloser
 }
@@ -39,9 +42,17 @@ func (c *Client) newResponse(res *http.Response, maxBodySize int64, stream bool)
 		StatusCode:          res.StatusCode,
 		Header:              res.Header,
 		DeprecationWarnings: res.Header["Warning"],
+		Warnings:            parseWarnings(res.Header),
 	}
 	if stream {
 		r.BodyReader = res.Body
+		if maxBodySize > 0 && res.Body != nil {
+			if res.ContentLength > maxBodySize {
+				res.Body.Close()
+				return nil, ErrResponseSize
+			}
+			r.BodyReader = &limitedBody{ReadCloser: res.Body, remaining: maxBodySize}
+		}
 	} else if res.Body != nil {
 		body := io.Reader(res.Body)
 		if maxBodySize > 0 {
@@ -64,3 +75,27 @@ func (c *Client) newResponse(res *http.Response, maxBodySize int64, stream bool)
 	}
 	return r, nil
 }
+
+// limitedBody returns ErrResponseSize when more than the given number
+// of bytes are read from a streamed response body.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+// Read reads from the body.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrResponseSize
+	}
+	// Read one more byte than allowed to detect oversized responses
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n - 1, ErrResponseSize
+	}
+	return n, err
+}
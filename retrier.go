@@ -7,6 +7,8 @@ package elastic
 import (
 	"context"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -33,6 +35,15 @@ type Retrier interface {
 	Retry(ctx context.Context, retry int, req *http.Request, resp *http.Response, err error) (time.Duration, bool, error)
 }
 
+// RetryObserver may be implemented by a Retrier to be notified about
+// requests that returned a response that is not retried, e.g. to
+// maintain a retry budget (see BudgetRetrier).
+type RetryObserver interface {
+	// Success is called when a request returned a response from
+	// Elasticsearch that will not be retried.
+	Success(ctx context.Context, req *http.Request, resp *http.Response)
+}
+
 // -- StopRetrier --
 
 // StopRetrier is an implementation that does no retries.
@@ -66,3 +77,247 @@ func (r *BackoffRetrier) Retry(ctx context.Context, retry int, req *http.Request
 	wait, goahead := r.backoff.Next(retry)
 	return wait, goahead, nil
 }
+
+// -- RetryBudget --
+
+// RetryBudget is a token bucket that limits the number of retries to a
+// fraction of the successful requests. It is meant to be shared by all
+// requests of a Client, so that retries do not multiply the load on
+// a cluster that is already overloaded, e.g. by returning HTTP status 429
+// (see SetRetryBudget).
+//
+// Each successful request deposits a fraction of a token, and each retry
+// withdraws a token. Additionally, a minimum number of retries per second
+// is always allowed, so that clients with little traffic can still retry.
+type RetryBudget struct {
+	mu           sync.Mutex
+	ratio        float64
+	minPerSecond float64
+	maxTokens    float64
+	tokens       float64
+	last         time.Time
+}
+
+// NewRetryBudget creates a new RetryBudget that allows ratio retries per
+// successful request (e.g. 0.1 for 10%), plus minRetriesPerSecond retries
+// per second. The number of retries that can be saved up is limited to
+// 100 by default (see MaxTokens).
+func NewRetryBudget(ratio float64, minRetriesPerSecond int) *RetryBudget {
+	b := &RetryBudget{
+		ratio:        ratio,
+		minPerSecond: float64(minRetriesPerSecond),
+		maxTokens:    100,
+		last:         time.Now(),
+	}
+	b.tokens = b.minPerSecond
+	return b
+}
+
+// MaxTokens sets the maximum number of retries that can be saved up.
+func (b *RetryBudget) MaxTokens(maxTokens int) *RetryBudget {
+	b.mu.Lock()
+	b.maxTokens = float64(maxTokens)
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+	b.mu.Unlock()
+	return b
+}
+
+// Deposit is called for each successful request.
+func (b *RetryBudget) Deposit() {
+	b.mu.Lock()
+	b.refill(time.Now())
+	b.add(b.ratio)
+	b.mu.Unlock()
+}
+
+// TryWithdraw returns true if there is enough budget for a retry,
+// and withdraws it from the budget.
+func (b *RetryBudget) TryWithdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Available returns the number of retries currently available.
+func (b *RetryBudget) Available() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(time.Now())
+	return int(b.tokens)
+}
+
+// refill adds the minimum retries per second for the time since the last refill.
+func (b *RetryBudget) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.add(elapsed.Seconds() * b.minPerSecond)
+		b.last = now
+	}
+}
+
+// add adds n tokens, limited by the maximum number of tokens.
+func (b *RetryBudget) add(n float64) {
+	b.tokens += n
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+// -- BudgetRetrier --
+
+// BudgetRetrier is a Retrier that only retries if the given RetryBudget
+// allows it. It asks the wrapped Retrier whether and when to retry.
+//
+// To limit the retries of all requests of a Client, no matter which
+// Retrier they use, use SetRetryBudget instead. If the budget of
+// SetRetryBudget is also used by a BudgetRetrier, the client doesn't
+// charge it a second time for the retries of that BudgetRetrier.
+type BudgetRetrier struct {
+	budget *RetryBudget
+	next   Retrier
+}
+
+// NewBudgetRetrier returns a retrier that limits the retries of next
+// by the given budget.
+func NewBudgetRetrier(budget *RetryBudget, next Retrier) *BudgetRetrier {
+	if next == nil {
+		next = noRetries
+	}
+	return &BudgetRetrier{budget: budget, next: next}
+}
+
+// Retry asks the wrapped Retrier and then withdraws from the budget.
+// If the budget is exhausted, it doesn't retry.
+func (r *BudgetRetrier) Retry(ctx context.Context, retry int, req *http.Request, resp *http.Response, err error) (time.Duration, bool, error) {
+	wait, goahead, rerr := r.next.Retry(ctx, retry, req, resp, err)
+	if rerr != nil || !goahead {
+		return wait, goahead, rerr
+	}
+	if !r.budget.TryWithdraw() {
+		return 0, false, nil
+	}
+	return wait, true, nil
+}
+
+// Success deposits into the budget, unless Elasticsearch is overloaded
+// or failed, i.e. it returned HTTP status 429 or 5xx.
+func (r *BudgetRetrier) Success(ctx context.Context, req *http.Request, resp *http.Response) {
+	if resp != nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		r.budget.Deposit()
+	}
+	if observer, ok := r.next.(RetryObserver); ok {
+		observer.Success(ctx, req, resp)
+	}
+}
+
+// retrierUsesBudget returns true if r, or a Retrier wrapped by it, is a
+// BudgetRetrier that withdraws from budget.
+func retrierUsesBudget(r Retrier, budget *RetryBudget) bool {
+	for r != nil {
+		switch v := r.(type) {
+		case *BudgetRetrier:
+			if v.budget == budget {
+				return true
+			}
+			r = v.next
+		case *RetryAfterRetrier:
+			r = v.next
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// -- RetryAfterRetrier --
+
+// DefaultRetryAfterMaxWait is the maximum time a RetryAfterRetrier waits
+// for a retry by default.
+const DefaultRetryAfterMaxWait = 5 * time.Second
+
+// RetryAfterRetrier is a Retrier that honours the Retry-After header
+// of responses with HTTP status 429 (Too Many Requests) and 503 (Service
+// Unavailable). It asks the wrapped Retrier whether to retry, and waits
+// at least as long as the server asked for.
+//
+// Notice that requests are only retried for HTTP status codes that
+// are passed to SetRetryStatusCodes or PerformRequestOptions.RetryStatusCodes.
+type RetryAfterRetrier struct {
+	next    Retrier
+	maxWait time.Duration
+}
+
+// NewRetryAfterRetrier returns a retrier that honours the Retry-After
+// header, and otherwise uses next.
+func NewRetryAfterRetrier(next Retrier) *RetryAfterRetrier {
+	if next == nil {
+		next = noRetries
+	}
+	return &RetryAfterRetrier{next: next, maxWait: DefaultRetryAfterMaxWait}
+}
+
+// MaxWait sets the maximum time to wait for a retry. If the server asks
+// to wait longer, the request is not retried. It is DefaultRetryAfterMaxWait
+// by default. Use 0 to wait as long as the server asks for.
+func (r *RetryAfterRetrier) MaxWait(maxWait time.Duration) *RetryAfterRetrier {
+	r.maxWait = maxWait
+	return r
+}
+
+// Retry asks the wrapped Retrier and extends the wait interval to the
+// time specified by the Retry-After header, if any.
+func (r *RetryAfterRetrier) Retry(ctx context.Context, retry int, req *http.Request, resp *http.Response, err error) (time.Duration, bool, error) {
+	wait, goahead, rerr := r.next.Retry(ctx, retry, req, resp, err)
+	if rerr != nil || !goahead || resp == nil {
+		return wait, goahead, rerr
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return wait, goahead, rerr
+	}
+	after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return wait, goahead, rerr
+	}
+	if r.maxWait > 0 && after > r.maxWait {
+		return 0, false, nil
+	}
+	if after > wait {
+		wait = after
+	}
+	return wait, true, nil
+}
+
+// Success notifies the wrapped Retrier.
+func (r *RetryAfterRetrier) Success(ctx context.Context, req *http.Request, resp *http.Response) {
+	if observer, ok := r.next.(RetryObserver); ok {
+		observer.Success(ctx, req, resp)
+	}
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is
+// either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
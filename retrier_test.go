@@ -8,6 +8,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"net/http/httptest"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -172,3 +173,309 @@ func TestRetrierOnPerformRequest(t *testing.T) {
 		t.Errorf("requestRetrier: expected %d calls; got: %d", want, have)
 	}
 }
+
+func TestRetryBudget(t *testing.T) {
+	b := NewRetryBudget(0.5, 0)
+	if b.TryWithdraw() {
+		t.Fatal("expected empty budget")
+	}
+	b.Deposit()
+	if b.TryWithdraw() {
+		t.Fatal("expected budget to need two deposits for a retry")
+	}
+	b.Deposit()
+	if !b.TryWithdraw() {
+		t.Fatal("expected budget to allow a retry")
+	}
+	if b.TryWithdraw() {
+		t.Fatal("expected budget to be exhausted")
+	}
+
+	// Maximum tokens
+	b.MaxTokens(2)
+	for i := 0; i < 10; i++ {
+		b.Deposit()
+	}
+	if want, have := 2, b.Available(); want != have {
+		t.Fatalf("expected %d; got: %d", want, have)
+	}
+}
+
+func TestRetryBudgetMinRetriesPerSecond(t *testing.T) {
+	b := NewRetryBudget(0, 100)
+	for i := 0; i < 100; i++ {
+		if !b.TryWithdraw() {
+			t.Fatalf("#%d: expected budget to allow a retry", i)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !b.TryWithdraw() {
+		t.Fatal("expected budget to be refilled over time")
+	}
+}
+
+func TestBudgetRetrierOnPerformRequest(t *testing.T) {
+	var numReqs int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&numReqs, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	budget := NewRetryBudget(1, 0)
+	for i := 0; i < 4; i++ {
+		budget.Deposit()
+	}
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetryStatusCodes(http.StatusTooManyRequests),
+		SetRetrier(NewBudgetRetrier(budget, NewBackoffRetrier(ZeroBackoff{}))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// First request uses up the budget with 4 retries; second request isn't retried
+	for i := 0; i < 2; i++ {
+		_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if want, have := int64(6), atomic.LoadInt64(&numReqs); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+	if want, have := 0, budget.Available(); want != have {
+		t.Fatalf("expected %d; got: %d", want, have)
+	}
+}
+
+func TestBudgetRetrierDepositsOnSuccess(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	budget := NewRetryBudget(0.5, 0)
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetrier(NewBudgetRetrier(budget, NewBackoffRetrier(ZeroBackoff{}))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := 2, budget.Available(); want != have {
+		t.Fatalf("expected %d; got: %d", want, have)
+	}
+}
+
+func TestClientRetryBudget(t *testing.T) {
+	var numReqs int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/ok" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		atomic.AddInt64(&numReqs, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	budget := NewRetryBudget(1, 0)
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetryStatusCodes(http.StatusTooManyRequests),
+		SetRetryBudget(budget))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Successful requests deposit into the budget of the client
+	for i := 0; i < 3; i++ {
+		_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/ok"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := 3, budget.Available(); want != have {
+		t.Fatalf("expected %d; got: %d", want, have)
+	}
+
+	// The budget applies to the retrier of the request, too
+	for i := 0; i < 2; i++ {
+		_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+			Method:  "GET",
+			Path:    "/",
+			Retrier: NewBackoffRetrier(ZeroBackoff{}),
+		})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if want, have := int64(5), atomic.LoadInt64(&numReqs); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+	if want, have := 0, budget.Available(); want != have {
+		t.Fatalf("expected %d; got: %d", want, have)
+	}
+}
+
+func TestClientRetryBudgetWithBudgetRetrier(t *testing.T) {
+	var numReqs int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&numReqs, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	// The same budget in the client and the retrier is charged only once
+	budget := NewRetryBudget(1, 0)
+	budget.Deposit()
+	budget.Deposit()
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetryStatusCodes(http.StatusTooManyRequests),
+		SetRetrier(NewRetryAfterRetrier(NewBudgetRetrier(budget, NewBackoffRetrier(ZeroBackoff{})))),
+		SetRetryBudget(budget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if want, have := int64(3), atomic.LoadInt64(&numReqs); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		Value    string
+		Expected time.Duration
+		OK       bool
+	}{
+		{"", 0, false},
+		{"abc", 0, false},
+		{"-1", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{"Wed, 01 Jan 2020 12:00:30 GMT", 30 * time.Second, true},
+		{"Wed, 01 Jan 2020 11:00:00 GMT", 0, true},
+	}
+	for _, test := range tests {
+		d, ok := parseRetryAfter(test.Value, now)
+		if want, have := test.OK, ok; want != have {
+			t.Errorf("%q: expected ok=%v; got: %v", test.Value, want, have)
+		}
+		if want, have := test.Expected, d; want != have {
+			t.Errorf("%q: expected %v; got: %v", test.Value, want, have)
+		}
+	}
+}
+
+func TestRetryAfterRetrier(t *testing.T) {
+	r := NewRetryAfterRetrier(NewBackoffRetrier(NewConstantBackoff(10 * time.Millisecond)))
+
+	// No Retry-After header
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	wait, ok, err := r.Retry(context.Background(), 1, nil, resp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected retry")
+	}
+	if want, have := 10*time.Millisecond, wait; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+
+	// Retry-After header
+	resp.Header.Set("Retry-After", "2")
+	wait, ok, err = r.Retry(context.Background(), 1, nil, resp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected retry")
+	}
+	if want, have := 2*time.Second, wait; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+
+	// Retry-After header is ignored for other status codes
+	resp.StatusCode = http.StatusInternalServerError
+	wait, _, _ = r.Retry(context.Background(), 1, nil, resp, nil)
+	if want, have := 10*time.Millisecond, wait; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+
+	// Don't retry if the server asks to wait too long
+	resp.StatusCode = http.StatusServiceUnavailable
+	r.MaxWait(time.Second)
+	_, ok, err = r.Retry(context.Background(), 1, nil, resp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("expected no retry")
+	}
+
+	// The maximum wait is limited by default
+	resp.Header.Set("Retry-After", "3600")
+	_, ok, _ = NewRetryAfterRetrier(NewBackoffRetrier(ZeroBackoff{})).Retry(context.Background(), 1, nil, resp, nil)
+	if ok {
+		t.Fatal("expected no retry by default")
+	}
+	wait, ok, _ = NewRetryAfterRetrier(NewBackoffRetrier(ZeroBackoff{})).MaxWait(0).Retry(context.Background(), 1, nil, resp, nil)
+	if !ok {
+		t.Fatal("expected retry without maximum wait")
+	}
+	if want, have := time.Hour, wait; want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+}
+
+func TestPerformRequestStopsWaitingForRetryWhenContextIsDone(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetryStatusCodes(http.StatusTooManyRequests),
+		SetRetrier(NewRetryAfterRetrier(NewBackoffRetrier(ZeroBackoff{})).MaxWait(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.PerformRequest(ctx, PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected %v; got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected to stop waiting when the context is done; took %v", elapsed)
+	}
+}
@@ -401,28 +401,13 @@ func (s *ScrollService) Clear(ctx context.Context) error {
 
 // first takes the first page of search results.
 func (s *ScrollService) first(ctx context.Context) (*SearchResult, error) {
-	// Get URL and parameters for request
-	path, params, err := s.buildFirstURL()
-	if err != nil {
-		return nil, err
-	}
-
-	// Get HTTP request body
-	body, err := s.bodyFirst()
+	opt, err := s.firstRequestOptions()
 	if err != nil {
 		return nil, err
 	}
 
 	// Get HTTP response
-	res, err := s.client.PerformRequest(ctx, PerformRequestOptions{
-		Method:          "POST",
-		Path:            path,
-		Params:          params,
-		Body:            body,
-		Retrier:         s.retrier,
-		Headers:         s.headers,
-		MaxResponseSize: s.maxResponseSize,
-	})
+	res, err := s.client.PerformRequest(ctx, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -432,6 +417,7 @@ func (s *ScrollService) first(ctx context.Context) (*SearchResult, error) {
 	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
+	ret.Warnings = res.Warnings
 	s.mu.Lock()
 	s.scrollId = ret.ScrollId
 	s.mu.Unlock()
@@ -441,6 +427,31 @@ func (s *ScrollService) first(ctx context.Context) (*SearchResult, error) {
 	return ret, nil
 }
 
+// firstRequestOptions returns the options to fetch the first page, as
+// used by first and DoStream.
+func (s *ScrollService) firstRequestOptions() (PerformRequestOptions, error) {
+	// Get URL and parameters for request
+	path, params, err := s.buildFirstURL()
+	if err != nil {
+		return PerformRequestOptions{}, err
+	}
+
+	// Get HTTP request body
+	body, err := s.bodyFirst()
+	if err != nil {
+		return PerformRequestOptions{}, err
+	}
+	return PerformRequestOptions{
+		Method:          "POST",
+		Path:            path,
+		Params:          params,
+		Body:            body,
+		Retrier:         s.retrier,
+		Headers:         s.headers,
+		MaxResponseSize: s.maxResponseSize,
+	}, nil
+}
+
 // buildFirstURL builds the URL for retrieving the first page.
 func (s *ScrollService) buildFirstURL() (string, url.Values, error) {
 	// Build URL
@@ -549,28 +560,13 @@ func (s *ScrollService) bodyFirst() (interface{}, error) {
 // -- Next --
 
 func (s *ScrollService) next(ctx context.Context) (*SearchResult, error) {
-	// Get URL for request
-	path, params, err := s.buildNextURL()
-	if err != nil {
-		return nil, err
-	}
-
-	// Setup HTTP request body
-	body, err := s.bodyNext()
+	opt, err := s.nextRequestOptions()
 	if err != nil {
 		return nil, err
 	}
 
 	// Get HTTP response
-	res, err := s.client.PerformRequest(ctx, PerformRequestOptions{
-		Method:          "POST",
-		Path:            path,
-		Params:          params,
-		Body:            body,
-		Retrier:         s.retrier,
-		Headers:         s.headers,
-		MaxResponseSize: s.maxResponseSize,
-	})
+	res, err := s.client.PerformRequest(ctx, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -580,6 +576,7 @@ func (s *ScrollService) next(ctx context.Context) (*SearchResult, error) {
 	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
+	ret.Warnings = res.Warnings
 	s.mu.Lock()
 	s.scrollId = ret.ScrollId
 	s.mu.Unlock()
@@ -589,6 +586,31 @@ func (s *ScrollService) next(ctx context.Context) (*SearchResult, error) {
 	return ret, nil
 }
 
+// nextRequestOptions returns the options to fetch the next page, as
+// used by next and DoStream.
+func (s *ScrollService) nextRequestOptions() (PerformRequestOptions, error) {
+	// Get URL for request
+	path, params, err := s.buildNextURL()
+	if err != nil {
+		return PerformRequestOptions{}, err
+	}
+
+	// Setup HTTP request body
+	body, err := s.bodyNext()
+	if err != nil {
+		return PerformRequestOptions{}, err
+	}
+	return PerformRequestOptions{
+		Method:          "POST",
+		Path:            path,
+		Params:          params,
+		Body:            body,
+		Retrier:         s.retrier,
+		Headers:         s.headers,
+		MaxResponseSize: s.maxResponseSize,
+	}, nil
+}
+
 // buildNextURL builds the URL for the operation.
 func (s *ScrollService) buildNextURL() (string, url.Values, error) {
 	path := "/_search/scroll"
@@ -50,6 +50,7 @@ type SearchService struct {
 
 	ccsMinimizeRoundtrips *bool // ccs_minimize_roundtrips
 
+	hedge HedgePolicy
 }
 
 // NewSearchService creates a new service for searching in Elasticsearch.
@@ -444,6 +445,14 @@ func (s *SearchService) MaxResponseSize(maxResponseSize int64) *SearchService {
 	return s
 }
 
+// Hedge enables hedged requests: If no response arrives in time, a
+// duplicate request is sent to a different node, and the first response
+// wins (see HedgePolicy).
+func (s *SearchService) Hedge(policy HedgePolicy) *SearchService {
+	s.hedge = policy
+	return s
+}
+
 // AllowPartialSearchResults indicates if an error should be returned if
 // there is a partial search failure or timeout.
 func (s *SearchService) AllowPartialSearchResults(enabled bool) *SearchService {
@@ -613,38 +622,51 @@ func (s *SearchService) Validate() error {
 	return nil
 }
 
-// Do executes the search and returns a SearchResult.
-func (s *SearchService) Do(ctx context.Context) (*SearchResult, error) {
+// requestOptions validates the search and returns the options to
+// perform it, as used by Do and DoStream.
+func (s *SearchService) requestOptions() (PerformRequestOptions, error) {
 	// Check pre-conditions
 	if err := s.Validate(); err != nil {
-		return nil, err
+		return PerformRequestOptions{}, err
 	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
 	if err != nil {
-		return nil, err
+		return PerformRequestOptions{}, err
 	}
 
-	// Perform request
+	// Get body of request
 	var body interface{}
 	if s.source != nil {
 		body = s.source
 	} else {
 		src, err := s.searchSource.Source()
 		if err != nil {
-			return nil, err
+			return PerformRequestOptions{}, err
 		}
 		body = src
 	}
-	res, err := s.client.PerformRequest(ctx, PerformRequestOptions{
+	return PerformRequestOptions{
 		Method:          "POST",
 		Path:            path,
 		Params:          params,
 		Body:            body,
 		Headers:         s.headers,
 		MaxResponseSize: s.maxResponseSize,
-	})
+	}, nil
+}
+
+// Do executes the search and returns a SearchResult.
+func (s *SearchService) Do(ctx context.Context) (*SearchResult, error) {
+	opt, err := s.requestOptions()
+	if err != nil {
+		return nil, err
+	}
+	opt.Hedge = s.hedge
+
+	// Perform request
+	res, err := s.client.PerformRequest(ctx, opt)
 	if err != nil {
 		return nil, err
 	}
@@ -656,6 +678,7 @@ func (s *SearchService) Do(ctx context.Context) (*SearchResult, error) {
 		return nil, err
 	}
 	ret.Header = res.Header
+	ret.Warnings = res.Warnings
 	return ret, nil
 }
 
@@ -676,6 +699,9 @@ type SearchResult struct {
 	Shards          *ShardsInfo          `json:"_shards,omitempty"`      // shard information
 	Status          int                  `json:"status,omitempty"`       // used in MultiSearch
 	PitId           string               `json:"pit_id,omitempty"`       // Point In Time ID
+
+	// Warnings lists the deprecation warnings returned from Elasticsearch.
+	Warnings []DeprecationWarning `json:"-"`
 }
 
 // SearchResultCluster holds information about a search response
@@ -0,0 +1,215 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// releaseTimeout is the time to wait for releasing server-side resources,
+// e.g. a scroll or point in time, after an operation was cancelled.
+const releaseTimeout = 30 * time.Second
+
+// searchPager fetches the pages of a search.
+type searchPager interface {
+	// Next returns the next page. It returns io.EOF if there are no
+	// more pages.
+	Next(ctx context.Context) (*SearchResult, error)
+	// Close releases server-side resources, e.g. a scroll or point in time.
+	Close(ctx context.Context) error
+}
+
+// SearchIterator iterates over the hits of a search one at a time, and
+// fetches the pages of the search lazily. Use ScrollService.Iterator or
+// SearchService.Iterator to create a SearchIterator.
+//
+// A SearchIterator must be closed to release server-side resources like
+// scroll contexts or points in time, even if not all hits have been read.
+//
+// Example:
+//
+//	it := client.Scroll("tweets").Query(q).Iterator()
+//	defer it.Close(context.Background())
+//	for it.Next(ctx) {
+//		hit := it.Hit()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type SearchIterator struct {
+	pager searchPager
+
+	mu       sync.Mutex
+	page     []*SearchHit
+	pos      int
+	hit      *SearchHit
+	result   *SearchResult
+	err      error
+	done     bool
+	released bool
+}
+
+// newSearchIterator creates a SearchIterator that fetches its pages
+// from pager.
+func newSearchIterator(pager searchPager) *SearchIterator {
+	return &SearchIterator{pager: pager}
+}
+
+// Next advances the iterator to the next hit, fetching the next page if
+// required. It returns false when there are no more hits, when an error
+// occurs, or when ctx is done. Use Err to find out about errors.
+func (it *SearchIterator) Next(ctx context.Context) bool {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	it.hit = nil
+	if it.err != nil || it.released {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	for it.pos >= len(it.page) {
+		if it.done {
+			return false
+		}
+		res, err := it.pager.Next(ctx)
+		if res != nil {
+			it.result = res
+		}
+		if err == io.EOF || (err == nil && (res == nil || res.Hits == nil || len(res.Hits.Hits) == 0)) {
+			it.done = true
+			it.page, it.pos = nil, 0
+			// Release server-side resources as early as possible
+			it.err = it.release(ctx)
+			return false
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.pos = res.Hits.Hits, 0
+	}
+	it.hit = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+// Hit returns the current hit. It is nil if Next has not been called
+// or returned false.
+func (it *SearchIterator) Hit() *SearchHit {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.hit
+}
+
+// Result returns the most recently fetched page of results, e.g. to
+// inspect the total number of hits or the aggregations. It is nil before
+// the first call to Next.
+func (it *SearchIterator) Result() *SearchResult {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.result
+}
+
+// Err returns the first error that occurred during iteration, if any.
+// It returns ctx.Err() if iteration stopped because the context passed
+// to Next was done.
+func (it *SearchIterator) Err() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.err
+}
+
+// Close stops the iteration and releases server-side resources, e.g. the
+// scroll context or point in time. It is safe to call Close more than once.
+//
+// Close releases resources even if ctx is already done, e.g. when the
+// iteration was cancelled. In that case, it waits at most 30 seconds.
+func (it *SearchIterator) Close(ctx context.Context) error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	it.hit = nil
+	it.done = true
+	it.page, it.pos = nil, 0
+	ctx, cancel := releaseContext(ctx)
+	defer cancel()
+	return it.release(ctx)
+}
+
+// release releases server-side resources. If it fails, it is tried
+// again on Close.
+func (it *SearchIterator) release(ctx context.Context) error {
+	if it.released {
+		return nil
+	}
+	if err := it.pager.Close(ctx); err != nil {
+		return errors.Wrap(err, "elastic: cannot release search resources")
+	}
+	it.released = true
+	return nil
+}
+
+// releaseContext returns a context to release server-side resources
+// with. If ctx is already done, e.g. because an operation was cancelled,
+// it returns a context with the values of ctx that times out after
+// releaseTimeout instead.
+func releaseContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx.Err() == nil {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(detachedContext{ctx}, releaseTimeout)
+}
+
+// detachedContext is a context that keeps the values of its parent,
+// but is never cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// -- Scroll --
+
+// Iterator returns a SearchIterator over the hits of the scroll. The
+// iterator clears the scroll when it is closed.
+func (s *ScrollService) Iterator() *SearchIterator {
+	return newSearchIterator(&scrollPager{s: s})
+}
+
+// scrollPager fetches pages via the Scroll API.
+type scrollPager struct {
+	s *ScrollService
+}
+
+func (p *scrollPager) Next(ctx context.Context) (*SearchResult, error) {
+	return p.s.Do(ctx)
+}
+
+func (p *scrollPager) Close(ctx context.Context) error {
+	return p.s.Clear(ctx)
+}
+
+// -- Point in time --
+
+// Iterator returns a SearchIterator over all hits of the search. It
+// pages through the results with a PointInTimePaginator, i.e. with a
+// point in time and search_after. Use Size to specify the number of hits
+// per page.
+//
+// The search must be specified by a SearchSource, i.e. not via Source.
+func (s *SearchService) Iterator() *SearchIterator {
+	return newSearchIterator(NewPointInTimePaginator(s))
+}
@@ -0,0 +1,316 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// iteratorTestServer is a fake Elasticsearch cluster with documents
+// 1..total that supports scrolling and points in time.
+type iteratorTestServer struct {
+	*httptest.Server
+	total int
+
+	mu            sync.Mutex
+	opened        []string // indices of opened points in time
+	openParams    []string
+	closedPITs    []string
+	clearedScroll []string
+	searches      []map[string]interface{}
+	searchPaths   []string
+}
+
+func newIteratorTestServer(t *testing.T, total int) *iteratorTestServer {
+	s := &iteratorTestServer{total: total}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := ioutil.ReadAll(r.Body)
+		var req map[string]interface{}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				t.Errorf("invalid request body %q: %v", body, err)
+			}
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/_pit"):
+			s.opened = append(s.opened, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/_pit"))
+			s.openParams = append(s.openParams, r.URL.RawQuery)
+			fmt.Fprint(w, `{"id":"pit-1"}`)
+		case r.Method == "DELETE" && r.URL.Path == "/_pit":
+			s.closedPITs = append(s.closedPITs, fmt.Sprint(req["id"]))
+			fmt.Fprint(w, `{"succeeded":true,"num_freed":1}`)
+		case r.Method == "DELETE" && r.URL.Path == "/_search/scroll":
+			s.clearedScroll = append(s.clearedScroll, fmt.Sprint(req["scroll_id"]))
+			fmt.Fprint(w, `{"succeeded":true,"num_freed":1}`)
+		case r.URL.Path == "/_search/scroll":
+			// Scroll ids are the offset of the next page
+			var from int
+			fmt.Sscanf(fmt.Sprint(req["scroll_id"]), "scroll-%d", &from)
+			s.writePage(w, from, 2, fmt.Sprintf(`"_scroll_id":"scroll-%d"`, from+2))
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			s.searches = append(s.searches, req)
+			s.searchPaths = append(s.searchPaths, r.URL.Path)
+			if r.URL.Query().Get("scroll") != "" {
+				s.writePage(w, 0, 2, `"_scroll_id":"scroll-2"`)
+				return
+			}
+			if pit, ok := req["pit"].(map[string]interface{}); ok && pit["id"] == "missing" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":{"root_cause":[{"type":"search_context_missing_exception","reason":"No search context found"}],"type":"search_phase_execution_exception","reason":"all shards failed"},"status":404}`)
+				return
+			}
+			// search_after contains the last document
+			var from int
+			if after, ok := req["search_after"].([]interface{}); ok && len(after) > 0 {
+				from = int(after[0].(float64))
+			}
+			size := 10
+			if v, ok := req["size"].(float64); ok {
+				size = int(v)
+			}
+			s.writePage(w, from, size, fmt.Sprintf(`"pit_id":"pit-%d"`, len(s.searches)+1))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	return s
+}
+
+// writePage writes the hits from+1..from+size.
+func (s *iteratorTestServer) writePage(w http.ResponseWriter, from, size int, extra string) {
+	var hits []string
+	for i := from + 1; i <= from+size && i <= s.total; i++ {
+		hits = append(hits, fmt.Sprintf(`{"_index":"twitter","_id":"%d","sort":[%d,%d]}`, i, i, i))
+	}
+	fmt.Fprintf(w, `{%s,"hits":{"total":{"value":%d,"relation":"eq"},"hits":[%s]}}`, extra, s.total, strings.Join(hits, ","))
+}
+
+func iterateIds(t *testing.T, ctx context.Context, it *SearchIterator, max int) []string {
+	var ids []string
+	for len(ids) < max && it.Next(ctx) {
+		ids = append(ids, it.Hit().Id)
+	}
+	return ids
+}
+
+func TestScrollIterator(t *testing.T) {
+	ts := newIteratorTestServer(t, 5)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := client.Scroll("twitter").Size(2).Iterator()
+	ids := iterateIds(t, context.Background(), it, 100)
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "1,2,3,4,5", strings.Join(ids, ","); want != have {
+		t.Fatalf("expected ids %s; got: %s", want, have)
+	}
+	if want, have := int64(5), it.Result().TotalHits(); want != have {
+		t.Fatalf("expected TotalHits = %d; got: %d", want, have)
+	}
+	if it.Next(context.Background()) || it.Hit() != nil {
+		t.Fatal("expected no more hits")
+	}
+	// The scroll is released after the last page
+	if want, have := 1, len(ts.clearedScroll); want != have {
+		t.Fatalf("expected %d cleared scrolls; got: %d", want, have)
+	}
+	if err := it.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(ts.clearedScroll); want != have {
+		t.Fatalf("expected %d cleared scrolls; got: %d", want, have)
+	}
+}
+
+func TestScrollIteratorPartialRead(t *testing.T) {
+	ts := newIteratorTestServer(t, 5)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := client.Scroll("twitter").Size(2).Iterator()
+	ids := iterateIds(t, context.Background(), it, 3)
+	if want, have := "1,2,3", strings.Join(ids, ","); want != have {
+		t.Fatalf("expected ids %s; got: %s", want, have)
+	}
+	if err := it.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := it.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "[scroll-4]", strings.Join(ts.clearedScroll, ","); want != have {
+		t.Fatalf("expected cleared scrolls %s; got: %s", want, have)
+	}
+	if it.Next(context.Background()) {
+		t.Fatal("expected no more hits after Close")
+	}
+}
+
+func TestSearchIterator(t *testing.T) {
+	ts := newIteratorTestServer(t, 5)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	search := client.Search("twitter").Routing("user1").Size(2).Sort("created", true)
+	it := search.Iterator()
+	ids := iterateIds(t, context.Background(), it, 100)
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "1,2,3,4,5", strings.Join(ids, ","); want != have {
+		t.Fatalf("expected ids %s; got: %s", want, have)
+	}
+
+	// The point in time is opened on the indices, with routing
+	if want, have := "twitter", strings.Join(ts.opened, ","); want != have {
+		t.Fatalf("expected point in time on %s; got: %s", want, have)
+	}
+	if !strings.Contains(ts.openParams[0], "routing=user1") || !strings.Contains(ts.openParams[0], "keep_alive=5m") {
+		t.Fatalf("expected routing and keep_alive when opening the point in time; got: %s", ts.openParams[0])
+	}
+
+	// Searches use the latest point in time id, search_after and the tiebreaker
+	if want, have := 4, len(ts.searches); want != have {
+		t.Fatalf("expected %d searches; got: %d", want, have)
+	}
+	for i, req := range ts.searches {
+		if want, have := "/_search", ts.searchPaths[i]; want != have {
+			t.Fatalf("#%d: expected search on %s; got: %s", i, want, have)
+		}
+		pit, _ := req["pit"].(map[string]interface{})
+		if want, have := fmt.Sprintf("pit-%d", i+1), pit["id"]; want != have {
+			t.Fatalf("#%d: expected pit id %v; got: %v", i, want, have)
+		}
+		sort, _ := json.Marshal(req["sort"])
+		if want, have := `[{"created":{"order":"asc"}},{"_shard_doc":{"order":"asc"}}]`, string(sort); want != have {
+			t.Fatalf("#%d: expected sort %s; got: %s", i, want, have)
+		}
+		after, _ := json.Marshal(req["search_after"])
+		if i == 0 && string(after) != "null" {
+			t.Fatalf("#%d: expected no search_after; got: %s", i, after)
+		}
+		if i > 0 {
+			last := 2 * i
+			if last > 5 {
+				last = 5
+			}
+			if want, have := fmt.Sprintf("[%d,%d]", last, last), string(after); want != have {
+				t.Fatalf("#%d: expected search_after %s; got: %s", i, want, have)
+			}
+		}
+	}
+
+	// The point in time is closed with its latest id
+	if want, have := "pit-5", strings.Join(ts.closedPITs, ","); want != have {
+		t.Fatalf("expected closed point in time %s; got: %s", want, have)
+	}
+	if err := it.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(ts.closedPITs); want != have {
+		t.Fatalf("expected %d closed points in time; got: %d", want, have)
+	}
+
+	// The original search is left untouched
+	src, err := search.searchSource.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(src)
+	if strings.Contains(string(data), "pit") || strings.Contains(string(data), "_shard_doc") || strings.Contains(string(data), "search_after") {
+		t.Fatalf("expected search to be unchanged; got: %s", data)
+	}
+}
+
+func TestSearchIteratorCancel(t *testing.T) {
+	ts := newIteratorTestServer(t, 5)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it := client.Search("twitter").Size(2).Iterator()
+	ids := iterateIds(t, ctx, it, 1)
+	if want, have := "1", strings.Join(ids, ","); want != have {
+		t.Fatalf("expected ids %s; got: %s", want, have)
+	}
+	cancel()
+	if it.Next(ctx) {
+		t.Fatal("expected Next to stop after cancel")
+	}
+	if want, have := context.Canceled, it.Err(); want != have {
+		t.Fatalf("expected Err = %v; got: %v", want, have)
+	}
+
+	// Close releases the point in time even with a cancelled context
+	if err := it.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "pit-2", strings.Join(ts.closedPITs, ","); want != have {
+		t.Fatalf("expected closed point in time %s; got: %s", want, have)
+	}
+}
+
+func TestSearchIteratorWithPointInTime(t *testing.T) {
+	ts := newIteratorTestServer(t, 3)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A point in time of the caller is used and left open
+	it := client.Search().PointInTime(NewPointInTimeWithKeepAlive("pit-1", "1m")).Sort("_shard_doc", true).Iterator()
+	ids := iterateIds(t, context.Background(), it, 100)
+	if err := it.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "1,2,3", strings.Join(ids, ","); want != have {
+		t.Fatalf("expected ids %s; got: %s", want, have)
+	}
+	if len(ts.opened) != 0 || len(ts.closedPITs) != 0 {
+		t.Fatalf("expected the point in time to be left alone; got: opened %v, closed %v", ts.opened, ts.closedPITs)
+	}
+	sort, _ := json.Marshal(ts.searches[0]["sort"])
+	if want, have := `[{"_shard_doc":{"order":"asc"}}]`, string(sort); want != have {
+		t.Fatalf("expected sort %s; got: %s", want, have)
+	}
+	pit, _ := ts.searches[0]["pit"].(map[string]interface{})
+	if want, have := "1m", pit["keep_alive"]; want != have {
+		t.Fatalf("expected keep_alive %v; got: %v", want, have)
+	}
+
+	// Source is not supported
+	it = client.Search("twitter").Source(map[string]interface{}{}).Iterator()
+	if it.Next(context.Background()) || it.Err() == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// SearchHitFunc is called for each hit when streaming search results
+// (see SearchService.DoStream and ScrollService.DoStream). Returning an
+// error stops reading the response, and the error is returned to the caller.
+type SearchHitFunc func(hit *SearchHit) error
+
+// DoStream executes the search like Do, but decodes the response while it
+// is read from the network and passes the hits to fn one at a time. This
+// avoids keeping large result pages in memory.
+//
+// The returned SearchResult contains everything but the hits, i.e.
+// Hits.Hits is always empty. MaxResponseSize is enforced while reading
+// the response.
+func (s *SearchService) DoStream(ctx context.Context, fn SearchHitFunc) (*SearchResult, error) {
+	opt, err := s.requestOptions()
+	if err != nil {
+		return nil, err
+	}
+	opt.Stream = true
+
+	// Perform request
+	res, err := s.client.PerformRequest(ctx, opt)
+	if res != nil && res.BodyReader != nil {
+		defer res.BodyReader.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Return search results
+	ret, _, err := decodeSearchResultStream(s.client.decoder, res.BodyReader, fn, nil)
+	if err != nil {
+		return nil, err
+	}
+	ret.Header = res.Header
+	ret.Warnings = res.Warnings
+	return ret, nil
+}
+
+// DoStream fetches the next page of results like Do, but decodes the
+// response while it is read from the network and passes the hits to fn
+// one at a time. Like Do, it returns io.EOF if there are no more hits.
+//
+// The returned SearchResult contains everything but the hits, i.e.
+// Hits.Hits is always empty. MaxResponseSize is enforced while reading
+// the response.
+func (s *ScrollService) DoStream(ctx context.Context, fn SearchHitFunc) (*SearchResult, error) {
+	s.mu.RLock()
+	nextScrollId := s.scrollId
+	s.mu.RUnlock()
+
+	var (
+		opt PerformRequestOptions
+		err error
+	)
+	if len(nextScrollId) == 0 {
+		opt, err = s.firstRequestOptions()
+	} else {
+		opt, err = s.nextRequestOptions()
+	}
+	if err != nil {
+		return nil, err
+	}
+	opt.Stream = true
+
+	// Get HTTP response
+	res, err := s.client.PerformRequest(ctx, opt)
+	if res != nil && res.BodyReader != nil {
+		defer res.BodyReader.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the scroll id as soon as it is read, so that the scroll
+	// can be cleared even if fn or decoding the rest of the page fails
+	setScrollId := func(scrollId string) {
+		s.mu.Lock()
+		s.scrollId = scrollId
+		s.mu.Unlock()
+	}
+
+	// Return operation response
+	ret, n, err := decodeSearchResultStream(s.client.decoder, res.BodyReader, fn, setScrollId)
+	if err != nil {
+		return nil, err
+	}
+	ret.Header = res.Header
+	ret.Warnings = res.Warnings
+	setScrollId(ret.ScrollId)
+	if n == 0 {
+		return ret, io.EOF
+	}
+	return ret, nil
+}
+
+// decodeSearchResultStream decodes a search response from r, passing
+// each hit to fn. It returns the search result without hits and the
+// number of hits. If scrollId is not nil, it is called with the scroll
+// id of the response as soon as it has been read.
+func decodeSearchResultStream(dec Decoder, r io.Reader, fn SearchHitFunc, scrollId func(string)) (*SearchResult, int, error) {
+	d := newTokenDecoder(dec, r)
+
+	// Everything but the hits is collected and decoded at the end
+	fields := make(map[string]json.RawMessage)
+	var hitsFields map[string]json.RawMessage
+	var n int
+
+	ok, err := expectDelim(d, '{')
+	if err != nil {
+		return nil, 0, err
+	}
+	if !ok {
+		return nil, 0, errors.New("elastic: expected search result; got: null")
+	}
+	for d.More() {
+		key, err := readKey(d)
+		if err != nil {
+			return nil, 0, err
+		}
+		if key != "hits" {
+			var raw json.RawMessage
+			if err := d.Decode(&raw); err != nil {
+				return nil, 0, err
+			}
+			fields[key] = raw
+			if key == "_scroll_id" && scrollId != nil {
+				var id string
+				if err := json.Unmarshal(raw, &id); err == nil && id != "" {
+					scrollId(id)
+				}
+			}
+			continue
+		}
+
+		ok, err := expectDelim(d, '{')
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok {
+			continue // "hits": null
+		}
+		hitsFields = make(map[string]json.RawMessage)
+		for d.More() {
+			key, err := readKey(d)
+			if err != nil {
+				return nil, 0, err
+			}
+			if key != "hits" {
+				var raw json.RawMessage
+				if err := d.Decode(&raw); err != nil {
+					return nil, 0, err
+				}
+				hitsFields[key] = raw
+				continue
+			}
+
+			ok, err := expectDelim(d, '[')
+			if err != nil {
+				return nil, 0, err
+			}
+			if !ok {
+				continue // "hits": null
+			}
+			for d.More() {
+				hit := new(SearchHit)
+				if err := d.Decode(hit); err != nil {
+					return nil, 0, err
+				}
+				n++
+				if err := fn(hit); err != nil {
+					return nil, 0, err
+				}
+			}
+			if _, err := d.Token(); err != nil { // ]
+				return nil, 0, err
+			}
+		}
+		if _, err := d.Token(); err != nil { // }
+			return nil, 0, err
+		}
+	}
+	if _, err := d.Token(); err != nil { // }
+		return nil, 0, err
+	}
+
+	ret := new(SearchResult)
+	if err := decodeFields(dec, fields, ret); err != nil {
+		return nil, 0, err
+	}
+	if hitsFields != nil {
+		ret.Hits = new(SearchHits)
+		if err := decodeFields(dec, hitsFields, ret.Hits); err != nil {
+			return nil, 0, err
+		}
+	}
+	return ret, n, nil
+}
+
+// expectDelim reads the next token. It returns true if it is the given
+// delimiter, and false if it is null.
+func expectDelim(d TokenDecoder, delim json.Delim) (bool, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return false, err
+	}
+	if tok == nil {
+		return false, nil
+	}
+	if v, ok := tok.(json.Delim); !ok || v != delim {
+		return false, fmt.Errorf("elastic: expected %v in search result; got: %v", delim, tok)
+	}
+	return true, nil
+}
+
+// readKey reads the key of an object.
+func readKey(d TokenDecoder) (string, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("elastic: expected key in search result; got: %v", tok)
+	}
+	return key, nil
+}
+
+// decodeFields decodes the collected fields of an object into v.
+func decodeFields(dec Decoder, fields map[string]json.RawMessage, v interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return dec.Decode(data, v)
+}
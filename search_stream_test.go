@@ -0,0 +1,300 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const streamTestSearchResult = `{
+	"_scroll_id": "scroll-1",
+	"took": 3,
+	"timed_out": false,
+	"hits": {
+		"total": {"value": 3, "relation": "eq"},
+		"max_score": 1.5,
+		"hits": [
+			{"_index": "twitter", "_id": "1", "_score": 1.5, "_source": {"user": "olivere"}},
+			{"_index": "twitter", "_id": "2", "_score": 1.0, "_source": {"user": "sandrae"}},
+			{"_index": "twitter", "_id": "3", "_score": 0.5, "_source": {"user": "olivere"}}
+		]
+	},
+	"aggregations": {
+		"users": {"buckets": [{"key": "olivere", "doc_count": 2}, {"key": "sandrae", "doc_count": 1}]}
+	}
+}`
+
+func TestDecodeSearchResultStream(t *testing.T) {
+	decoders := []Decoder{&DefaultDecoder{}, &NumberDecoder{}, &decoder{}}
+	for _, dec := range decoders {
+		var ids []string
+		ret, n, err := decodeSearchResultStream(dec, strings.NewReader(streamTestSearchResult), func(hit *SearchHit) error {
+			ids = append(ids, hit.Id)
+			return nil
+		}, nil)
+		if err != nil {
+			t.Fatalf("%T: %v", dec, err)
+		}
+		if want, have := 3, n; want != have {
+			t.Fatalf("%T: expected %d hits; got: %d", dec, want, have)
+		}
+		if want, have := "1,2,3", strings.Join(ids, ","); want != have {
+			t.Fatalf("%T: expected hits %s; got: %s", dec, want, have)
+		}
+		if want, have := "scroll-1", ret.ScrollId; want != have {
+			t.Fatalf("%T: expected ScrollId = %q; got: %q", dec, want, have)
+		}
+		if want, have := int64(3), ret.TookInMillis; want != have {
+			t.Fatalf("%T: expected TookInMillis = %d; got: %d", dec, want, have)
+		}
+		if ret.Hits == nil {
+			t.Fatalf("%T: expected Hits != nil", dec)
+		}
+		if want, have := int64(3), ret.TotalHits(); want != have {
+			t.Fatalf("%T: expected TotalHits = %d; got: %d", dec, want, have)
+		}
+		if ret.Hits.MaxScore == nil || *ret.Hits.MaxScore != 1.5 {
+			t.Fatalf("%T: expected MaxScore = 1.5; got: %v", dec, ret.Hits.MaxScore)
+		}
+		if len(ret.Hits.Hits) != 0 {
+			t.Fatalf("%T: expected no hits in result; got: %d", dec, len(ret.Hits.Hits))
+		}
+		agg, found := ret.Aggregations.Terms("users")
+		if !found || len(agg.Buckets) != 2 {
+			t.Fatalf("%T: expected aggregation with 2 buckets; got: %+v", dec, agg)
+		}
+	}
+}
+
+func TestDecodeSearchResultStreamWithoutHits(t *testing.T) {
+	for _, body := range []string{`{"took":1}`, `{"took":1,"hits":null}`, `{"took":1,"hits":{"total":{"value":0,"relation":"eq"},"hits":[]}}`} {
+		ret, n, err := decodeSearchResultStream(&DefaultDecoder{}, strings.NewReader(body), func(hit *SearchHit) error {
+			t.Fatalf("expected no hits; got: %v", hit.Id)
+			return nil
+		}, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		if n != 0 || ret.TookInMillis != 1 {
+			t.Fatalf("%s: expected no hits and TookInMillis = 1; got: %d, %d", body, n, ret.TookInMillis)
+		}
+	}
+	for _, body := range []string{``, `null`, `[]`, `{"hits":{"hits":[{"_id":"1"}`} {
+		_, _, err := decodeSearchResultStream(&DefaultDecoder{}, strings.NewReader(body), func(hit *SearchHit) error {
+			return nil
+		}, nil)
+		if err == nil {
+			t.Fatalf("%q: expected error", body)
+		}
+	}
+}
+
+func TestSearchDoStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Warning", `299 Elasticsearch-7.17.0 "deprecated"`)
+		fmt.Fprint(w, streamTestSearchResult)
+	}))
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users []string
+	ret, err := client.Search("twitter").DoStream(context.Background(), func(hit *SearchHit) error {
+		var doc struct {
+			User string `json:"user"`
+		}
+		if err := json.Unmarshal(hit.Source, &doc); err != nil {
+			return err
+		}
+		users = append(users, doc.User)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "olivere,sandrae,olivere", strings.Join(users, ","); want != have {
+		t.Fatalf("expected users %s; got: %s", want, have)
+	}
+	if want, have := int64(3), ret.TotalHits(); want != have {
+		t.Fatalf("expected TotalHits = %d; got: %d", want, have)
+	}
+	if want, have := 1, len(ret.Warnings); want != have {
+		t.Fatalf("expected %d warnings; got: %d", want, have)
+	}
+	if ret.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("expected Header to be set; got: %v", ret.Header)
+	}
+
+	// Stop after the first hit
+	errStop := errors.New("stop")
+	var count int
+	_, err = client.Search("twitter").DoStream(context.Background(), func(hit *SearchHit) error {
+		count++
+		return errStop
+	})
+	if err != errStop {
+		t.Fatalf("expected %v; got: %v", errStop, err)
+	}
+	if want, have := 1, count; want != have {
+		t.Fatalf("expected %d calls; got: %d", want, have)
+	}
+}
+
+func TestSearchDoStreamWithMaxResponseSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// Flush to send the response without Content-Length
+		fmt.Fprint(w, streamTestSearchResult[:10])
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, streamTestSearchResult[10:])
+	}))
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	_, err = client.Search("twitter").MaxResponseSize(200).DoStream(context.Background(), func(hit *SearchHit) error {
+		count++
+		return nil
+	})
+	if !errors.Is(err, ErrResponseSize) {
+		t.Fatalf("expected %v; got: %v", ErrResponseSize, err)
+	}
+	if count > 1 {
+		t.Fatalf("expected to stop reading at 200 bytes; got %d hits", count)
+	}
+
+	_, err = client.Search("twitter").MaxResponseSize(int64(len(streamTestSearchResult))).DoStream(context.Background(), func(hit *SearchHit) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error at exactly MaxResponseSize; got: %v", err)
+	}
+}
+
+func TestScrollDoStream(t *testing.T) {
+	var page int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		page++
+		switch page {
+		case 1:
+			if r.URL.Path != "/twitter/_search" {
+				t.Errorf("expected first page from /twitter/_search; got: %s", r.URL.Path)
+			}
+			fmt.Fprint(w, streamTestSearchResult)
+		default:
+			if r.URL.Path != "/_search/scroll" {
+				t.Errorf("expected next page from /_search/scroll; got: %s", r.URL.Path)
+			}
+			fmt.Fprint(w, `{"_scroll_id":"scroll-2","hits":{"total":{"value":3,"relation":"eq"},"hits":[]}}`)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := client.Scroll("twitter").Size(3)
+	var count int
+	for {
+		_, err := svc.DoStream(context.Background(), func(hit *SearchHit) error {
+			count++
+			return nil
+		})
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := 3, count; want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+	if want, have := 2, page; want != have {
+		t.Fatalf("expected %d pages; got: %d", want, have)
+	}
+	if want, have := "scroll-2", svc.scrollId; want != have {
+		t.Fatalf("expected scroll id %q; got: %q", want, have)
+	}
+}
+
+func TestScrollDoStreamKeepsScrollIdOnError(t *testing.T) {
+	var cleared []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "DELETE" {
+			var body struct {
+				ScrollId []string `json:"scroll_id"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("invalid request body: %v", err)
+			}
+			cleared = append(cleared, body.ScrollId...)
+			fmt.Fprint(w, `{"succeeded":true,"num_freed":1}`)
+			return
+		}
+		fmt.Fprint(w, streamTestSearchResult)
+	}))
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := client.Scroll("twitter").Size(3)
+	errStop := errors.New("stop")
+	_, err = svc.DoStream(context.Background(), func(hit *SearchHit) error {
+		return errStop
+	})
+	if err != errStop {
+		t.Fatalf("expected %v; got: %v", errStop, err)
+	}
+
+	// The scroll can still be cleared
+	if err := svc.Clear(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "scroll-1", strings.Join(cleared, ","); want != have {
+		t.Fatalf("expected cleared scroll %q; got: %q", want, have)
+	}
+}
+
+func TestSearchDoStreamWithContentLengthExceedingMaxResponseSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", fmt.Sprint(len(streamTestSearchResult)))
+		fmt.Fprint(w, streamTestSearchResult)
+	}))
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Search("twitter").MaxResponseSize(200).DoStream(context.Background(), func(hit *SearchHit) error {
+		t.Fatal("expected no hits")
+		return nil
+	})
+	if !errors.Is(err, ErrResponseSize) {
+		t.Fatalf("expected %v; got: %v", ErrResponseSize, err)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoConnection is returned by a Selector if it cannot pick a
+	// connection from the given list.
+	ErrNoConnection = errors.New("elastic: no connection to select from")
+)
+
+// SelectorFunc specifies the signature of a Select function, and is an
+// adapter to allow the use of ordinary functions as a Selector. If f is
+// a function with the appropriate signature, SelectorFunc(f) is a
+// Selector that calls f.
+type SelectorFunc func([]Connection) (Connection, error)
+
+// Select calls f.
+func (f SelectorFunc) Select(conns []Connection) (Connection, error) {
+	return f(conns)
+}
+
+// Selector picks the connection to use for the next request to
+// Elasticsearch. Use SetConnectionSelector to specify the Selector for
+// a Client. By default, the Client uses a RoundRobinSelector.
+type Selector interface {
+	// Select returns one of the given connections. The client only passes
+	// connections that are not marked as dead, and it never passes an empty
+	// list. The Connection returned must be one of the given connections.
+	Select(conns []Connection) (Connection, error)
+}
+
+// -- RoundRobinSelector --
+
+// RoundRobinSelector returns the connections in turn.
+type RoundRobinSelector struct {
+	mu    sync.Mutex
+	index int
+}
+
+// NewRoundRobinSelector returns a new RoundRobinSelector.
+func NewRoundRobinSelector() *RoundRobinSelector {
+	return &RoundRobinSelector{index: -1}
+}
+
+// Select returns the connection following the one that has been returned
+// in the previous call.
+func (s *RoundRobinSelector) Select(conns []Connection) (Connection, error) {
+	if len(conns) == 0 {
+		return nil, ErrNoConnection
+	}
+	s.mu.Lock()
+	s.index++
+	if s.index >= len(conns) {
+		s.index = 0
+	}
+	conn := conns[s.index]
+	s.mu.Unlock()
+	return conn, nil
+}
+
+// -- RandomSelector --
+
+// RandomSelector returns a random connection.
+type RandomSelector struct{}
+
+// NewRandomSelector returns a new RandomSelector.
+func NewRandomSelector() *RandomSelector {
+	return &RandomSelector{}
+}
+
+// Select returns a random connection.
+func (s *RandomSelector) Select(conns []Connection) (Connection, error) {
+	if len(conns) == 0 {
+		return nil, ErrNoConnection
+	}
+	return conns[rand.Intn(len(conns))], nil
+}
+
+// -- LeastInFlightSelector --
+
+// LeastInFlightSelector returns the connection with the lowest number of
+// requests currently in flight. Ties are broken in a round-robin fashion.
+type LeastInFlightSelector struct {
+	mu     sync.Mutex
+	offset int
+}
+
+// NewLeastInFlightSelector returns a new LeastInFlightSelector.
+func NewLeastInFlightSelector() *LeastInFlightSelector {
+	return &LeastInFlightSelector{}
+}
+
+// Select returns the connection with the fewest requests in flight.
+func (s *LeastInFlightSelector) Select(conns []Connection) (Connection, error) {
+	if len(conns) == 0 {
+		return nil, ErrNoConnection
+	}
+	s.mu.Lock()
+	offset := s.offset % len(conns)
+	s.offset = offset + 1
+	s.mu.Unlock()
+
+	var best Connection
+	bestInFlight := -1
+	for i := 0; i < len(conns); i++ {
+		conn := conns[(offset+i)%len(conns)]
+		if n := conn.InFlight(); bestInFlight < 0 || n < bestInFlight {
+			best, bestInFlight = conn, n
+		}
+	}
+	return best, nil
+}
+
+// -- LatencySelector --
+
+// LatencySelector picks a connection at random, weighted by the inverse
+// of the moving average of its latency multiplied by the number of
+// requests in flight (plus one). Fast and idle nodes therefore receive
+// more traffic than slow or busy nodes, while slow nodes still get
+// enough requests to notice when they recover.
+//
+// Connections that have not been used yet are given the weight of the
+// fastest connection, so that new nodes are probed quickly.
+type LatencySelector struct {
+	minLatency time.Duration
+}
+
+// NewLatencySelector returns a new LatencySelector.
+func NewLatencySelector() *LatencySelector {
+	return &LatencySelector{minLatency: time.Millisecond}
+}
+
+// MinLatency sets the lower bound for the latency of a connection
+// when computing its weight (1ms by default). It prevents a single
+// very fast node from receiving all requests.
+func (s *LatencySelector) MinLatency(minLatency time.Duration) *LatencySelector {
+	if minLatency > 0 {
+		s.minLatency = minLatency
+	}
+	return s
+}
+
+// Select returns a connection, weighted by latency and requests in flight.
+func (s *LatencySelector) Select(conns []Connection) (Connection, error) {
+	if len(conns) == 0 {
+		return nil, ErrNoConnection
+	}
+	if len(conns) == 1 {
+		return conns[0], nil
+	}
+
+	weights := make([]float64, len(conns))
+	var maxWeight float64
+	for i, conn := range conns {
+		latency := conn.Latency()
+		if latency <= 0 {
+			continue // no samples yet; see below
+		}
+		if latency < s.minLatency {
+			latency = s.minLatency
+		}
+		weights[i] = 1 / (float64(latency) * float64(conn.InFlight()+1))
+		if weights[i] > maxWeight {
+			maxWeight = weights[i]
+		}
+	}
+	if maxWeight == 0 {
+		maxWeight = 1
+	}
+
+	var total float64
+	for i, conn := range conns {
+		if weights[i] == 0 {
+			weights[i] = maxWeight / float64(conn.InFlight()+1)
+		}
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		r -= w
+		if r < 0 {
+			return conns[i], nil
+		}
+	}
+	return conns[len(conns)-1], nil
+}
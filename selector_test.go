@@ -0,0 +1,191 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"testing"
+	"time"
+)
+
+func testSelectorConns(urls ...string) []Connection {
+	conns := make([]Connection, len(urls))
+	for i, url := range urls {
+		conns[i] = newConn(url, url)
+	}
+	return conns
+}
+
+func TestRoundRobinSelector(t *testing.T) {
+	conns := testSelectorConns("http://127.0.0.1:9200", "http://127.0.0.1:9201", "http://127.0.0.1:9202")
+	s := NewRoundRobinSelector()
+	for i := 0; i < 7; i++ {
+		conn, err := s.Select(conns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := conns[i%len(conns)], conn; want != have {
+			t.Fatalf("#%d: expected %s; got: %s", i, want.URL(), have.URL())
+		}
+	}
+}
+
+func TestRoundRobinSelectorWithShrinkingConns(t *testing.T) {
+	conns := testSelectorConns("http://127.0.0.1:9200", "http://127.0.0.1:9201", "http://127.0.0.1:9202")
+	s := NewRoundRobinSelector()
+	for i := 0; i < 3; i++ {
+		if _, err := s.Select(conns); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn, err := s.Select(conns[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := conns[0], conn; want != have {
+		t.Fatalf("expected %s; got: %s", want.URL(), have.URL())
+	}
+}
+
+func TestRandomSelector(t *testing.T) {
+	conns := testSelectorConns("http://127.0.0.1:9200", "http://127.0.0.1:9201")
+	s := NewRandomSelector()
+	seen := make(map[Connection]int)
+	for i := 0; i < 100; i++ {
+		conn, err := s.Select(conns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[conn]++
+	}
+	if want, have := 2, len(seen); want != have {
+		t.Fatalf("expected %d different connections; got: %d", want, have)
+	}
+}
+
+func TestLeastInFlightSelector(t *testing.T) {
+	conns := testSelectorConns("http://127.0.0.1:9200", "http://127.0.0.1:9201", "http://127.0.0.1:9202")
+	conns[0].(*conn).startRequest()
+	conns[0].(*conn).startRequest()
+	conns[1].(*conn).startRequest()
+	conns[2].(*conn).startRequest()
+	conns[2].(*conn).startRequest()
+
+	s := NewLeastInFlightSelector()
+	for i := 0; i < 5; i++ {
+		conn, err := s.Select(conns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := conns[1], conn; want != have {
+			t.Fatalf("#%d: expected %s; got: %s", i, want.URL(), have.URL())
+		}
+	}
+
+	// Spread requests evenly on ties
+	conns[1].(*conn).startRequest()
+	seen := make(map[Connection]int)
+	for i := 0; i < 3; i++ {
+		conn, err := s.Select(conns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[conn]++
+	}
+	if want, have := 3, len(seen); want != have {
+		t.Fatalf("expected %d different connections; got: %d", want, have)
+	}
+}
+
+func TestLatencySelector(t *testing.T) {
+	conns := testSelectorConns("http://127.0.0.1:9200", "http://127.0.0.1:9201")
+	conns[0].(*conn).finishRequest(10*time.Millisecond, true)
+	conns[1].(*conn).finishRequest(100*time.Millisecond, true)
+
+	s := NewLatencySelector()
+	counts := make(map[Connection]int)
+	for i := 0; i < 1000; i++ {
+		conn, err := s.Select(conns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[conn]++
+	}
+	if counts[conns[0]] <= counts[conns[1]] {
+		t.Fatalf("expected fast connection to be preferred; got: fast=%d slow=%d", counts[conns[0]], counts[conns[1]])
+	}
+	if counts[conns[1]] == 0 {
+		t.Fatal("expected slow connection to be selected at least once")
+	}
+}
+
+func TestLatencySelectorWithoutSamples(t *testing.T) {
+	conns := testSelectorConns("http://127.0.0.1:9200", "http://127.0.0.1:9201")
+	s := NewLatencySelector()
+	seen := make(map[Connection]int)
+	for i := 0; i < 100; i++ {
+		conn, err := s.Select(conns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[conn]++
+	}
+	if want, have := 2, len(seen); want != have {
+		t.Fatalf("expected %d different connections; got: %d", want, have)
+	}
+}
+
+func TestConnLatencyMovingAverage(t *testing.T) {
+	c := newConn("node", "http://127.0.0.1:9200")
+	if want, have := time.Duration(0), c.Latency(); want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+	c.startRequest()
+	if want, have := 1, c.InFlight(); want != have {
+		t.Fatalf("expected %d; got: %d", want, have)
+	}
+	c.finishRequest(100*time.Millisecond, true)
+	if want, have := 0, c.InFlight(); want != have {
+		t.Fatalf("expected %d; got: %d", want, have)
+	}
+	if want, have := 100*time.Millisecond, c.Latency(); want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+	c.finishRequest(200*time.Millisecond, true)
+	if want, have := 130*time.Millisecond, c.Latency(); want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+	c.finishRequest(time.Second, false)
+	if want, have := 130*time.Millisecond, c.Latency(); want != have {
+		t.Fatalf("expected %v; got: %v", want, have)
+	}
+}
+
+func TestClientWithConnectionSelector(t *testing.T) {
+	var calls int
+	selector := SelectorFunc(func(conns []Connection) (Connection, error) {
+		calls++
+		return conns[len(conns)-1], nil
+	})
+	client, err := NewClient(
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetConnectionSelector(selector),
+		SetURL("http://127.0.0.1:9200", "http://127.0.0.1:9201"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.conns[1].MarkAsDead()
+
+	c, err := client.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := client.conns[0].URL(), c.URL(); want != have {
+		t.Fatalf("expected %s; got: %s", want, have)
+	}
+	if want, have := 1, calls; want != have {
+		t.Fatalf("expected %d calls to selector; got: %d", want, have)
+	}
+}
@@ -0,0 +1,616 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SlicedScanHitFunc is called for each hit of a SlicedScanService, along
+// with the id of the slice it belongs to. Returning an error stops the scan,
+// and the error is returned from SlicedScanService.Do.
+type SlicedScanHitFunc func(slice int, hit *SearchHit) error
+
+// SlicedScanHit is a hit of a SlicedScanService (see DoChan).
+type SlicedScanHit struct {
+	Slice int
+	Hit   *SearchHit
+}
+
+// SliceProgress reports the progress of a slice of a SlicedScanService.
+type SliceProgress struct {
+	Slice  int   // id of the slice
+	Slices int   // total number of slices
+	Pages  int   // number of pages read so far
+	Hits   int64 // number of hits read so far
+	Total  int64 // total number of hits in the slice, as reported with the first page
+	Done   bool  // true if the slice is finished, successfully or not
+	Err    error // error that finished the slice, if any
+}
+
+// PaginationCheckpointFunc is called with a checkpoint of a slice after
+// each page (see SlicedScanService.Checkpoint).
+type PaginationCheckpointFunc func(checkpoint *PaginationCheckpoint)
+
+// SliceProgressFunc is called with the progress of a slice after each page,
+// and when the slice is finished (see SlicedScanService.Progress).
+type SliceProgressFunc func(progress SliceProgress)
+
+// SlicedScanService reads all documents matching a search in parallel.
+// It splits the search into a number of slices with a SliceQuery, and
+// pages through each slice in its own goroutine, either via the Scroll
+// API or via a point in time and search_after. The hits of all slices
+// are merged and passed to a single callback (see Do) or channel
+// (see DoChan).
+//
+// At most Concurrency slices are read at the same time. If a slice fails,
+// all other slices are stopped. In any case, every scroll and point in
+// time opened by the service is released before Do returns.
+//
+// When reading with a point in time, the service can emit checkpoints of
+// every slice, and resume from them after a failure (see Checkpoint and
+// Resume).
+//
+// Example:
+//
+//	err := client.SlicedScan("tweets").
+//		Query(q).
+//		Slices(8).
+//		Concurrency(4).
+//		Do(ctx, func(slice int, hit *elastic.SearchHit) error {
+//			...
+//			return nil
+//		})
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/7.x/paginate-search-results.html#slice-scroll
+// for details.
+type SlicedScanService struct {
+	client *Client
+
+	headers      http.Header
+	indices      []string
+	searchSource *SearchSource
+	slices       int
+	sliceField   string
+	concurrency  int
+	size         int
+	keepAlive    string
+	refresh      time.Duration
+	pointInTime  bool
+	progress     SliceProgressFunc
+	checkpoint   PaginationCheckpointFunc
+	resume       []*PaginationCheckpoint
+}
+
+// NewSlicedScanService creates a new SlicedScanService.
+func NewSlicedScanService(client *Client) *SlicedScanService {
+	return &SlicedScanService{
+		client:       client,
+		searchSource: NewSearchSource(),
+		slices:       2,
+	}
+}
+
+// Header adds a header to the requests.
+func (s *SlicedScanService) Header(name string, value string) *SlicedScanService {
+	if s.headers == nil {
+		s.headers = http.Header{}
+	}
+	s.headers.Add(name, value)
+	return s
+}
+
+// Headers specifies the headers of the requests.
+func (s *SlicedScanService) Headers(headers http.Header) *SlicedScanService {
+	s.headers = headers
+	return s
+}
+
+// Index sets the names of the indices to read from.
+func (s *SlicedScanService) Index(indices ...string) *SlicedScanService {
+	s.indices = append(s.indices, indices...)
+	return s
+}
+
+// SearchSource sets the search to run on every slice. Its slice, if any,
+// is replaced.
+func (s *SlicedScanService) SearchSource(searchSource *SearchSource) *SlicedScanService {
+	s.searchSource = searchSource
+	if s.searchSource == nil {
+		s.searchSource = NewSearchSource()
+	}
+	return s
+}
+
+// Query sets the query of the search.
+func (s *SlicedScanService) Query(query Query) *SlicedScanService {
+	s.searchSource = s.searchSource.Query(query)
+	return s
+}
+
+// Slices is the number of slices to split the search into (2 by default).
+func (s *SlicedScanService) Slices(slices int) *SlicedScanService {
+	s.slices = slices
+	return s
+}
+
+// SliceField is the field to slice by. It must be a numeric doc value
+// field. By default, Elasticsearch slices by _id.
+func (s *SlicedScanService) SliceField(field string) *SlicedScanService {
+	s.sliceField = field
+	return s
+}
+
+// Concurrency is the maximum number of slices that are read at the same
+// time. By default, all slices are read at the same time.
+func (s *SlicedScanService) Concurrency(concurrency int) *SlicedScanService {
+	s.concurrency = concurrency
+	return s
+}
+
+// Size is the number of hits per page of a slice.
+func (s *SlicedScanService) Size(size int) *SlicedScanService {
+	s.size = size
+	return s
+}
+
+// KeepAlive specifies how long Elasticsearch keeps the scrolls or the
+// point in time alive between two pages, e.g. "5m" (the default).
+func (s *SlicedScanService) KeepAlive(keepAlive string) *SlicedScanService {
+	s.keepAlive = keepAlive
+	return s
+}
+
+// RefreshInterval specifies how often the scan extends the keep alive of
+// its point in time in the background, so that it doesn't expire while
+// slices are waiting to be read or their hits are being processed. By
+// default, it is half of the keep alive. Use a negative interval to
+// disable refreshing.
+func (s *SlicedScanService) RefreshInterval(interval time.Duration) *SlicedScanService {
+	s.refresh = interval
+	return s
+}
+
+// PointInTime specifies whether to read the slices with a point in time
+// and search_after instead of the Scroll API (false by default). All
+// slices share a single point in time, which requires Elasticsearch 7.10
+// or later.
+func (s *SlicedScanService) PointInTime(pointInTime bool) *SlicedScanService {
+	s.pointInTime = pointInTime
+	return s
+}
+
+// Progress specifies a function that is called with the progress of a
+// slice after each page, and when the slice is finished.
+func (s *SlicedScanService) Progress(progress SliceProgressFunc) *SlicedScanService {
+	s.progress = progress
+	return s
+}
+
+// Checkpoint specifies a function that is called with a checkpoint of a
+// slice after all hits of a page have been passed to Do or DoChan, and
+// with a final checkpoint when the slice is finished. Store the most
+// recent checkpoint of every slice to resume the scan later (see Resume).
+// Checkpoints require PointInTime(true).
+func (s *SlicedScanService) Checkpoint(checkpoint PaginationCheckpointFunc) *SlicedScanService {
+	s.checkpoint = checkpoint
+	return s
+}
+
+// Resume continues a scan from the most recent checkpoints of its slices,
+// e.g. in a new process after the previous one failed. The search and the
+// number of slices must be the same as those of the checkpoints. Slices
+// without a checkpoint are read from the start, and finished slices are
+// skipped. Resume requires PointInTime(true).
+//
+// IMPORTANT: If the point in time of the checkpoints has expired, the scan
+// opens a new one and continues after the sort values of the checkpoints.
+// The _shard_doc tiebreaker is only meaningful within the point in time
+// that returned it, so this requires the search to sort by a unique key,
+// e.g. a unique field of the documents. Otherwise, the scan fails instead
+// of skipping or repeating hits (see PointInTimePaginator.Resume).
+func (s *SlicedScanService) Resume(checkpoints ...*PaginationCheckpoint) *SlicedScanService {
+	s.resume = append(s.resume, checkpoints...)
+	return s
+}
+
+// Validate checks if the operation is valid.
+func (s *SlicedScanService) Validate() error {
+	var invalid []string
+	if s.slices < 1 {
+		invalid = append(invalid, "Slices")
+	}
+	if s.concurrency < 0 {
+		invalid = append(invalid, "Concurrency")
+	}
+	if (s.checkpoint != nil || len(s.resume) > 0) && !s.pointInTime {
+		invalid = append(invalid, "PointInTime")
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("missing required fields: %v", invalid)
+	}
+	return nil
+}
+
+// Do reads all slices and calls fn for every hit. Calls to fn are
+// serialized, i.e. they never run at the same time. The same applies to
+// calls to the Progress and Checkpoint functions, but these may run at the
+// same time as fn. Hits of different slices are interleaved in no
+// particular order.
+//
+// Do returns the first error of any slice, including errors returned by
+// fn, after all slices are stopped and all scrolls and points in time
+// are released.
+func (s *SlicedScanService) Do(ctx context.Context, fn SlicedScanHitFunc) error {
+	var mu sync.Mutex // serializes fn
+	return s.do(ctx, func(ctx context.Context, slice int, hit *SearchHit) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return fn(slice, hit)
+	})
+}
+
+// DoChan reads all slices like Do, but sends the hits to the given
+// channel. All slices send to the channel at the same time, so a slow
+// consumer doesn't hold up reading the pages of other slices. DoChan
+// closes the channel when it returns.
+//
+// Example:
+//
+//	hits := make(chan elastic.SlicedScanHit)
+//	g, ctx := errgroup.WithContext(ctx)
+//	g.Go(func() error {
+//		return client.SlicedScan("tweets").Slices(4).DoChan(ctx, hits)
+//	})
+//	g.Go(func() error {
+//		for hit := range hits {
+//			...
+//		}
+//		return nil
+//	})
+//	err := g.Wait()
+func (s *SlicedScanService) DoChan(ctx context.Context, hits chan<- SlicedScanHit) error {
+	defer close(hits)
+	return s.do(ctx, func(ctx context.Context, slice int, hit *SearchHit) error {
+		select {
+		case hits <- SlicedScanHit{Slice: slice, Hit: hit}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// do reads all slices and passes their hits to emit.
+func (s *SlicedScanService) do(ctx context.Context, emit func(ctx context.Context, slice int, hit *SearchHit) error) (err error) {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	keepAlive := s.keepAlive
+	if keepAlive == "" {
+		keepAlive = DefaultScrollKeepAlive
+	}
+
+	checkpoints, err := s.resumeCheckpoints()
+	if err != nil {
+		return err
+	}
+
+	// All slices share a single point in time
+	pit := new(slicedScanPIT)
+	if s.pointInTime {
+		pitID, err := s.openPointInTime(ctx, keepAlive, checkpoints)
+		if err != nil {
+			return err
+		}
+		pit.set(pitID)
+		defer func() {
+			rctx, cancel := releaseContext(ctx)
+			defer cancel()
+			_, cerr := s.client.ClosePointInTime(pit.get()).Do(rctx)
+			if cerr != nil && !IsNotFound(cerr) && err == nil {
+				err = errors.Wrap(cerr, "elastic: cannot close point in time of sliced scan")
+			}
+		}()
+
+		// Stop refreshing before closing the point in time
+		stop := s.startRefresh(pit, keepAlive)
+		defer stop()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex // serializes progress and checkpoints
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	concurrency := s.concurrency
+	if concurrency == 0 || concurrency > s.slices {
+		concurrency = s.slices
+	}
+	slices := make(chan int, s.slices)
+	for i := 0; i < s.slices; i++ {
+		slices <- i
+	}
+	close(slices)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for slice := range slices {
+				if ctx.Err() != nil {
+					return
+				}
+				pager := s.pager(slice, keepAlive, pit.get(), checkpoints[slice])
+				if err := s.scanSlice(ctx, slice, pager, &mu, emit); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// slicedScanPIT is the point in time shared by all slices of a scan.
+type slicedScanPIT struct {
+	mu sync.Mutex
+	id string
+}
+
+// get returns the most recent id of the point in time.
+func (p *slicedScanPIT) get() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.id
+}
+
+// set updates the id of the point in time.
+func (p *slicedScanPIT) set(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.id = id
+}
+
+// startRefresh extends the keep alive of the point in time in the
+// background, like PointInTimePaginator does for its own point in time.
+// It returns a function that stops refreshing and waits for it.
+func (s *SlicedScanService) startRefresh(pit *slicedScanPIT, keepAlive string) func() {
+	interval := s.refresh
+	if interval == 0 {
+		interval = parseKeepAlive(keepAlive) / 2
+	}
+	if interval <= 0 {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.refreshPointInTime(ctx, pit, keepAlive, interval)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// refreshPointInTime extends the keep alive of the point in time every
+// interval, until ctx is done.
+func (s *SlicedScanService) refreshPointInTime(ctx context.Context, pit *slicedScanPIT, keepAlive string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// A search without hits extends the keep alive
+		pitID := pit.get()
+		res, err := s.client.Search().
+			PointInTime(NewPointInTimeWithKeepAlive(pitID, keepAlive)).
+			Headers(s.headers).
+			Size(0).
+			TrackTotalHits(false).
+			Do(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.client.log(ctx, LogLevelWarn, fmt.Sprintf("elastic: cannot refresh point in time of sliced scan: %v", err),
+					"error", err)
+			}
+			continue
+		}
+		if res.PitId != "" && res.PitId != pitID {
+			pit.set(res.PitId)
+		}
+	}
+}
+
+// resumeCheckpoints returns the checkpoints to resume from by slice.
+func (s *SlicedScanService) resumeCheckpoints() (map[int]*PaginationCheckpoint, error) {
+	slices := s.slices
+	if slices == 1 {
+		slices = 0 // not sliced
+	}
+	checkpoints := make(map[int]*PaginationCheckpoint)
+	for _, cp := range s.resume {
+		if cp == nil {
+			continue
+		}
+		if cp.Slices != slices || cp.Slice < 0 || cp.Slice >= s.slices {
+			return nil, fmt.Errorf("elastic: checkpoint of slice %d/%d does not match %d slices", cp.Slice, cp.Slices, s.slices)
+		}
+		checkpoints[cp.Slice] = cp
+	}
+	return checkpoints, nil
+}
+
+// openPointInTime returns the point in time to scan with. It uses the
+// point in time of the checkpoints to resume from, if it is still alive,
+// or opens a new one. In the latter case, the checkpoints are marked as
+// expired, so their slices continue with the new point in time (see
+// PointInTimePaginator.Resume).
+func (s *SlicedScanService) openPointInTime(ctx context.Context, keepAlive string, checkpoints map[int]*PaginationCheckpoint) (string, error) {
+	for _, cp := range checkpoints {
+		if cp.Done || cp.Expired() {
+			continue
+		}
+		// A search without hits checks and extends the point in time
+		res, err := s.client.Search().
+			PointInTime(NewPointInTimeWithKeepAlive(cp.PitId, keepAlive)).
+			Headers(s.headers).
+			Size(0).
+			TrackTotalHits(false).
+			Do(ctx)
+		if err != nil {
+			if isPointInTimeMissing(err) {
+				break
+			}
+			return "", err
+		}
+		if res.PitId != "" {
+			return res.PitId, nil
+		}
+		return cp.PitId, nil
+	}
+	res, err := s.client.OpenPointInTime(s.indices...).
+		Headers(s.headers).
+		KeepAlive(keepAlive).
+		Do(ctx)
+	if err != nil {
+		return "", err
+	}
+	for slice, cp := range checkpoints {
+		expired := *cp
+		expired.PitId = ""
+		checkpoints[slice] = &expired
+	}
+	return res.Id, nil
+}
+
+// pager returns the pager of a slice.
+func (s *SlicedScanService) pager(slice int, keepAlive, pitID string, checkpoint *PaginationCheckpoint) searchPager {
+	src := *s.searchSource
+	src.sliceQuery = nil
+	if s.slices > 1 {
+		q := NewSliceQuery().Id(slice).Max(s.slices)
+		if s.sliceField != "" {
+			q = q.Field(s.sliceField)
+		}
+		src.sliceQuery = q
+	}
+
+	if pitID != "" {
+		search := s.client.Search().
+			SearchSource(&src).
+			Headers(s.headers).
+			PointInTime(NewPointInTimeWithKeepAlive(pitID, keepAlive))
+		if s.size > 0 {
+			search = search.Size(s.size)
+		}
+		return NewPointInTimePaginator(search).Resume(checkpoint)
+	}
+
+	scroll := s.client.Scroll(s.indices...).
+		SearchSource(&src).
+		Headers(s.headers).
+		KeepAlive(keepAlive)
+	if s.size > 0 {
+		scroll = scroll.Size(s.size)
+	}
+	return &scrollPager{s: scroll}
+}
+
+// scanSlice reads all pages of a slice and releases its resources.
+func (s *SlicedScanService) scanSlice(
+	ctx context.Context,
+	slice int,
+	pager searchPager,
+	mu *sync.Mutex,
+	emit func(ctx context.Context, slice int, hit *SearchHit) error,
+) (err error) {
+	progress := SliceProgress{Slice: slice, Slices: s.slices}
+	defer func() {
+		rctx, cancel := releaseContext(ctx)
+		defer cancel()
+		if cerr := pager.Close(rctx); cerr != nil && err == nil {
+			err = errors.Wrapf(cerr, "elastic: cannot release slice %d of sliced scan", slice)
+		}
+
+		progress.Done, progress.Err = true, err
+		mu.Lock()
+		defer mu.Unlock()
+		s.reportProgress(progress)
+	}()
+
+	for {
+		res, err := pager.Next(ctx)
+		if err == io.EOF {
+			mu.Lock()
+			s.reportCheckpoint(pager)
+			mu.Unlock()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if progress.Pages == 0 {
+			progress.Total = res.TotalHits()
+		}
+		progress.Pages++
+
+		var hits []*SearchHit
+		if res.Hits != nil {
+			hits = res.Hits.Hits
+		}
+		for _, hit := range hits {
+			if err := emit(ctx, slice, hit); err != nil {
+				return err
+			}
+			progress.Hits++
+		}
+		mu.Lock()
+		s.reportProgress(progress)
+		s.reportCheckpoint(pager)
+		mu.Unlock()
+	}
+}
+
+// reportProgress calls the Progress function, if any. It must be called
+// with the mutex of Do held.
+func (s *SlicedScanService) reportProgress(progress SliceProgress) {
+	if s.progress != nil {
+		s.progress(progress)
+	}
+}
+
+// reportCheckpoint calls the Checkpoint function, if any, with the
+// checkpoint of a slice. It must be called with the mutex of Do held.
+func (s *SlicedScanService) reportCheckpoint(pager searchPager) {
+	if p, ok := pager.(*PointInTimePaginator); ok && s.checkpoint != nil {
+		s.checkpoint(p.Checkpoint())
+	}
+}
@@ -0,0 +1,476 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slicedScanTestServer is a fake Elasticsearch cluster with documents
+// 1..total that supports sliced scrolls and sliced points in time.
+// Document i belongs to slice i%max.
+type slicedScanTestServer struct {
+	*httptest.Server
+	total int
+
+	mu            sync.Mutex
+	openedScrolls int
+	clearedScroll []string
+	openedPITs    int
+	closedPITs    []string
+	keptAlive     []string
+	slices        []string
+}
+
+func newSlicedScanTestServer(t *testing.T, total int) *slicedScanTestServer {
+	s := &slicedScanTestServer{total: total}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := ioutil.ReadAll(r.Body)
+		var req map[string]interface{}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &req); err != nil {
+				t.Errorf("invalid request body %q: %v", body, err)
+			}
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		switch {
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/_pit"):
+			s.openedPITs++
+			fmt.Fprintf(w, `{"id":"pit-%d"}`, s.openedPITs)
+		case r.Method == "DELETE" && r.URL.Path == "/_pit":
+			s.closedPITs = append(s.closedPITs, fmt.Sprint(req["id"]))
+			fmt.Fprint(w, `{"succeeded":true,"num_freed":1}`)
+		case r.Method == "DELETE" && r.URL.Path == "/_search/scroll":
+			s.clearedScroll = append(s.clearedScroll, fmt.Sprint(req["scroll_id"]))
+			fmt.Fprint(w, `{"succeeded":true,"num_freed":1}`)
+		case r.URL.Path == "/_search/scroll":
+			// Scroll ids are "<slice id>-<max>-<last document>"
+			var id, max, after int
+			fmt.Sscanf(fmt.Sprint(req["scroll_id"]), "%d-%d-%d", &id, &max, &after)
+			s.writePage(w, id, max, after, 2, func(last int) string {
+				return fmt.Sprintf(`"_scroll_id":"%d-%d-%d"`, id, max, last)
+			})
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			id, max := 0, 1
+			if slice, ok := req["slice"].(map[string]interface{}); ok {
+				id, max = int(slice["id"].(float64)), int(slice["max"].(float64))
+				s.slices = append(s.slices, fmt.Sprintf("%d/%d", id, max))
+			}
+			if r.URL.Query().Get("scroll") != "" {
+				s.openedScrolls++
+				size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+				s.writePage(w, id, max, 0, size, func(last int) string {
+					return fmt.Sprintf(`"_scroll_id":"%d-%d-%d"`, id, max, last)
+				})
+				return
+			}
+			pit := req["pit"].(map[string]interface{})
+			for _, id := range s.closedPITs {
+				if id == pit["id"] {
+					w.WriteHeader(http.StatusNotFound)
+					fmt.Fprint(w, `{"error":{"root_cause":[{"type":"search_context_missing_exception","reason":"No search context found"}],"type":"search_phase_execution_exception","reason":"all shards failed"},"status":404}`)
+					return
+				}
+			}
+			if size, ok := req["size"].(float64); ok && size == 0 {
+				s.keptAlive = append(s.keptAlive, fmt.Sprintf("%v/%v", pit["id"], pit["keep_alive"]))
+				fmt.Fprintf(w, `{"pit_id":%q,"hits":{"hits":[]}}`, pit["id"])
+				return
+			}
+			var after int
+			if v, ok := req["search_after"].([]interface{}); ok && len(v) > 0 {
+				after = int(v[0].(float64))
+			}
+			s.writePage(w, id, max, after, int(req["size"].(float64)), func(int) string {
+				return fmt.Sprintf(`"pit_id":%q`, pit["id"])
+			})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	return s
+}
+
+// writePage writes the next size documents of a slice after document after.
+func (s *slicedScanTestServer) writePage(w http.ResponseWriter, id, max, after, size int, extra func(last int) string) {
+	var hits []string
+	var count int
+	last := after
+	for i := 1; i <= s.total; i++ {
+		if i%max != id {
+			continue
+		}
+		count++
+		if i > after && len(hits) < size {
+			hits = append(hits, fmt.Sprintf(`{"_index":"twitter","_id":"%d","sort":[%d,%d]}`, i, i, i))
+			last = i
+		}
+	}
+	fmt.Fprintf(w, `{%s,"hits":{"total":{"value":%d,"relation":"eq"},"hits":[%s]}}`, extra(last), count, strings.Join(hits, ","))
+}
+
+func TestSlicedScanScroll(t *testing.T) {
+	ts := newSlicedScanTestServer(t, 10)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	done := make(map[int]SliceProgress)
+	err = client.SlicedScan("twitter").
+		Query(NewMatchAllQuery()).
+		Slices(3).
+		Concurrency(2).
+		Size(2).
+		Progress(func(p SliceProgress) {
+			if p.Done {
+				done[p.Slice] = p
+			}
+		}).
+		Do(context.Background(), func(slice int, hit *SearchHit) error {
+			id, _ := strconv.Atoi(hit.Id)
+			if want, have := id%3, slice; want != have {
+				t.Errorf("expected document %d in slice %d; got: %d", id, want, have)
+			}
+			ids = append(ids, hit.Id)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	if want, have := "1,2,3,4,5,6,7,8,9,10", strings.Join(ids, ","); want != have {
+		t.Fatalf("expected ids %s; got: %s", want, have)
+	}
+	sort.Strings(ts.slices)
+	if want, have := "0/3,1/3,2/3", strings.Join(ts.slices, ","); want != have {
+		t.Fatalf("expected slices %s; got: %s", want, have)
+	}
+
+	// Progress is reported for every slice
+	if want, have := 3, len(done); want != have {
+		t.Fatalf("expected %d finished slices; got: %d", want, have)
+	}
+	for slice, p := range done {
+		if p.Err != nil {
+			t.Fatalf("slice %d: expected no error; got: %v", slice, p.Err)
+		}
+		if p.Hits != p.Total || p.Slices != 3 {
+			t.Fatalf("slice %d: expected all %d hits of 3 slices; got: %+v", slice, p.Total, p)
+		}
+	}
+
+	// Every scroll is cleared
+	if want, have := ts.openedScrolls, len(ts.clearedScroll); want != have {
+		t.Fatalf("expected %d cleared scrolls; got: %d", want, have)
+	}
+}
+
+func TestSlicedScanPointInTime(t *testing.T) {
+	ts := newSlicedScanTestServer(t, 10)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := make(chan SlicedScanHit)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- client.SlicedScan("twitter").Slices(2).Size(3).PointInTime(true).DoChan(context.Background(), hits)
+	}()
+	var n int
+	for hit := range hits {
+		id, _ := strconv.Atoi(hit.Hit.Id)
+		if want, have := id%2, hit.Slice; want != have {
+			t.Fatalf("expected document %d in slice %d; got: %d", id, want, have)
+		}
+		n++
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 10, n; want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+
+	// All slices share a single point in time, which is closed at the end
+	if want, have := 1, ts.openedPITs; want != have {
+		t.Fatalf("expected %d opened points in time; got: %d", want, have)
+	}
+	if want, have := "pit-1", strings.Join(ts.closedPITs, ","); want != have {
+		t.Fatalf("expected closed points in time %s; got: %s", want, have)
+	}
+}
+
+func TestSlicedScanSlowConsumerDoesNotBlockOtherSlices(t *testing.T) {
+	ts := newSlicedScanTestServer(t, 4)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := make(chan SlicedScanHit)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- client.SlicedScan("twitter").Slices(2).Size(2).DoChan(context.Background(), hits)
+	}()
+
+	// Wait for a hit of every slice before reading on, while the slice
+	// of the first hit is blocked sending its second hit
+	seen := make(map[int]bool)
+	for len(seen) < 2 {
+		select {
+		case hit := <-hits:
+			seen[hit.Slice] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected hits of all slices; got: %v", seen)
+		}
+	}
+	for range hits {
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSlicedScanRefreshesPointInTime(t *testing.T) {
+	ts := newSlicedScanTestServer(t, 4)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.SlicedScan("twitter").
+		Slices(2).
+		Size(2).
+		PointInTime(true).
+		KeepAlive("1m").
+		RefreshInterval(10*time.Millisecond).
+		Do(context.Background(), func(slice int, hit *SearchHit) error {
+			time.Sleep(20 * time.Millisecond) // slow consumer
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.mu.Lock()
+	keptAlive := append([]string(nil), ts.keptAlive...)
+	ts.mu.Unlock()
+	if len(keptAlive) == 0 {
+		t.Fatal("expected point in time to be refreshed")
+	}
+	for _, have := range keptAlive {
+		if want := "pit-1/1m"; want != have {
+			t.Fatalf("expected refresh of %s; got: %s", want, have)
+		}
+	}
+
+	// Refreshing stops before the point in time is closed
+	time.Sleep(50 * time.Millisecond)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if want, have := len(keptAlive), len(ts.keptAlive); want != have {
+		t.Fatalf("expected %d refreshes; got: %d", want, have)
+	}
+	if want, have := "pit-1", strings.Join(ts.closedPITs, ","); want != have {
+		t.Fatalf("expected closed points in time %s; got: %s", want, have)
+	}
+}
+
+func TestSlicedScanError(t *testing.T) {
+	ts := newSlicedScanTestServer(t, 100)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errStop := errors.New("stop")
+	for _, pit := range []bool{false, true} {
+		var failed int
+		err = client.SlicedScan("twitter").
+			Slices(4).
+			Size(2).
+			PointInTime(pit).
+			Progress(func(p SliceProgress) {
+				if p.Done && p.Err != nil {
+					failed++
+				}
+			}).
+			Do(context.Background(), func(slice int, hit *SearchHit) error {
+				if hit.Id == "42" {
+					return errStop
+				}
+				return nil
+			})
+		if err != errStop {
+			t.Fatalf("PointInTime(%v): expected %v; got: %v", pit, errStop, err)
+		}
+		if failed == 0 {
+			t.Fatalf("PointInTime(%v): expected failed slices to be reported", pit)
+		}
+	}
+
+	// Every scroll and point in time is released
+	if want, have := ts.openedScrolls, len(ts.clearedScroll); want != have {
+		t.Fatalf("expected %d cleared scrolls; got: %d", want, have)
+	}
+	if want, have := ts.openedPITs, len(ts.closedPITs); want != have {
+		t.Fatalf("expected %d closed points in time; got: %d", want, have)
+	}
+}
+
+func TestSlicedScanValidate(t *testing.T) {
+	client, err := NewSimpleClient(SetURL("http://127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.SlicedScan("twitter").Slices(0).Do(context.Background(), func(int, *SearchHit) error { return nil })
+	if err == nil {
+		t.Fatal("expected error for zero slices")
+	}
+}
+
+func TestSlicedScanResume(t *testing.T) {
+	ts := newSlicedScanTestServer(t, 100)
+	defer ts.Close()
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first run fails, and its point in time is closed
+	errStop := errors.New("stop")
+	checkpoints := make(map[int]*PaginationCheckpoint)
+	err = client.SlicedScan("twitter").
+		SearchSource(NewSearchSource().Sort("id", true)).
+		Slices(3).
+		Size(5).
+		PointInTime(true).
+		Checkpoint(func(cp *PaginationCheckpoint) {
+			data, err := json.Marshal(cp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkpoints[cp.Slice] = new(PaginationCheckpoint)
+			if err := json.Unmarshal(data, checkpoints[cp.Slice]); err != nil {
+				t.Fatal(err)
+			}
+		}).
+		Do(context.Background(), func(slice int, hit *SearchHit) error {
+			if hit.Id == "50" {
+				return errStop
+			}
+			return nil
+		})
+	if err != errStop {
+		t.Fatalf("expected %v; got: %v", errStop, err)
+	}
+	if len(checkpoints) == 0 {
+		t.Fatal("expected checkpoints")
+	}
+	for slice, cp := range checkpoints {
+		if want, have := "pit-1", cp.PitId; want != have {
+			t.Fatalf("slice %d: expected PitId %s; got: %s", slice, want, have)
+		}
+		if cp.Expired() {
+			t.Fatalf("slice %d: expected checkpoint to not be expired; got: %v", slice, cp.Expires)
+		}
+	}
+
+	// Without a unique sort, the checkpoints cannot be used with a new
+	// point in time
+	var resume []*PaginationCheckpoint
+	for _, cp := range checkpoints {
+		resume = append(resume, cp)
+	}
+	err = client.SlicedScan("twitter").
+		Slices(3).
+		Size(5).
+		PointInTime(true).
+		Resume(resume...).
+		Do(context.Background(), func(slice int, hit *SearchHit) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "unique") {
+		t.Fatalf("expected error for resuming without a unique sort; got: %v", err)
+	}
+
+	// The second run continues after the checkpoints with a new point in time
+	seen := make(map[string]bool)
+	err = client.SlicedScan("twitter").
+		SearchSource(NewSearchSource().Sort("id", true)).
+		Slices(3).
+		Size(5).
+		PointInTime(true).
+		Resume(resume...).
+		Do(context.Background(), func(slice int, hit *SearchHit) error {
+			if cp := checkpoints[slice]; cp != nil {
+				id, _ := strconv.Atoi(hit.Id)
+				if after := cp.SearchAfter[0].(float64); float64(id) <= after {
+					t.Errorf("slice %d: expected hits after %v; got: %d", slice, after, id)
+				}
+			}
+			seen[hit.Id] = true
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !seen["50"] || !seen["100"] {
+		t.Fatalf("expected the remaining hits; got: %v", seen)
+	}
+	if want, have := 3, ts.openedPITs; want != have {
+		t.Fatalf("expected %d opened points in time; got: %d", want, have)
+	}
+	if want, have := "pit-1,pit-2,pit-3", strings.Join(ts.closedPITs, ","); want != have {
+		t.Fatalf("expected closed points in time %s; got: %s", want, have)
+	}
+}
+
+func TestSlicedScanResumeRequiresPointInTime(t *testing.T) {
+	client, err := NewSimpleClient(SetURL("http://127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.SlicedScan("twitter").
+		Resume(&PaginationCheckpoint{PitId: "pit-1", Slices: 2}).
+		Do(context.Background(), func(int, *SearchHit) error { return nil })
+	if err == nil {
+		t.Fatal("expected error when resuming a scroll")
+	}
+	err = client.SlicedScan("twitter").
+		Slices(3).
+		PointInTime(true).
+		Resume(&PaginationCheckpoint{PitId: "pit-1", Slices: 2}).
+		Do(context.Background(), func(int, *SearchHit) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("expected error for checkpoint of other slices; got: %v", err)
+	}
+}
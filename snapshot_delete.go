@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -142,7 +141,7 @@ func (s *SnapshotDeleteService) Do(ctx context.Context) (*SnapshotDeleteResponse
 
 	// Return operation response
 	ret := new(SnapshotDeleteResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -195,7 +194,7 @@ func (s *SnapshotGetService) Do(ctx context.Context) (*SnapshotGetResponse, erro
 
 	// Return operation response
 	ret := new(SnapshotGetResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -199,7 +198,7 @@ func (s *SnapshotRestoreService) Do(ctx context.Context) (*SnapshotRestoreRespon
 	}
 
 	ret := new(SnapshotRestoreResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -182,7 +181,7 @@ func (s *SnapshotStatusService) Do(ctx context.Context) (*SnapshotStatusResponse
 
 	// Return operation response
 	ret := new(SnapshotStatusResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
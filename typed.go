@@ -0,0 +1,165 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Hit is a search hit with its document decoded into T.
+type Hit[T any] struct {
+	Index       string
+	Id          string
+	Routing     string
+	Score       *float64
+	Sort        []interface{}
+	Version     *int64
+	SeqNo       *int64
+	PrimaryTerm *int64
+	Highlight   SearchHitHighlight
+
+	// Source is the decoded document. It is the zero value of T if the
+	// hit has no source or if it cannot be decoded.
+	Source T
+	// Err is the error from decoding the document, if any.
+	Err error
+	// Raw is the search hit as returned from Elasticsearch, e.g. to
+	// access fields or inner hits.
+	Raw *SearchHit
+}
+
+// Hits decodes the documents of the hits in res into T, using the
+// Decoder of the client. Unlike SearchResult.Each, hits whose document
+// cannot be decoded are returned with Err set.
+//
+// Example:
+//
+//	res, err := client.Search("tweets").Query(q).Do(ctx)
+//	...
+//	for _, hit := range elastic.Hits[Tweet](client, res) {
+//		if hit.Err != nil {
+//			...
+//		}
+//		fmt.Println(hit.Id, hit.Source.Message)
+//	}
+func Hits[T any](client *Client, res *SearchResult) []Hit[T] {
+	if res == nil || res.Hits == nil || len(res.Hits.Hits) == 0 {
+		return nil
+	}
+	dec := clientDecoder(client)
+	hits := make([]Hit[T], 0, len(res.Hits.Hits))
+	for _, h := range res.Hits.Hits {
+		hit := Hit[T]{
+			Index:       h.Index,
+			Id:          h.Id,
+			Routing:     h.Routing,
+			Score:       h.Score,
+			Sort:        h.Sort,
+			Version:     h.Version,
+			SeqNo:       h.SeqNo,
+			PrimaryTerm: h.PrimaryTerm,
+			Highlight:   h.Highlight,
+			Raw:         h,
+		}
+		if len(h.Source) > 0 {
+			if err := dec.Decode(h.Source, &hit.Source); err != nil {
+				var zero T
+				hit.Source = zero
+				hit.Err = errors.Wrapf(err, "elastic: cannot decode document %q of index %q", h.Id, h.Index)
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits
+}
+
+// TypedSearchResult is a SearchResult with the documents of its hits
+// decoded into T (see SearchAs).
+type TypedSearchResult[T any] struct {
+	*SearchResult
+
+	// Docs are the hits with their decoded documents.
+	Docs []Hit[T]
+}
+
+// Err returns the first error from decoding the documents, if any.
+func (r *TypedSearchResult[T]) Err() error {
+	for _, hit := range r.Docs {
+		if hit.Err != nil {
+			return hit.Err
+		}
+	}
+	return nil
+}
+
+// SearchAs executes the search and decodes the documents of the hits
+// into T (see Hits). Errors from decoding the documents are reported per
+// hit and are not returned.
+//
+// Example:
+//
+//	res, err := elastic.SearchAs[Tweet](ctx, client.Search("tweets").Query(q))
+func SearchAs[T any](ctx context.Context, s *SearchService) (*TypedSearchResult[T], error) {
+	res, err := s.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedSearchResult[T]{
+		SearchResult: res,
+		Docs:         Hits[T](s.client, res),
+	}, nil
+}
+
+// TypedGetResult is a GetResult with its document decoded into T
+// (see GetAs).
+type TypedGetResult[T any] struct {
+	*GetResult
+
+	// Doc is the decoded document. It is the zero value of T if the
+	// document has no source.
+	Doc T
+}
+
+// GetAs gets a document and decodes it into T, using the Decoder of the
+// client. If the document cannot be decoded, the result is returned
+// along with the error.
+//
+// Example:
+//
+//	res, err := elastic.GetAs[Tweet](ctx, client.Get().Index("tweets").Id("1"))
+func GetAs[T any](ctx context.Context, s *GetService) (*TypedGetResult[T], error) {
+	res, err := s.Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ret := &TypedGetResult[T]{GetResult: res}
+	if len(res.Source) > 0 {
+		if err := clientDecoder(s.client).Decode(res.Source, &ret.Doc); err != nil {
+			var zero T
+			ret.Doc = zero
+			return ret, errors.Wrapf(err, "elastic: cannot decode document %q of index %q", res.Id, res.Index)
+		}
+	}
+	return ret, nil
+}
+
+// clientDecoder returns the Decoder of the client, or DefaultDecoder
+// if client is nil. Strict decoding applies to the responses of
+// Elasticsearch, not to documents, so the decoder wrapped by
+// StrictDecoder is used for documents.
+func clientDecoder(client *Client) Decoder {
+	if client == nil || client.decoder == nil {
+		return &DefaultDecoder{}
+	}
+	if strict, ok := client.decoder.(*StrictDecoder); ok {
+		if strict.Decoder == nil {
+			return &DefaultDecoder{}
+		}
+		return strict.Decoder
+	}
+	return client.decoder
+}
@@ -0,0 +1,163 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHits(t *testing.T) {
+	res := &SearchResult{
+		Hits: &SearchHits{
+			Hits: []*SearchHit{
+				{Index: "twitter", Id: "1", Source: []byte(`{"user":"olivere","retweets":1}`), Sort: []interface{}{1.0}, Highlight: SearchHitHighlight{"user": {"<em>olivere</em>"}}},
+				{Index: "twitter", Id: "2", Source: []byte(`{"user":"sandrae","retweets":"many"}`)},
+				{Index: "twitter", Id: "3"},
+			},
+		},
+	}
+	hits := Hits[tweet](nil, res)
+	if want, have := 3, len(hits); want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+
+	if hits[0].Err != nil {
+		t.Fatalf("expected no error; got: %v", hits[0].Err)
+	}
+	if want, have := "olivere", hits[0].Source.User; want != have {
+		t.Fatalf("expected User = %q; got: %q", want, have)
+	}
+	if want, have := "1", hits[0].Id; want != have {
+		t.Fatalf("expected Id = %q; got: %q", want, have)
+	}
+	if len(hits[0].Sort) != 1 || len(hits[0].Highlight["user"]) != 1 {
+		t.Fatalf("expected sort and highlight; got: %v, %v", hits[0].Sort, hits[0].Highlight)
+	}
+	if hits[0].Raw != res.Hits.Hits[0] {
+		t.Fatal("expected Raw to be the search hit")
+	}
+
+	// Decode errors are reported per hit
+	if hits[1].Err == nil {
+		t.Fatal("expected error")
+	}
+	if want, have := "", hits[1].Source.User; want != have {
+		t.Fatalf("expected zero document; got: %+v", hits[1].Source)
+	}
+	if want, have := "2", hits[1].Id; want != have {
+		t.Fatalf("expected Id = %q; got: %q", want, have)
+	}
+
+	// Hits without source have the zero value
+	if hits[2].Err != nil {
+		t.Fatalf("expected no error; got: %v", hits[2].Err)
+	}
+
+	if Hits[tweet](nil, nil) != nil || Hits[tweet](nil, &SearchResult{}) != nil {
+		t.Fatal("expected no hits")
+	}
+}
+
+func TestHitsIgnoreStrictDecoding(t *testing.T) {
+	client, err := NewClient(SetURL("http://127.0.0.1:9200"), SetHealthcheck(false), SetSniff(false), SetStrictDecoding(StrictDecodingFail))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &SearchResult{
+		Hits: &SearchHits{
+			Hits: []*SearchHit{
+				{Index: "twitter", Id: "1", Source: []byte(`{"user":"olivere","unknown":true}`)},
+			},
+		},
+	}
+	hits := Hits[tweet](client, res)
+	if want, have := 1, len(hits); want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+	if hits[0].Err != nil {
+		t.Fatalf("expected no error; got: %v", hits[0].Err)
+	}
+	if want, have := "olivere", hits[0].Source.User; want != have {
+		t.Fatalf("expected User = %q; got: %q", want, have)
+	}
+}
+
+func TestSearchAsAndGetAs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/twitter/_search":
+			fmt.Fprint(w, `{"took":1,"hits":{"total":{"value":2,"relation":"eq"},"hits":[
+				{"_index":"twitter","_id":"1","_seq_no":5,"_primary_term":1,"_source":{"user":"olivere"}},
+				{"_index":"twitter","_id":"2","_source":{"user":42}}
+			]}}`)
+		case "/twitter/_doc/1":
+			fmt.Fprint(w, `{"_index":"twitter","_id":"1","_version":2,"found":true,"_source":{"user":"olivere"}}`)
+		case "/twitter/_doc/2":
+			fmt.Fprint(w, `{"_index":"twitter","_id":"2","found":true,"_source":{"user":42}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"_index":"twitter","_id":"3","found":false}`)
+		}
+	}))
+	defer ts.Close()
+
+	dec := &decoder{}
+	client, err := NewSimpleClient(SetURL(ts.URL), SetDecoder(dec))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := SearchAs[tweet](context.Background(), client.Search("twitter"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(2), res.TotalHits(); want != have {
+		t.Fatalf("expected TotalHits = %d; got: %d", want, have)
+	}
+	if want, have := 2, len(res.Docs); want != have {
+		t.Fatalf("expected %d docs; got: %d", want, have)
+	}
+	if want, have := "olivere", res.Docs[0].Source.User; want != have {
+		t.Fatalf("expected User = %q; got: %q", want, have)
+	}
+	if res.Docs[0].SeqNo == nil || *res.Docs[0].SeqNo != 5 {
+		t.Fatalf("expected SeqNo = 5; got: %v", res.Docs[0].SeqNo)
+	}
+	if res.Err() == nil || res.Err() != res.Docs[1].Err {
+		t.Fatalf("expected Err to return the error of the second hit; got: %v", res.Err())
+	}
+	if dec.N < 3 {
+		t.Fatalf("expected the client's decoder to decode the documents; got %d calls", dec.N)
+	}
+
+	doc, err := GetAs[tweet](context.Background(), client.Get().Index("twitter").Id("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "olivere", doc.Doc.User; want != have {
+		t.Fatalf("expected User = %q; got: %q", want, have)
+	}
+	if doc.Version == nil || *doc.Version != 2 {
+		t.Fatalf("expected Version = 2; got: %v", doc.Version)
+	}
+
+	doc, err = GetAs[tweet](context.Background(), client.Get().Index("twitter").Id("2"))
+	if err == nil {
+		t.Fatal("expected decode error")
+	}
+	if doc == nil || doc.Id != "2" {
+		t.Fatalf("expected result along with the decode error; got: %+v", doc)
+	}
+
+	_, err = GetAs[tweet](context.Background(), client.Get().Index("twitter").Id("3"))
+	if !IsNotFound(err) {
+		t.Fatalf("expected not found; got: %v", err)
+	}
+}
@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -134,7 +133,7 @@ func (s *XPackInfoService) Do(ctx context.Context) (*XPackInfoServiceResponse, e
 
 	// Return operation response
 	ret := XPackInfoServiceResponse{}
-	if err := json.Unmarshal(res.Body, &ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, &ret); err != nil {
 		return nil, err
 	}
 	return &ret, nil
@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -158,7 +157,7 @@ func (s *XPackRollupPutService) Do(ctx context.Context) (*XPackRollupPutResponse
 
 	// Return operation response
 	ret := new(XPackRollupPutResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
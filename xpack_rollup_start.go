@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -147,7 +146,7 @@ func (s *XPackRollupStartService) Do(ctx context.Context) (*XPackRollupStartResp
 
 	// Return operation response
 	ret := new(XPackRollupStartResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
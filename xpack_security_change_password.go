@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -187,7 +186,7 @@ func (s *XPackSecurityChangePasswordService) Do(ctx context.Context) (*XPackSecu
 
 	// Return operation response
 	ret := new(XPackSecurityChangeUserPasswordResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
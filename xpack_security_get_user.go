@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -153,7 +152,7 @@ func (s *XPackSecurityGetUserService) Do(ctx context.Context) (*XPackSecurityGet
 
 	// Return operation response
 	ret := XPackSecurityGetUserResponse{}
-	if err := json.Unmarshal(res.Body, &ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, &ret); err != nil {
 		return nil, err
 	}
 	return &ret, nil
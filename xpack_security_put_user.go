@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -186,7 +185,7 @@ func (s *XPackSecurityPutUserService) Do(ctx context.Context) (*XPackSecurityPut
 
 	// Return operation response
 	ret := new(XPackSecurityPutUserResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
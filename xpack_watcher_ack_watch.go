@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -176,7 +175,7 @@ func (s *XPackWatcherAckWatchService) Do(ctx context.Context) (*XPackWatcherAckW
 
 	// Return operation response
 	ret := new(XPackWatcherAckWatchResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -157,7 +156,7 @@ func (s *XPackWatcherActivateWatchService) Do(ctx context.Context) (*XPackWatche
 
 	// Return operation response
 	ret := new(XPackWatcherActivateWatchResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
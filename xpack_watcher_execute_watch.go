@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -181,7 +180,7 @@ func (s *XPackWatcherExecuteWatchService) Do(ctx context.Context) (*XPackWatcher
 
 	// Return operation response
 	ret := new(XPackWatcherExecuteWatchResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
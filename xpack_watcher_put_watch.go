@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -201,7 +200,7 @@ func (s *XPackWatcherPutWatchService) Do(ctx context.Context) (*XPackWatcherPutW
 
 	// Return operation response
 	ret := new(XPackWatcherPutWatchResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -125,7 +124,7 @@ func (s *XPackWatcherStartService) Do(ctx context.Context) (*XPackWatcherStartRe
 
 	// Return operation response
 	ret := new(XPackWatcherStartResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil
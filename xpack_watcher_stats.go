@@ -6,7 +6,6 @@ package elastic
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -146,7 +145,7 @@ func (s *XPackWatcherStatsService) Do(ctx context.Context) (*XPackWatcherStatsRe
 
 	// Return operation response
 	ret := new(XPackWatcherStatsResponse)
-	if err := json.Unmarshal(res.Body, ret); err != nil {
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
 		return nil, err
 	}
 	return ret, nil